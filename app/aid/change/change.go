@@ -0,0 +1,172 @@
+// 结果变化检测：按规则的 ItemKey 字段识别同一条结果，
+// 与上次保存的版本比较，产生新增、变化、删除事件
+package change
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"skynet-service/app/common/util"
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+)
+
+// 事件类型
+const (
+	Added   = "added"   // 新出现的结果
+	Changed = "changed" // 字段值发生变化
+	Removed = "removed" // 本次运行未再出现的结果
+)
+
+type (
+	// 变化事件
+	Event struct {
+		Time   time.Time         `json:"time"`
+		Spider string            `json:"spider"`
+		Keyin  string            `json:"keyin"`
+		Rule   string            `json:"rule"`
+		Key    string            `json:"key"`
+		Type   string            `json:"type"`
+		Diffs  []Diff            `json:"diffs,omitempty"` // Changed 事件中发生变化的字段
+		Item   map[string]string `json:"item"`            // 本次的结果，Removed 事件中为上次的结果
+	}
+	// 字段变化
+	Diff struct {
+		Field string `json:"field"`
+		Old   string `json:"old"`
+		New   string `json:"new"`
+	}
+	// 一个蜘蛛实例(按 Keyin 区分)的变化检测器，保存各规则最近一次的结果
+	Detector struct {
+		spider string
+		keyin  string
+		path   string
+		items  map[string]map[string]map[string]string // [规则][标识]结果
+		seen   map[string]map[string]bool              // 本次运行出现过的[规则][标识]
+		sync.Mutex
+	}
+)
+
+// subName 为蜘蛛的二级标识名，保证不同 Keyin 的结果分开比较
+func New(spiderName, keyin, subName string) *Detector {
+	name := spiderName
+	if subName != "" {
+		name += "__" + subName
+	}
+	return load(spiderName, keyin, filepath.Join(config.ChangeDir, util.FileNameReplace(name)+".json"))
+}
+
+// 从记录文件 path 创建检测器
+func load(spiderName, keyin, path string) *Detector {
+	self := &Detector{
+		spider: spiderName,
+		keyin:  keyin,
+		path:   path,
+		items:  map[string]map[string]map[string]string{},
+		seen:   map[string]map[string]bool{},
+	}
+	b, err := ioutil.ReadFile(self.path)
+	if err == nil {
+		err = json.Unmarshal(b, &self.items)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		logs.Log.Error(" *     读取变化检测记录失败：%v", err)
+	}
+	return self
+}
+
+// 检查一条结果，key 为其标识字段的值；与上次相同时返回 nil
+func (self *Detector) Check(rule, key string, item map[string]string) *Event {
+	self.Lock()
+	defer self.Unlock()
+	if self.seen[rule] == nil {
+		self.seen[rule] = map[string]bool{}
+	}
+	self.seen[rule][key] = true
+	if self.items[rule] == nil {
+		self.items[rule] = map[string]map[string]string{}
+	}
+	old, ok := self.items[rule][key]
+	self.items[rule][key] = item
+	if !ok {
+		return self.event(rule, key, Added, item, nil)
+	}
+	if diffs := Compare(old, item); len(diffs) > 0 {
+		return self.event(rule, key, Changed, item, diffs)
+	}
+	return nil
+}
+
+// 结束本次检测并保存记录。
+// complete 为 true 时，对本次有结果的规则，返回上次存在而本次未出现的结果的删除事件
+func (self *Detector) Finish(complete bool) []*Event {
+	self.Lock()
+	defer self.Unlock()
+	var events []*Event
+	if complete {
+		for rule, seen := range self.seen {
+			for key, item := range self.items[rule] {
+				if !seen[key] {
+					events = append(events, self.event(rule, key, Removed, item, nil))
+					delete(self.items[rule], key)
+				}
+			}
+		}
+	}
+	self.seen = map[string]map[string]bool{}
+	if err := self.save(); err != nil {
+		logs.Log.Error(" *     保存变化检测记录失败：%v", err)
+	}
+	return events
+}
+
+// 比较两个版本的结果，按字段名排序返回变化
+func Compare(old, new map[string]string) []Diff {
+	var diffs []Diff
+	for field, v := range new {
+		if ov, ok := old[field]; !ok || ov != v {
+			diffs = append(diffs, Diff{Field: field, Old: ov, New: v})
+		}
+	}
+	for field, ov := range old {
+		if _, ok := new[field]; !ok {
+			diffs = append(diffs, Diff{Field: field, Old: ov})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs
+}
+
+func (self *Detector) event(rule, key, typ string, item map[string]string, diffs []Diff) *Event {
+	return &Event{
+		Time:   time.Now(),
+		Spider: self.spider,
+		Keyin:  self.keyin,
+		Rule:   rule,
+		Key:    key,
+		Type:   typ,
+		Diffs:  diffs,
+		Item:   item,
+	}
+}
+
+// 写入记录文件，须持有锁
+func (self *Detector) save() error {
+	b, err := json.Marshal(self.items)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(self.path), 0755); err != nil {
+		return err
+	}
+	tmp := self.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, self.path)
+}
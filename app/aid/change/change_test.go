@@ -0,0 +1,40 @@
+package change
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gold.json")
+	d := load("gold", "", path)
+	if e := d.Check("price", "usd", map[string]string{"curr": "usd", "price": "1"}); e == nil || e.Type != Added {
+		t.Fatalf("first Check() = %+v, want added", e)
+	}
+	d.Check("price", "cny", map[string]string{"curr": "cny", "price": "7"})
+	if events := d.Finish(true); len(events) != 0 {
+		t.Fatalf("Finish() = %d events, want 0", len(events))
+	}
+
+	// 重新读取记录后比较
+	d = load("gold", "", path)
+	if e := d.Check("price", "usd", map[string]string{"curr": "usd", "price": "1"}); e != nil {
+		t.Errorf("unchanged Check() = %+v, want nil", e)
+	}
+	e := d.Check("price", "usd", map[string]string{"curr": "usd", "price": "2"})
+	want := []Diff{{Field: "price", Old: "1", New: "2"}}
+	if e == nil || e.Type != Changed || !reflect.DeepEqual(e.Diffs, want) {
+		t.Errorf("changed Check() = %+v, want diffs %v", e, want)
+	}
+	events := d.Finish(true)
+	if len(events) != 1 || events[0].Type != Removed || events[0].Key != "cny" {
+		t.Errorf("Finish() = %+v, want cny removed", events)
+	}
+
+	// 中途终止时不产生删除事件
+	d.Check("price", "eur", map[string]string{"curr": "eur"})
+	if events := d.Finish(false); len(events) != 0 {
+		t.Errorf("incomplete Finish() = %+v, want no events", events)
+	}
+}
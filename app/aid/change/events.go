@@ -0,0 +1,166 @@
+package change
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+)
+
+// 事件日志按天保存的天数
+const keepDays = 30
+
+var (
+	handlers     []func(*Event)
+	handlersLock sync.RWMutex
+	eventLock    sync.Mutex
+	eventDir     = filepath.Join(config.ChangeDir, "events")
+)
+
+// 注册事件处理函数，如发送通知；处理函数应尽快返回
+func Handle(h func(*Event)) {
+	handlersLock.Lock()
+	handlers = append(handlers, h)
+	handlersLock.Unlock()
+}
+
+// 记录事件：写入事件日志并交给各处理函数
+func Emit(events ...*Event) {
+	if len(events) == 0 {
+		return
+	}
+	for _, e := range events {
+		logs.Log.Notice(" *     [结果变化：%s | %s]   %s %s", e.Spider, e.Rule, e.Type, e.Key)
+	}
+	if err := appendEvents(events); err != nil {
+		logs.Log.Error(" *     写入变化事件失败：%v", err)
+	}
+	handlersLock.RLock()
+	defer handlersLock.RUnlock()
+	for _, h := range handlers {
+		for _, e := range events {
+			h(e)
+		}
+	}
+}
+
+// 按时间倒序返回 since 之后的事件；spider 为空时不限蜘蛛，limit 不大于0时不限数量
+func List(spider string, since time.Time, limit int) ([]*Event, error) {
+	eventLock.Lock()
+	defer eventLock.Unlock()
+	days, err := eventDays()
+	if err != nil {
+		return nil, err
+	}
+	list := []*Event{}
+	first := since.Format("2006-01-02")
+	for i := len(days) - 1; i >= 0 && days[i] >= first; i-- {
+		events, err := readEvents(days[i])
+		if err != nil {
+			return nil, err
+		}
+		for j := len(events) - 1; j >= 0; j-- {
+			e := events[j]
+			if e.Time.Before(since) {
+				return list, nil
+			}
+			if spider != "" && e.Spider != spider {
+				continue
+			}
+			list = append(list, e)
+			if limit > 0 && len(list) >= limit {
+				return list, nil
+			}
+		}
+	}
+	return list, nil
+}
+
+// 追加到当天的事件日志，新建日志文件时删除过期的日志
+func appendEvents(events []*Event) error {
+	eventLock.Lock()
+	defer eventLock.Unlock()
+	if err := os.MkdirAll(eventDir, 0755); err != nil {
+		return err
+	}
+	path := dayPath(time.Now().Format("2006-01-02"))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		pruneEvents()
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
+
+func readEvents(day string) ([]*Event, error) {
+	f, err := os.Open(dayPath(day))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var events []*Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		e := new(Event)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// 按日期先后返回全部事件日志的日期
+func eventDays() ([]string, error) {
+	infos, err := ioutil.ReadDir(eventDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var days []string
+	for _, info := range infos {
+		if name := info.Name(); strings.HasSuffix(name, ".jsonl") {
+			days = append(days, strings.TrimSuffix(name, ".jsonl"))
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// 删除过期的事件日志，须持有锁
+func pruneEvents() {
+	days, _ := eventDays()
+	expire := time.Now().AddDate(0, 0, -keepDays).Format("2006-01-02")
+	for _, day := range days {
+		if day < expire {
+			os.Remove(dayPath(day))
+		}
+	}
+}
+
+func dayPath(day string) string {
+	return filepath.Join(eventDir, day+".jsonl")
+}
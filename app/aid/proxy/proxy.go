@@ -65,7 +65,7 @@ func (self *Proxy) Count() int32 {
 
 // 更新代理IP列表
 func (self *Proxy) Update() *Proxy {
-	f, err := os.Open(config.PROXY)
+	f, err := os.Open(config.Current().Proxy)
 	if err != nil {
 		// logs.Log.Error("Error: %v\n", err)
 		return self
@@ -0,0 +1,334 @@
+// 数值告警：结果经过 Collector 时按规则检查指定字段，
+// 越过阈值或在时间窗口内变化幅度超限时经由通知方式发出告警
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+)
+
+// 告警类型
+const (
+	Above  = "above"  // 升至阈值之上
+	Below  = "below"  // 降至阈值之下
+	Change = "change" // 时间窗口内的变化幅度超过百分比
+)
+
+const (
+	defaultCooldown = 30 * time.Minute // 同一告警的默认冷却时长
+	queueSize       = 1024             // 待发送告警的缓冲长度
+)
+
+type (
+	// 告警规则
+	Rule struct {
+		Name          string   `json:"name"`
+		Spider        string   `json:"spider"`
+		ItemRule      string   `json:"rule"`           // 采集规则名称，为空时检查该蜘蛛的全部规则
+		Field         string   `json:"field"`          // 数值字段
+		GroupBy       string   `json:"group_by"`       // 分组字段，各组的数值分别检查，如币种
+		Above         *float64 `json:"above"`          // 数值由不高于升至高于该值时告警
+		Below         *float64 `json:"below"`          // 数值由不低于降至低于该值时告警
+		ChangePercent float64  `json:"change_percent"` // 窗口内变化幅度(百分比)达到该值时告警，为0时不检查
+		Window        string   `json:"window"`         // 变化幅度的时间窗口，如 "1h"
+		Cooldown      string   `json:"cooldown"`       // 同一告警的冷却时长，默认30分钟
+		Notify        []string `json:"notify"`         // 通知方式名称，为空时只写日志
+		window        time.Duration
+		cooldown      time.Duration
+	}
+	// 告警
+	Alert struct {
+		Time      time.Time         `json:"time"`
+		Rule      string            `json:"rule"`
+		Spider    string            `json:"spider"`
+		ItemRule  string            `json:"item_rule"`
+		Field     string            `json:"field"`
+		Group     string            `json:"group,omitempty"`
+		Kind      string            `json:"kind"`
+		Value     float64           `json:"value"`
+		Threshold float64           `json:"threshold"`         // 阈值，change 类型时为百分比
+		Percent   float64           `json:"percent,omitempty"` // change 类型的实际变化幅度
+		Message   string            `json:"message"`
+		Item      map[string]string `json:"item"`
+	}
+	// 告警配置文件内容
+	Config struct {
+		Rules     []*Rule                    `json:"rules"`
+		Notifiers map[string]json.RawMessage `json:"notifiers"` // 名称 -> 通知方式配置，须含 type 字段
+	}
+	// 规则引擎
+	Engine struct {
+		rules     map[string][]*Rule // [蜘蛛名称]规则
+		notifiers map[string]Notifier
+		series    map[string]*series // [规则名称+分组]数值序列
+		fired     map[string]time.Time
+		queue     chan *delivery
+		sync.Mutex
+	}
+	// 一组数值的检查状态
+	series struct {
+		last    float64
+		hasLast bool
+		samples []sample // 时间窗口内的数值
+	}
+	sample struct {
+		t time.Time
+		v float64
+	}
+	delivery struct {
+		alert     *Alert
+		notifiers []Notifier
+	}
+)
+
+// 全局告警引擎
+var Alerts = New()
+
+func init() {
+	if err := Alerts.Load(config.ALERT_FILE); err != nil {
+		logs.Log.Error(" *     读取告警配置失败：%v", err)
+	}
+}
+
+func New() *Engine {
+	self := &Engine{
+		rules:     map[string][]*Rule{},
+		notifiers: map[string]Notifier{},
+		series:    map[string]*series{},
+		fired:     map[string]time.Time{},
+		queue:     make(chan *delivery, queueSize),
+	}
+	go self.deliver()
+	return self
+}
+
+// 读取告警配置文件，文件不存在时没有规则；同名规则保留已有的检查状态
+func (self *Engine) Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		b, err = []byte("{}"), nil
+	}
+	if err != nil {
+		return err
+	}
+	var conf Config
+	if err = json.Unmarshal(b, &conf); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err = self.Set(&conf); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// 设置规则与通知方式
+func (self *Engine) Set(conf *Config) error {
+	notifiers, err := newNotifiers(conf.Notifiers)
+	if err != nil {
+		return err
+	}
+	rules := map[string][]*Rule{}
+	names := map[string]bool{}
+	for _, r := range conf.Rules {
+		if err = r.init(); err != nil {
+			return fmt.Errorf("alert rule %q: %v", r.Name, err)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate alert rule %q", r.Name)
+		}
+		names[r.Name] = true
+		for _, n := range r.Notify {
+			if _, ok := notifiers[n]; !ok {
+				return fmt.Errorf("alert rule %q: notifier not found: %s", r.Name, n)
+			}
+		}
+		rules[r.Spider] = append(rules[r.Spider], r)
+	}
+
+	self.Lock()
+	defer self.Unlock()
+	self.rules = rules
+	self.notifiers = notifiers
+	for key := range self.series {
+		if !names[strings.SplitN(key, "\x00", 2)[0]] {
+			delete(self.series, key)
+		}
+	}
+	return nil
+}
+
+func (self *Rule) init() (err error) {
+	switch {
+	case self.Name == "":
+		return fmt.Errorf("name can not be empty")
+	case self.Spider == "" || self.Field == "":
+		return fmt.Errorf("spider and field are required")
+	case self.Above == nil && self.Below == nil && self.ChangePercent <= 0:
+		return fmt.Errorf("one of above, below or change_percent is required")
+	}
+	if self.ChangePercent > 0 {
+		if self.window, err = time.ParseDuration(self.Window); err != nil || self.window <= 0 {
+			return fmt.Errorf("invalid window: %q", self.Window)
+		}
+	}
+	self.cooldown = defaultCooldown
+	if self.Cooldown != "" {
+		if self.cooldown, err = time.ParseDuration(self.Cooldown); err != nil || self.cooldown < 0 {
+			return fmt.Errorf("invalid cooldown: %q", self.Cooldown)
+		}
+	}
+	return nil
+}
+
+// 是否有针对该蜘蛛的规则
+func (self *Engine) Watches(spider string) bool {
+	self.Lock()
+	defer self.Unlock()
+	return len(self.rules[spider]) > 0
+}
+
+// 检查一条结果，返回产生的告警；告警在后台发送
+func (self *Engine) Check(spider, itemRule string, item map[string]string, now time.Time) []*Alert {
+	self.Lock()
+	var (
+		alerts     []*Alert
+		deliveries []*delivery
+	)
+	for _, r := range self.rules[spider] {
+		if r.ItemRule != "" && r.ItemRule != itemRule {
+			continue
+		}
+		v, err := parseNumber(item[r.Field])
+		if err != nil {
+			continue
+		}
+		for _, a := range self.eval(r, itemRule, item, v, now) {
+			alerts = append(alerts, a)
+			deliveries = append(deliveries, &delivery{alert: a, notifiers: self.ruleNotifiers(r)})
+		}
+	}
+	self.Unlock()
+
+	for _, d := range deliveries {
+		select {
+		case self.queue <- d:
+		default:
+			logs.Log.Error(" *     [告警：%s]   待发送告警过多，丢弃：%s", d.alert.Rule, d.alert.Message)
+		}
+	}
+	return alerts
+}
+
+// 按规则检查数值，须持有锁
+func (self *Engine) eval(r *Rule, itemRule string, item map[string]string, v float64, now time.Time) []*Alert {
+	group := item[r.GroupBy]
+	key := r.Name + "\x00" + group
+	s, ok := self.series[key]
+	if !ok {
+		s = new(series)
+		self.series[key] = s
+	}
+
+	var alerts []*Alert
+	newAlert := func(kind string, threshold, percent float64, format string, a ...interface{}) {
+		if last, ok := self.fired[key+"\x00"+kind]; ok && now.Sub(last) < r.cooldown {
+			return
+		}
+		self.fired[key+"\x00"+kind] = now
+		alerts = append(alerts, &Alert{
+			Time:      now,
+			Rule:      r.Name,
+			Spider:    r.Spider,
+			ItemRule:  itemRule,
+			Field:     r.Field,
+			Group:     group,
+			Kind:      kind,
+			Value:     v,
+			Threshold: threshold,
+			Percent:   percent,
+			Message:   label(r, group) + fmt.Sprintf(format, a...),
+			Item:      item,
+		})
+	}
+
+	if r.Above != nil && v > *r.Above && (!s.hasLast || s.last <= *r.Above) {
+		newAlert(Above, *r.Above, 0, " 升至 %v，高于 %v", v, *r.Above)
+	}
+	if r.Below != nil && v < *r.Below && (!s.hasLast || s.last >= *r.Below) {
+		newAlert(Below, *r.Below, 0, " 降至 %v，低于 %v", v, *r.Below)
+	}
+	if r.ChangePercent > 0 {
+		// 移出窗口外的数值，与窗口内的最低、最高值比较
+		i := 0
+		for i < len(s.samples) && now.Sub(s.samples[i].t) > r.window {
+			i++
+		}
+		s.samples = s.samples[i:]
+		if pct, base := s.maxChange(v); math.Abs(pct) >= r.ChangePercent {
+			newAlert(Change, r.ChangePercent, pct, " %v 内由 %v 变为 %v，变化 %+.2f%%", r.window, base, v, pct)
+		}
+		s.samples = append(s.samples, sample{t: now, v: v})
+	}
+	s.last, s.hasLast = v, true
+	return alerts
+}
+
+// 返回 v 相对窗口内最低、最高值中变化幅度较大者的百分比及其基准值
+func (self *series) maxChange(v float64) (pct, base float64) {
+	for _, s := range self.samples {
+		if s.v == 0 {
+			continue
+		}
+		if p := (v - s.v) / math.Abs(s.v) * 100; math.Abs(p) > math.Abs(pct) {
+			pct, base = p, s.v
+		}
+	}
+	return
+}
+
+// 规则的通知方式，须持有锁
+func (self *Engine) ruleNotifiers(r *Rule) []Notifier {
+	if len(r.Notify) == 0 {
+		return []Notifier{logNotifier{}}
+	}
+	list := make([]Notifier, 0, len(r.Notify))
+	for _, n := range r.Notify {
+		list = append(list, self.notifiers[n])
+	}
+	return list
+}
+
+// 发送协程
+func (self *Engine) deliver() {
+	for d := range self.queue {
+		for _, n := range d.notifiers {
+			if err := n.Notify(d.alert); err != nil {
+				logs.Log.Error(" *     [告警：%s]   通知失败：%v", d.alert.Rule, err)
+			}
+		}
+	}
+}
+
+func label(r *Rule, group string) string {
+	s := "[" + r.Spider + "] " + r.Field
+	if group != "" {
+		s += "(" + group + ")"
+	}
+	return s
+}
+
+// 解析数值，允许千分位逗号、百分号及首尾空白
+func parseNumber(s string) (float64, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	return strconv.ParseFloat(strings.Replace(s, ",", "", -1), 64)
+}
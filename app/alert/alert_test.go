@@ -0,0 +1,68 @@
+package alert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	above := 2000.0
+	e := New()
+	err := e.Set(&Config{Rules: []*Rule{
+		{Name: "high", Spider: "gold", Field: "price", GroupBy: "curr", Above: &above, Cooldown: "1h"},
+		{Name: "move", Spider: "gold", ItemRule: "xau", Field: "price", ChangePercent: 5, Window: "10m", Cooldown: "0s"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Watches("other") || !e.Watches("gold") {
+		t.Fatal("Watches() mismatch")
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	check := func(min int, rule, price string) []string {
+		var kinds []string
+		item := map[string]string{"price": price, "curr": "USD"}
+		for _, a := range e.Check("gold", rule, item, now.Add(time.Duration(min)*time.Minute)) {
+			kinds = append(kinds, a.Rule+":"+a.Kind)
+		}
+		return kinds
+	}
+
+	for i, c := range []struct {
+		min   int
+		rule  string
+		price string
+		want  string
+	}{
+		{0, "xau", "1,990.5", ""},
+		{1, "xau", "2010", "high:above"},
+		{2, "xau", "1990", ""},
+		{3, "xau", "2010", ""}, // 冷却中
+		{4, "other", "n/a", ""},
+		{5, "xau", "2120", "move:change"},
+		{85, "xau", "2130", ""}, // 窗口外的数值已移除
+		{90, "xau", "1990", "move:change"},
+		{96, "xau", "2010", "high:above"},
+	} {
+		got := ""
+		for _, k := range check(c.min, c.rule, c.price) {
+			got += k
+		}
+		if got != c.want {
+			t.Errorf("#%d Check(%s) = %q, want %q", i, c.price, got, c.want)
+		}
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	for _, r := range []*Rule{
+		{Name: "a", Spider: "gold", Field: "price"},
+		{Name: "b", Spider: "gold", Field: "price", ChangePercent: 1},
+		{Name: "c", Spider: "gold", Field: "price", ChangePercent: 1, Window: "1h", Notify: []string{"nope"}},
+	} {
+		if err := New().Set(&Config{Rules: []*Rule{r}}); err == nil {
+			t.Errorf("Set(%s) should fail", r.Name)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+	"skynet-service/app/notify/mail"
+	"skynet-service/app/notify/webhook"
+)
+
+type (
+	// 告警的通知方式
+	Notifier interface {
+		Notify(*Alert) error
+	}
+	// 根据配置创建通知方式，conf 为该通知方式的完整 JSON 配置
+	NotifierFunc func(conf json.RawMessage) (Notifier, error)
+)
+
+// 已注册的通知方式，[type]构造函数
+var notifierFuncs = map[string]NotifierFunc{
+	"log":     newLogNotifier,
+	"smtp":    newMailNotifier,
+	"webhook": newWebhookNotifier,
+}
+
+// 注册通知方式，重复注册时覆盖
+func Register(typ string, f NotifierFunc) {
+	notifierFuncs[typ] = f
+}
+
+// 已注册的通知方式类型
+func Types() []string {
+	list := make([]string, 0, len(notifierFuncs))
+	for typ := range notifierFuncs {
+		list = append(list, typ)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// 按配置创建全部通知方式，未配置名为 log 的通知方式时自动添加
+func newNotifiers(confs map[string]json.RawMessage) (map[string]Notifier, error) {
+	notifiers := map[string]Notifier{"log": logNotifier{}}
+	for name, conf := range confs {
+		var head struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(conf, &head); err != nil {
+			return nil, fmt.Errorf("notifier %q: %v", name, err)
+		}
+		f, ok := notifierFuncs[head.Type]
+		if !ok {
+			return nil, fmt.Errorf("notifier %q: unknown type %q, must be one of %s", name, head.Type, strings.Join(Types(), ", "))
+		}
+		n, err := f(conf)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %v", name, err)
+		}
+		notifiers[name] = n
+	}
+	return notifiers, nil
+}
+
+// 写入日志
+type logNotifier struct{}
+
+func newLogNotifier(json.RawMessage) (Notifier, error) {
+	return logNotifier{}, nil
+}
+
+func (logNotifier) Notify(a *Alert) error {
+	logs.Log.Warning(" *     [告警：%s]   %s", a.Rule, a.Message)
+	return nil
+}
+
+// 通过 SMTP 发送邮件，服务器使用 [smtp] 段的配置
+type mailNotifier struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"` // 标题前缀，为空时为"[告警]"
+}
+
+func newMailNotifier(conf json.RawMessage) (Notifier, error) {
+	n := &mailNotifier{}
+	if err := json.Unmarshal(conf, n); err != nil {
+		return nil, err
+	}
+	if len(n.To) == 0 {
+		return nil, fmt.Errorf("recipients (to) can not be empty")
+	}
+	if n.Subject == "" {
+		n.Subject = "[告警]"
+	}
+	return n, nil
+}
+
+func (self *mailNotifier) Notify(a *Alert) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s\n\n规则：%s\n时间：%s\n", a.Message, a.Rule, a.Time.Format("2006-01-02 15:04:05"))
+	keys := make([]string, 0, len(a.Item))
+	for k := range a.Item {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	text.WriteString("\n数据：\n")
+	for _, k := range keys {
+		fmt.Fprintf(&text, "  %s: %s\n", k, a.Item[k])
+	}
+
+	conf := config.Current()
+	return mail.Send(mail.Server{
+		Addr:     conf.SmtpHost,
+		Username: conf.SmtpUsername,
+		Password: conf.SmtpPassword,
+		StartTLS: conf.SmtpStartTLS,
+	}, &mail.Message{
+		From:    conf.SmtpFrom,
+		To:      self.To,
+		Subject: self.Subject + " " + a.Message,
+		Text:    text.String(),
+		Date:    a.Time,
+	})
+}
+
+// 推送至 config.WEBHOOK_FILE 中配置的 Webhook，事件类型为 alert
+type webhookNotifier struct {
+	Hook string `json:"hook"` // Webhook 名称
+}
+
+func newWebhookNotifier(conf json.RawMessage) (Notifier, error) {
+	n := &webhookNotifier{}
+	if err := json.Unmarshal(conf, n); err != nil {
+		return nil, err
+	}
+	if !webhook.Exists(n.Hook) {
+		return nil, fmt.Errorf("webhook not found: %q", n.Hook)
+	}
+	return n, nil
+}
+
+func (self *webhookNotifier) Notify(a *Alert) error {
+	return webhook.PublishTo(self.Hook, webhook.Alert, a)
+}
@@ -3,6 +3,7 @@
 package app
 
 import (
+	"errors"
 	"io"
 	"reflect"
 	"strconv"
@@ -14,9 +15,11 @@ import (
 	"skynet-service/app/crawler"
 	"skynet-service/app/distribute"
 	"skynet-service/app/logs"
+	"skynet-service/app/notify/webhook"
 	"skynet-service/app/pipeline"
 	"skynet-service/app/pipeline/collector"
 	"skynet-service/app/runtime/cache"
+	"skynet-service/app/runtime/runs"
 	"skynet-service/app/runtime/status"
 	"skynet-service/app/scheduler"
 	"skynet-service/app/spider"
@@ -31,7 +34,9 @@ type (
 		ReInit(mode int, port int, master string, w ...io.Writer) App 	// 切换运行模式并重设log打印目标
 		GetAppConf(k ...string) interface{}                           	// 获取全局参数
 		SetAppConf(k string, v interface{}) App                       	// 设置全局参数（client模式下不调用该方法）
+		ReloadAppConf(conf *cache.AppConf) App                        	// 替换为重新加载配置得到的全局参数
 		SpiderPrepare(original []*spider.Spider) App                  	// 须在设置全局运行参数后Run()前调用（client模式下不调用该方法）
+		TryPrepare(original []*spider.Spider) error                   	// 占用任务并调用SpiderPrepare()，已有任务时返回ErrRunning，成功后须调用Run()
 		Run()                                                         	// 阻塞式运行直至任务完成（须在所有应当配置项配置完成后调用）
 		Stop()                                                        	// Offline 模式下中途终止任务（对外为阻塞式运行直至当前任务终止）
 		IsRunning() bool                                              	// 检查任务是否正在运行
@@ -44,6 +49,7 @@ type (
 		GetSpiderQueue() crawler.SpiderQueue                          	// 获取蜘蛛队列接口实例
 		GetOutputLib() []string                                       	// 获取全部输出方式
 		GetTaskJar() *distribute.TaskJar                              	// 返回任务库
+		GetReports() []*cache.Report                                  	// 获取最近一次任务的各蜘蛛报告
 		distribute.Distributer                                        	// 实现分布式接口
 	}
 	Logic struct {
@@ -54,8 +60,12 @@ type (
 		crawler.CrawlerPool                 							// 爬行回收池
 		teleport.Teleport                   							// socket长连接双工通信接口，json数据传输
 		sum                   [2]uint64     							// 执行计数
+		reports               []*cache.Report 							// 最近一次任务的各蜘蛛报告
+		recorder              *runs.Recorder  							// 当前任务的运行记录
+		next                  *cache.AppConf 							// 任务运行期间修改的全局参数，下一次任务开始前生效
 		takeTime              time.Duration 							// 执行计时
 		status                int           							// 运行状态
+		claimed               bool          							// 已由TryPrepare()占用，直至Run()返回
 		finish                chan bool
 		finishOnce            sync.Once
 		canSocketLog          bool
@@ -66,6 +76,9 @@ type (
 // 全局唯一的核心接口实例
 var LogicApp = New ()
 
+// 已有任务在运行，或已被其他调用方占用
+var ErrRunning = errors.New("task is running")
+
 func New () App {
 	return newLogic()
 }
@@ -100,22 +113,25 @@ func (self *Logic) LogGoOn() App {
 	return self
 }
 
-// 获取全局参数
+// 获取全局参数，不指定参数名时返回全部参数的副本
 func (self *Logic) GetAppConf(k ...string) interface{} {
 	defer func() {
 		if err := recover(); err != nil {
 			logs.Log.Error("%v", err)
 		}
 	}()
+	self.RWMutex.RLock()
+	defer self.RWMutex.RUnlock()
 	if len(k) == 0 {
-		return self.AppConf
+		conf := *self.AppConf
+		return &conf
 	}
 	key := strings.Title(k[0])
 	acv := reflect.ValueOf(self.AppConf).Elem()
 	return acv.FieldByName(key).Interface()
 }
 
-// 设置全局参数
+// 设置全局参数，任务运行期间修改的参数在下一次任务开始前生效
 func (self *Logic) SetAppConf(k string, v interface{}) App {
 	defer func() {
 		if err := recover(); err != nil {
@@ -127,7 +143,9 @@ func (self *Logic) SetAppConf(k string, v interface{}) App {
 	} else if k == "DockerCap" && v.(int) < 1 {
 		v = int(1)
 	}
-	acv := reflect.ValueOf(self.AppConf).Elem()
+	self.RWMutex.Lock()
+	defer self.RWMutex.Unlock()
+	acv := reflect.ValueOf(self.writableConf()).Elem()
 	key := strings.Title(k)
 	if acv.FieldByName(key).CanSet() {
 		acv.FieldByName(key).Set(reflect.ValueOf(v))
@@ -136,6 +154,42 @@ func (self *Logic) SetAppConf(k string, v interface{}) App {
 	return self
 }
 
+// 替换为重新加载配置得到的全局参数，运行方式、节点角色及分布式地址保持不变；
+// 正在运行的任务不受影响，下一次任务开始前生效
+func (self *Logic) ReloadAppConf(conf *cache.AppConf) App {
+	self.RWMutex.Lock()
+	defer self.RWMutex.Unlock()
+	next := *conf
+	next.RunType = self.AppConf.RunType
+	next.Mode = self.AppConf.Mode
+	next.Port = self.AppConf.Port
+	next.Master = self.AppConf.Master
+	*self.writableConf() = next
+	return self
+}
+
+// 可写入的全局参数：任务运行期间为下一次任务的副本，须在持有锁时调用
+func (self *Logic) writableConf() *cache.AppConf {
+	if self.status == status.STOPPED {
+		return self.AppConf
+	}
+	if self.next == nil {
+		next := *self.AppConf
+		self.next = &next
+	}
+	return self.next
+}
+
+// 应用任务运行期间修改的全局参数，须在任务开始前调用
+func (self *Logic) applyNextConf() {
+	self.RWMutex.Lock()
+	defer self.RWMutex.Unlock()
+	if self.next != nil && self.status == status.STOPPED {
+		*self.AppConf = *self.next
+		self.next = nil
+	}
+}
+
 // 使用App前必须先进行Init初始化（SetLog()除外）
 func (self *Logic) Init( w ...io.Writer) App {
 	self.canSocketLog = false
@@ -203,6 +257,7 @@ func (self *Logic) ReInit(mode int, port int, master string, w ...io.Writer) App
 // 已被显式赋值过的spider将不再重新分配Keyin
 // client模式下不调用该方法
 func (self *Logic) SpiderPrepare(original []*spider.Spider) App {
+	self.applyNextConf()
 	self.SpiderQueue.Reset()
 	// 遍历任务
 	for _, sp := range original {
@@ -220,6 +275,20 @@ func (self *Logic) SpiderPrepare(original []*spider.Spider) App {
 	return self
 }
 
+// 占用任务并准备蜘蛛队列，供接口、定时任务等多个调用方共用同一App时使用；
+// 已有任务在运行或已被占用时返回 ErrRunning，成功后须调用 Run()，Run() 返回时释放占用
+func (self *Logic) TryPrepare(original []*spider.Spider) error {
+	self.RWMutex.Lock()
+	if self.claimed || self.status != status.STOPPED {
+		self.RWMutex.Unlock()
+		return ErrRunning
+	}
+	self.claimed = true
+	self.RWMutex.Unlock()
+	self.SpiderPrepare(original)
+	return nil
+}
+
 // 获取全部输出方式
 func (self *Logic) GetOutputLib() []string {
 	return collector.DataOutputLib
@@ -245,6 +314,15 @@ func (self *Logic) GetTaskJar() *distribute.TaskJar {
 	return self.TaskJar
 }
 
+// 获取最近一次任务的各蜘蛛报告
+func (self *Logic) GetReports() []*cache.Report {
+	self.RWMutex.RLock()
+	defer self.RWMutex.RUnlock()
+	reports := make([]*cache.Report, len(self.reports))
+	copy(reports, self.reports)
+	return reports
+}
+
 // 服务器客户端模式下返回节点数
 func (self *Logic) CountNodes() int {
 	return self.Teleport.CountNodes()
@@ -257,8 +335,14 @@ func (self *Logic) GetSpiderQueue() crawler.SpiderQueue {
 
 // 运行任务
 func (self *Logic) Run() {
+	defer func() {
+		self.RWMutex.Lock()
+		self.claimed = false
+		self.RWMutex.Unlock()
+	}()
 	// 确保开启报告
 	self.LogGoOn()
+	self.applyNextConf()
 	if self.AppConf.Mode != status.CLIENT && self.SpiderQueue.Len() == 0 {
 		logs.Log.Warning("任务列表不能为空~")
 		self.LogRest()
@@ -268,6 +352,7 @@ func (self *Logic) Run() {
 	self.finishOnce = sync.Once{}
 	// 重置计数
 	self.sum[0], self.sum[1] = 0, 0
+	self.resetReports()
 	// 重置计时
 	self.takeTime = 0
 	// 设置状态
@@ -432,6 +517,7 @@ func (self *Logic) client() {
 
 		// 重置计数
 		self.sum[0], self.sum[1] = 0, 0
+		self.resetReports()
 		// 重置计时
 		self.takeTime = 0
 
@@ -513,6 +599,13 @@ func (self *Logic) exec() {
 	// 开始计时
 	cache.StartTime = time.Now()
 
+	// 开始记录本次运行
+	spiders := make([]runs.Spider, 0, count)
+	for _, sp := range self.SpiderQueue.GetAll() {
+		spiders = append(spiders, runs.Spider{Name: sp.GetName(), Keyin: sp.GetKeyin()})
+	}
+	self.recorder = runs.Begin(spiders)
+
 	// 根据模式选择合理的并发
 	if self.AppConf.Mode == status.OFFLINE {
 		// 可控制执行状态
@@ -551,6 +644,9 @@ func (self *Logic) goRun(count int) {
 	// 监控结束任务
 	for ii := 0; ii < i; ii++ {
 		s := <-cache.ReportChan
+		self.RWMutex.Lock()
+		self.reports = append(self.reports, s)
+		self.RWMutex.Unlock()
 		if (s.DataNum == 0) && (s.FileNum == 0) {
 			logs.Log.App(" *     [任务小计：%s | KEYIN：%s]   无采集结果，用时 %v！", s.SpiderName, s.Keyin, s.Time)
 			continue
@@ -601,6 +697,16 @@ func (self *Logic) goRun(count int) {
 	logs.Log.Informational(" * ")
 	logs.Log.Informational(` *********************************************************************************************************************************** `)
 
+	// 保存运行记录
+	var runID string
+	if run, err := self.recorder.Finish(self.GetReports(), self.Status() == status.STOP); err != nil {
+		logs.Log.Error(" *     保存运行记录失败：%v", err)
+	} else {
+		runID = run.ID
+		logs.Log.Informational(" *     运行记录已保存：%s", run.ID)
+	}
+	webhook.PublishRun(runID, cache.StartTime, self.GetReports(), self.Status() == status.STOP)
+
 	// 单机模式并发运行，需要标记任务结束
 	if self.AppConf.Mode == status.OFFLINE {
 		self.LogRest()
@@ -608,6 +714,13 @@ func (self *Logic) goRun(count int) {
 	}
 }
 
+// 清空报告
+func (self *Logic) resetReports() {
+	self.RWMutex.Lock()
+	self.reports = nil
+	self.RWMutex.Unlock()
+}
+
 // 客户端向服务端反馈日志
 func (self *Logic) socketLog() {
 	for self.canSocketLog {
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"testing"
+
+	"skynet-service/app/runtime/cache"
+	"skynet-service/app/runtime/status"
+)
+
+func TestReloadAppConf(t *testing.T) {
+	self := newLogic()
+	self.AppConf = &cache.AppConf{Mode: status.OFFLINE, ThreadNum: 1, OutType: "csv"}
+
+	// 运行期间重新加载的参数不影响当前任务
+	self.setStatus(status.RUN)
+	self.ReloadAppConf(&cache.AppConf{Mode: status.SERVER, ThreadNum: 8, OutType: "mysql"})
+	self.SetAppConf("Pausetime", int64(300))
+	if self.AppConf.ThreadNum != 1 || self.AppConf.OutType != "csv" || self.AppConf.Pausetime != 0 {
+		t.Fatalf("conf changed during run: %+v", *self.AppConf)
+	}
+	self.applyNextConf()
+	if self.AppConf.ThreadNum != 1 {
+		t.Fatalf("conf applied during run: %+v", *self.AppConf)
+	}
+
+	self.setStatus(status.STOPPED)
+	self.applyNextConf()
+	want := cache.AppConf{Mode: status.OFFLINE, ThreadNum: 8, OutType: "mysql", Pausetime: 300}
+	if *self.AppConf != want {
+		t.Fatalf("AppConf = %+v, want %+v", *self.AppConf, want)
+	}
+
+	// 任务之间直接生效
+	self.SetAppConf("ThreadNum", 2)
+	if self.GetAppConf("ThreadNum").(int) != 2 || self.next != nil {
+		t.Fatalf("AppConf = %+v", *self.AppConf)
+	}
+}
+
+func TestTryPrepare(t *testing.T) {
+	self := newLogic()
+	self.AppConf = &cache.AppConf{Mode: status.OFFLINE}
+
+	if err := self.TryPrepare(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := self.TryPrepare(nil); err != ErrRunning {
+		t.Fatalf("second TryPrepare() = %v", err)
+	}
+	// 蜘蛛队列为空时 Run() 立即返回，同样释放占用
+	self.Run()
+	if err := self.TryPrepare(nil); err != nil {
+		t.Fatalf("TryPrepare() after Run() = %v", err)
+	}
+	self.Run()
+
+	self.setStatus(status.RUN)
+	if err := self.TryPrepare(nil); err != ErrRunning {
+		t.Fatalf("TryPrepare() while running = %v", err)
+	}
+}
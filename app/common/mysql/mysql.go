@@ -3,6 +3,7 @@ package mysql
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 
@@ -22,9 +23,13 @@ type MyTable struct {
 	args             []interface{} // 数据
 	sqlCode          string
 	customPrimaryKey bool
-	size             int // 内容大小的近似值
+	uniqueKey        []string // 唯一索引的列，设置后插入改为更新已有行
+	size             int      // 内容大小的近似值
 }
 
+// 唯一索引名称
+const uniqueKeyName = "skynet_unique"
+
 
 var (
 	err                error
@@ -38,6 +43,11 @@ func DB() (*sql.DB, error) {
 	return db, err
 }
 
+// 替换数据库连接，供其他包的测试使用模拟的数据库
+func SetDB(d *sql.DB) {
+	db, err = d, nil
+}
+
 func Refresh() {
 	once.Do(func() {
 		logs.Log.Debug("mysql" + config.MYSQL_CONN_STR+"/"+config.DB_NAME+"?charset=utf8")
@@ -63,6 +73,7 @@ func (m *MyTable) Clone() *MyTable {
 		tableName:        m.tableName,
 		columnNames:      m.columnNames,
 		customPrimaryKey: m.customPrimaryKey,
+		uniqueKey:        m.uniqueKey,
 	}
 }
 
@@ -90,6 +101,15 @@ func (self *MyTable) CustomPrimaryKey(primaryKeyCode string) *MyTable {
 	return self
 }
 
+//设置唯一索引的列（可选），插入时遇到重复的行则更新其余各列
+func (self *MyTable) SetUniqueKey(names ...string) *MyTable {
+	self.uniqueKey = nil
+	for _, name := range names {
+		self.uniqueKey = append(self.uniqueKey, wrapSqlKey(name))
+	}
+	return self
+}
+
 //生成"创建表单"的语句，执行前须保证SetTableName()、AddColumn()已经执行
 func (self *MyTable) Create() error {
 	if len(self.columnNames) == 0 {
@@ -102,6 +122,9 @@ func (self *MyTable) Create() error {
 	for _, title := range self.columnNames {
 		self.sqlCode += title[0] + ` ` + title[1] + `,`
 	}
+	if len(self.uniqueKey) > 0 {
+		self.sqlCode += `UNIQUE KEY ` + uniqueKeyName + ` (` + strings.Join(self.uniqueKey, `,`) + `),`
+	}
 	self.sqlCode = self.sqlCode[:len(self.sqlCode)-1] + `) DEFAULT CHARSET=utf8;`
 
 	logs.Log.Debug("Sql code:%s", self.sqlCode)
@@ -115,8 +138,229 @@ func (self *MyTable) Create() error {
 	// debug
 	// println("Create():", self.sqlCode)
 
-	_, err := db.Exec(self.sqlCode)
-	return err
+	if _, err := db.Exec(self.sqlCode); err != nil {
+		return err
+	}
+	if err := self.syncColumns(); err != nil {
+		return err
+	}
+	return self.ensureUniqueKey()
+}
+
+//列名、列的类型(同 information_schema 的 DATA_TYPE)及唯一索引是否与另一张表相同
+func (self *MyTable) SameColumns(other *MyTable) bool {
+	if len(self.columnNames) != len(other.columnNames) || len(self.uniqueKey) != len(other.uniqueKey) {
+		return false
+	}
+	for i, v := range self.columnNames {
+		if v[0] != other.columnNames[i][0] || !sameDataType(dataType(v[1]), dataType(other.columnNames[i][1])) {
+			return false
+		}
+	}
+	for i, v := range self.uniqueKey {
+		if v != other.uniqueKey[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//对照 information_schema 同步已存在的表：按声明顺序补建缺少的列，
+//配置允许时删除已不再声明的列(自增主键 id 除外)；
+//已有列的类型与声明不同时只记录警告，不自动修改，以免转换时丢失数据
+func (self *MyTable) syncColumns() error {
+	order, types, err := self.columnTypes()
+	if err != nil {
+		return err
+	}
+	for _, msg := range self.typeMismatches(types) {
+		logs.Log.Warning(" *     [表结构] %s 的%s，未修改", self.tableName, msg)
+	}
+
+	var alter []string
+	prev := ""
+	if !self.customPrimaryKey {
+		prev = "`id`"
+	}
+	declared := map[string]bool{}
+	for _, col := range self.columnNames {
+		declared[col[0]] = true
+		if _, ok := types[col[0]]; !ok {
+			pos := ` FIRST`
+			if prev != "" {
+				pos = ` AFTER ` + prev
+			}
+			alter = append(alter, `ADD COLUMN `+col[0]+` `+col[1]+pos)
+		}
+		prev = col[0]
+	}
+	for _, name := range order {
+		if declared[name] || (!self.customPrimaryKey && name == "`id`") {
+			continue
+		}
+		if config.Current().MysqlDropColumns {
+			alter = append(alter, `DROP COLUMN `+name)
+		} else {
+			logs.Log.Warning(" *     [表结构] %s 的列 %s 已不在规则中，未删除", self.tableName, name)
+		}
+	}
+	if len(alter) == 0 {
+		return nil
+	}
+
+	code := `ALTER TABLE ` + self.tableName + ` ` + strings.Join(alter, `, `)
+	logs.Log.Debug("Sql code:%s", code)
+	if _, err = db.Exec(code); err != nil {
+		return fmt.Errorf("migrate %s: %v", self.tableName, err)
+	}
+	logs.Log.Informational(" *     [表结构] %s 已更新：%s", self.tableName, strings.Join(alter, "; "))
+	return nil
+}
+
+//为设置唯一索引前已存在的表补建索引，表中已有重复行时返回错误；
+//此前建为 TEXT 等无法直接索引的列先改为声明的类型，有值超出 VARCHAR 长度时返回错误而不截断
+func (self *MyTable) ensureUniqueKey() error {
+	if len(self.uniqueKey) == 0 {
+		return nil
+	}
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`,
+		strings.Trim(self.tableName, "`"), uniqueKeyName).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, types, err := self.columnTypes()
+	if err != nil {
+		return err
+	}
+	var alter []string
+	for _, key := range self.uniqueKey {
+		def := self.columnDef(key)
+		if def == "" || !textTypes[types[key]] || textTypes[dataType(def)] {
+			continue
+		}
+		if size := varcharSize(def); size > 0 {
+			if err = db.QueryRow(`SELECT COUNT(*) FROM ` + self.tableName + ` WHERE CHAR_LENGTH(` + key + `) > ?`, size).Scan(&n); err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("add unique key to %s: %d rows of %s are longer than %s", self.tableName, n, key, def)
+			}
+		}
+		alter = append(alter, `MODIFY COLUMN `+key+` `+def)
+	}
+	alter = append(alter, `ADD UNIQUE KEY `+uniqueKeyName+` (`+strings.Join(self.uniqueKey, `,`)+`)`)
+	code := `ALTER TABLE ` + self.tableName + ` ` + strings.Join(alter, `, `)
+	logs.Log.Debug("Sql code:%s", code)
+	if _, err = db.Exec(code); err != nil {
+		return fmt.Errorf("add unique key to %s: %v", self.tableName, err)
+	}
+	logs.Log.Informational(" *     [表结构] %s 已更新：%s", self.tableName, strings.Join(alter, "; "))
+	return nil
+}
+
+//无法直接建立索引的列类型
+var textTypes = map[string]bool{
+	"tinytext": true, "text": true, "mediumtext": true, "longtext": true,
+	"tinyblob": true, "blob": true, "mediumblob": true, "longblob": true,
+}
+
+//从 information_schema 读取已存在的表的列，按列的顺序返回列名，及列名对应的 DATA_TYPE
+func (self *MyTable) columnTypes() ([]string, map[string]string, error) {
+	rows, err := db.Query(`SELECT COLUMN_NAME, DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`,
+		strings.Trim(self.tableName, "`"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var order []string
+	types := map[string]string{}
+	for rows.Next() {
+		var name, typ string
+		if err = rows.Scan(&name, &typ); err != nil {
+			return nil, nil, err
+		}
+		order = append(order, wrapSqlKey(name))
+		types[wrapSqlKey(name)] = strings.ToLower(typ)
+	}
+	return order, types, rows.Err()
+}
+
+//已存在的列中类型与声明不同的列，按声明顺序返回说明；
+//唯一索引的列由 ensureUniqueKey() 处理
+func (self *MyTable) typeMismatches(types map[string]string) []string {
+	var list []string
+	for _, col := range self.columnNames {
+		actual, ok := types[col[0]]
+		if !ok || sameDataType(dataType(col[1]), actual) {
+			continue
+		}
+		if textTypes[actual] && self.isUniqueKey(col[0]) {
+			continue
+		}
+		list = append(list, fmt.Sprintf("列 %s 类型为 %s，规则声明为 %s", col[0], actual, col[1]))
+	}
+	return list
+}
+
+func (self *MyTable) isUniqueKey(name string) bool {
+	for _, key := range self.uniqueKey {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+//类型名的别名，information_schema 中均记为后者；MariaDB 的 JSON 即 LONGTEXT
+var dataTypeAlias = map[string]string{
+	"integer": "int",
+	"bool":    "tinyint",
+	"boolean": "tinyint",
+	"dec":     "decimal",
+	"numeric": "decimal",
+	"real":    "double",
+	"json":    "longtext",
+}
+
+//两个 DATA_TYPE 是否相同
+func sameDataType(a, b string) bool {
+	if alias, ok := dataTypeAlias[a]; ok {
+		a = alias
+	}
+	if alias, ok := dataTypeAlias[b]; ok {
+		b = alias
+	}
+	return a == b
+}
+
+//声明的列定义，未声明时为空
+func (self *MyTable) columnDef(name string) string {
+	for _, col := range self.columnNames {
+		if col[0] == name {
+			return col[1]
+		}
+	}
+	return ""
+}
+
+//列定义中的类型名，同 information_schema 的 DATA_TYPE，如 "VARCHAR(255) NOT NULL" 为 varchar
+func dataType(def string) string {
+	def = strings.ToLower(strings.TrimSpace(def))
+	if i := strings.IndexAny(def, "( "); i >= 0 {
+		def = def[:i]
+	}
+	return def
+}
+
+//VARCHAR 列定义的长度，其他类型为 0
+func varcharSize(def string) int {
+	if dataType(def) != "varchar" {
+		return 0
+	}
+	var n int
+	fmt.Sscanf(def[strings.Index(def, "(")+1:], "%d", &n)
+	return n
 }
 
 //清空表单，执行前须保证SetTableName()已经执行
@@ -132,23 +376,30 @@ func (self *MyTable) Truncate() error {
 }
 
 //设置插入的1行数据
-func (self *MyTable) addRow(value []string) *MyTable {
-	for i, count := 0, len(value); i < count; i++ {
-		self.args = append(self.args, value[i])
-	}
+func (self *MyTable) addRow(value []interface{}) *MyTable {
+	self.args = append(self.args, value...)
 	self.rowsCount++
 	return self
 }
 
 //智能插入数据，每次1行
 func (self *MyTable) AutoInsert(value []string) *MyTable {
+	row := make([]interface{}, len(value))
+	for i, v := range value {
+		row[i] = v
+	}
+	return self.AutoInsertValues(row)
+}
+
+//智能插入数据，每次1行，值可为 string、[]byte、数值、bool、time.Time 或 nil(NULL)
+func (self *MyTable) AutoInsertValues(value []interface{}) *MyTable {
 	if self.rowsCount > 100 {
 		util.CheckErr(self.FlushInsert())
-		return self.AutoInsert(value)
+		return self.AutoInsertValues(value)
 	}
 	var nsize int
 	for _, v := range value {
-		nsize += len(v)
+		nsize += valueSize(v)
 	}
 	if nsize > max_allowed_packet {
 		logs.Log.Error("%v", "packet for query is too large. Try adjusting the 'maxallowedpacket'variable in the 'config.ini'")
@@ -157,11 +408,24 @@ func (self *MyTable) AutoInsert(value []string) *MyTable {
 	self.size += nsize
 	if self.size > max_allowed_packet {
 		util.CheckErr(self.FlushInsert())
-		return self.AutoInsert(value)
+		return self.AutoInsertValues(value)
 	}
 	return self.addRow(value)
 }
 
+// 值在插入语句中所占大小的近似值
+func valueSize(v interface{}) int {
+	switch x := v.(type) {
+	case string:
+		return len(x)
+	case []byte:
+		return len(x)
+	case nil:
+		return 4
+	}
+	return 24
+}
+
 //向sqlCode添加"插入数据"的语句，执行前须保证Create()、AutoInsert()已经执行
 func (self *MyTable) FlushInsert() error {
 	if self.rowsCount == 0 {
@@ -182,7 +446,11 @@ func (self *MyTable) FlushInsert() error {
 	self.sqlCode = self.sqlCode[:len(self.sqlCode)-1] + `) VALUES `
 
 	blank := ",(" + strings.Repeat(",?", colCount)[1:] + ")"
-	self.sqlCode += strings.Repeat(blank, self.rowsCount)[1:] + `;`
+	self.sqlCode += strings.Repeat(blank, self.rowsCount)[1:]
+	if len(self.uniqueKey) > 0 {
+		self.sqlCode += ` ON DUPLICATE KEY UPDATE ` + self.updateCode()
+	}
+	self.sqlCode += `;`
 
 
 	defer func() {
@@ -203,6 +471,27 @@ func (self *MyTable) FlushInsert() error {
 	return err
 }
 
+//唯一索引以外各列的更新语句，全部列均为索引时保持原值
+func (self *MyTable) updateCode() string {
+	var list []string
+	for _, v := range self.columnNames {
+		isKey := false
+		for _, k := range self.uniqueKey {
+			if k == v[0] {
+				isKey = true
+				break
+			}
+		}
+		if !isKey {
+			list = append(list, v[0]+`=VALUES(`+v[0]+`)`)
+		}
+	}
+	if len(list) == 0 {
+		return self.uniqueKey[0] + `=` + self.uniqueKey[0]
+	}
+	return strings.Join(list, `,`)
+}
+
 // 获取全部数据
 func (self *MyTable) SelectAll() (*sql.Rows, error) {
 	if self.tableName == "" {
@@ -0,0 +1,152 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+
+	"skynet-service/app/common/mysql/mysqltest"
+)
+
+func TestEnsureUniqueKeyFromText(t *testing.T) {
+	// 早先未设置唯一索引时建成的表，字符串字段均为 MEDIUMTEXT
+	f := useFakeDB(t, map[string][][2]string{
+		"gold": {{"id", "int"}, {"title", "mediumtext"}, {"price", "mediumtext"}},
+	})
+	table := New().SetTableName("gold").
+		AddColumn("title VARCHAR(255)", "price MEDIUMTEXT").
+		SetUniqueKey("title")
+	if err := table.Create(); err != nil {
+		t.Fatal(err)
+	}
+	want := "ALTER TABLE `gold` MODIFY COLUMN `title` VARCHAR(255), ADD UNIQUE KEY skynet_unique (`title`)"
+	if got := f.alters(); len(got) != 1 || got[0] != want {
+		t.Fatalf("alters = %q, want %q", got, want)
+	}
+
+	// 已有超出长度的值时不截断，返回错误
+	f.Reset()
+	f.indexed, f.longValues = false, 2
+	if err := table.Create(); err == nil || !strings.Contains(err.Error(), "2 rows of `title`") {
+		t.Fatalf("Create() = %v", err)
+	}
+	if got := f.alters(); len(got) != 0 {
+		t.Fatalf("alters = %q", got)
+	}
+
+	// 已有索引时不再修改
+	f.Reset()
+	f.indexed, f.longValues = true, 0
+	if err := table.Create(); err != nil || len(f.alters()) != 0 {
+		t.Fatalf("Create() = %v, alters = %q", err, f.alters())
+	}
+}
+
+func TestDataType(t *testing.T) {
+	for def, want := range map[string]string{
+		"VARCHAR(255)":      "varchar",
+		"MEDIUMTEXT":        "mediumtext",
+		"BIGINT NOT NULL":   "bigint",
+		" TINYINT(1)":       "tinyint",
+		"DECIMAL(10,2)":     "decimal",
+		"DATETIME":          "datetime",
+		"INT(12) NOT NULL ": "int",
+	} {
+		if got := dataType(def); got != want {
+			t.Errorf("dataType(%q) = %q, want %q", def, got, want)
+		}
+	}
+	if n := varcharSize("VARCHAR(191) NOT NULL"); n != 191 {
+		t.Errorf("varcharSize() = %d", n)
+	}
+	if n := varcharSize("MEDIUMTEXT"); n != 0 {
+		t.Errorf("varcharSize(MEDIUMTEXT) = %d", n)
+	}
+}
+
+// 模拟已存在的表结构的数据库
+type fakeMysql struct {
+	*mysqltest.DB
+	tables     map[string][][2]string // 表名对应的列名及 DATA_TYPE
+	indexed    bool                   // 是否已有唯一索引
+	longValues int                    // 超出长度的行数
+}
+
+// 以模拟的数据库替换数据库连接，测试结束后恢复
+func useFakeDB(t *testing.T, tables map[string][][2]string) *fakeMysql {
+	f := &fakeMysql{DB: &mysqltest.DB{}, tables: tables}
+	f.DB.Query = f.query
+	old := db
+	t.Cleanup(func() { db = old })
+	db = f.Open()
+	return f
+}
+
+func (self *fakeMysql) alters() []string {
+	return self.ExecsWithPrefix("ALTER TABLE")
+}
+
+func (self *fakeMysql) query(q string, args []driver.Value) (*mysqltest.Rows, error) {
+	switch {
+	case strings.Contains(q, "information_schema.COLUMNS"):
+		rows := &mysqltest.Rows{Columns: []string{"COLUMN_NAME", "DATA_TYPE"}}
+		for _, c := range self.tables[args[0].(string)] {
+			rows.Rows = append(rows.Rows, []driver.Value{c[0], c[1]})
+		}
+		return rows, nil
+	case strings.Contains(q, "information_schema.STATISTICS"):
+		n := 0
+		if self.indexed {
+			n = 1
+		}
+		return &mysqltest.Rows{Columns: []string{"COUNT(*)"}, Rows: [][]driver.Value{{int64(n)}}}, nil
+	case strings.Contains(q, "CHAR_LENGTH"):
+		return &mysqltest.Rows{Columns: []string{"COUNT(*)"}, Rows: [][]driver.Value{{int64(self.longValues)}}}, nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", q)
+}
+
+func TestSyncColumns(t *testing.T) {
+	f := useFakeDB(t, map[string][][2]string{
+		"gold": {{"id", "int"}, {"title", "mediumtext"}, {"price", "mediumtext"}, {"flag", "tinyint"}, {"extra", "json"}},
+	})
+	table := New().SetTableName("gold").
+		AddColumn("title MEDIUMTEXT", "price BIGINT", "volume DOUBLE", "flag BOOL", "extra JSON")
+	if got := table.typeMismatches(map[string]string{"`title`": "mediumtext", "`price`": "mediumtext", "`flag`": "tinyint", "`extra`": "longtext"}); len(got) != 1 || got[0] != "列 `price` 类型为 mediumtext，规则声明为 BIGINT" {
+		t.Fatalf("typeMismatches() = %q", got)
+	}
+	if err := table.Create(); err != nil {
+		t.Fatal(err)
+	}
+	// 类型不同的列只记录警告
+	want := "ALTER TABLE `gold` ADD COLUMN `volume` DOUBLE AFTER `price`"
+	if got := f.alters(); len(got) != 1 || got[0] != want {
+		t.Fatalf("alters = %q, want %q", got, want)
+	}
+
+	// 唯一索引的 TEXT 列由 ensureUniqueKey() 修改，不计为类型不同
+	keyed := New().SetTableName("gold").AddColumn("title VARCHAR(255)").SetUniqueKey("title")
+	if got := keyed.typeMismatches(map[string]string{"`title`": "mediumtext"}); len(got) != 0 {
+		t.Fatalf("typeMismatches() = %q", got)
+	}
+}
+
+func TestSameColumns(t *testing.T) {
+	a := New().AddColumn("title MEDIUMTEXT", "price BIGINT")
+	for _, c := range []struct {
+		b    *MyTable
+		same bool
+	}{
+		{New().AddColumn("title MEDIUMTEXT", "price BIGINT"), true},
+		{New().AddColumn("title mediumtext", "price BIGINT NOT NULL"), true},
+		{New().AddColumn("title MEDIUMTEXT", "price DOUBLE"), false},
+		{New().AddColumn("title MEDIUMTEXT", "amount BIGINT"), false},
+		{New().AddColumn("title MEDIUMTEXT"), false},
+		{New().AddColumn("title MEDIUMTEXT", "price BIGINT").SetUniqueKey("title"), false},
+	} {
+		if got := a.SameColumns(c.b); got != c.same {
+			t.Errorf("SameColumns(%v) = %v", c.b.columnNames, got)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// 模拟 MySQL 的 database/sql 驱动，用于在不连接数据库的情况下测试读写 MySQL 的代码
+package mysqltest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 模拟的数据库：查询结果由 Query 给出，并按顺序记录执行过的语句
+type DB struct {
+	// 返回查询结果，为空时任何查询均返回错误
+	Query func(query string, args []driver.Value) (*Rows, error)
+	// 执行修改语句，为空时均执行成功
+	Exec func(query string, args []driver.Value) error
+
+	execs   []string
+	queries []string
+	sync.Mutex
+}
+
+// 查询结果
+type Rows struct {
+	Columns []string
+	Rows    [][]driver.Value
+}
+
+var (
+	dbs     = map[string]*DB{}
+	dbsLock sync.Mutex
+)
+
+func init() {
+	sql.Register("mysqltest", fakeDriver{})
+}
+
+// 打开连接至 self 的 *sql.DB
+func (self *DB) Open() *sql.DB {
+	dbsLock.Lock()
+	name := strconv.Itoa(len(dbs))
+	dbs[name] = self
+	dbsLock.Unlock()
+	db, _ := sql.Open("mysqltest", name) // 驱动已注册，不会出错
+	return db
+}
+
+// 执行过的修改语句，不含参数
+func (self *DB) Execs() []string {
+	self.Lock()
+	defer self.Unlock()
+	return append([]string(nil), self.execs...)
+}
+
+// 以 prefix 开头的已执行修改语句
+func (self *DB) ExecsWithPrefix(prefix string) []string {
+	var list []string
+	for _, code := range self.Execs() {
+		if strings.HasPrefix(code, prefix) {
+			list = append(list, code)
+		}
+	}
+	return list
+}
+
+// 执行过的查询，带参数时以空格分隔附于其后
+func (self *DB) Queries() []string {
+	self.Lock()
+	defer self.Unlock()
+	return append([]string(nil), self.queries...)
+}
+
+// 清空已记录的语句
+func (self *DB) Reset() {
+	self.Lock()
+	self.execs, self.queries = nil, nil
+	self.Unlock()
+}
+
+// 以字符串构造查询结果，与 MySQL 驱动一样以 []byte 返回各值
+func Strings(columns []string, rows ...[]string) *Rows {
+	r := &Rows{Columns: columns}
+	for _, row := range rows {
+		values := make([]driver.Value, len(row))
+		for i, v := range row {
+			values[i] = []byte(v)
+		}
+		r.Rows = append(r.Rows, values)
+	}
+	return r
+}
+
+// 语句中第一个以反引号括起的名称，如 "SHOW COLUMNS FROM `t`" 中的 t
+func TableName(query string) string {
+	i := strings.Index(query, "`")
+	if i < 0 {
+		return ""
+	}
+	query = query[i+1:]
+	if i = strings.Index(query, "`"); i < 0 {
+		return ""
+	}
+	return query[:i]
+}
+
+type (
+	fakeDriver struct{}
+	fakeConn   struct{ db *DB }
+	fakeStmt   struct {
+		db    *DB
+		query string
+	}
+	fakeRows struct{ *Rows }
+)
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	dbsLock.Lock()
+	defer dbsLock.Unlock()
+	db, ok := dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("mysqltest: unknown db %q", name)
+	}
+	return fakeConn{db}, nil
+}
+
+func (self fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{self.db, query}, nil
+}
+
+func (fakeConn) Close() error { return nil }
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("mysqltest: transactions not supported")
+}
+
+func (self *fakeStmt) Close() error  { return nil }
+func (self *fakeStmt) NumInput() int { return -1 }
+
+func (self *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	self.db.Lock()
+	self.db.execs = append(self.db.execs, self.query)
+	self.db.Unlock()
+	if self.db.Exec != nil {
+		if err := self.db.Exec(self.query, args); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (self *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	q := self.query
+	if len(args) > 0 {
+		q = fmt.Sprint(q, " ", args)
+	}
+	self.db.Lock()
+	self.db.queries = append(self.db.queries, q)
+	self.db.Unlock()
+	if self.db.Query == nil {
+		return nil, fmt.Errorf("mysqltest: unexpected query: %s", self.query)
+	}
+	rows, err := self.db.Query(self.query, args)
+	if err != nil {
+		return nil, err
+	}
+	// 复制一份，以便同一结果被多次返回
+	return &fakeRows{&Rows{Columns: rows.Columns, Rows: append([][]driver.Value(nil), rows.Rows...)}}, nil
+}
+
+func (self *fakeRows) Columns() []string { return self.Rows.Columns }
+func (self *fakeRows) Close() error      { return nil }
+func (self *fakeRows) Next(dest []driver.Value) error {
+	if len(self.Rows.Rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, self.Rows.Rows[0])
+	self.Rows.Rows = self.Rows.Rows[1:]
+	return nil
+}
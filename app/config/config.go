@@ -2,7 +2,9 @@ package config
 
 import (
 	"skynet-service/app/common"
+	"skynet-service/app/common/config"
 	"strings"
+	"sync/atomic"
 
 	"skynet-service/app/logs/logs"
 	"skynet-service/app/runtime/cache"
@@ -15,7 +17,6 @@ const (
 	NAME     string = "spider"                                 				// 软件名
 	FullName string = NAME + " " + VERSION + "（by " + AUTHOR + "）" 		// 软件全称
 	IconPng  string = ``
-	PidFile	 string = "/tmp/" + NAME + "_" + AUTHOR + "_" + VERSION			// 进程id存放位置
 )
 
 // 默认配置。
@@ -28,7 +29,13 @@ const (
 	PhantomjsTemp string = CacheDir                       // Surfer-Phantom下载器：js文件临时目录
 	HistoryTag    string = "history"                      // 历史记录的标识符
 	HistoryDir    string = WorkRoot + "/" + HistoryTag    // excel或csv输出方式下，历史记录目录
+	SessionDir    string = WorkRoot + "/sessions"         // file 方式下登录会话的存储目录
+	RunsDir       string = WorkRoot + "/runs"             // 任务运行记录目录
+	ChangeDir     string = WorkRoot + "/changes"          // 结果变化检测记录及事件目录
+	WebhookDead   string = WorkRoot + "/webhook_dead.jsonl" // 重试后仍投递失败的 Webhook 事件
 	SpiderExt     string = ".spider.html"                 // 动态规则扩展名
+	SpiderJsonExt string = ".spider.json"                 // 声明式规则扩展名(JSON)
+	SpiderYamlExt string = ".spider.yaml"                 // 声明式规则扩展名(YAML)
 )
 
 // 来自配置文件的配置项。
@@ -36,8 +43,15 @@ var (
 	CRAWLS_CAP int = setting.DefaultInt("crawlcap", crawlcap) // 蜘蛛池最大容量
 	// DATA_CHAN_CAP            int    = setting.DefaultInt("datachancap", datachancap)                         // 收集器容量
 	PHANTOMJS                string = setting.String("phantomjs")                                          // Surfer-Phantom下载器：phantomjs程序路径
-	PROXY                    string = setting.String("proxylib")                                           // 代理IP文件路径
 	SPIDER_DIR               string = setting.String("spiderdir")                                          // 动态规则目录
+	PID_FILE                 string = setting.String("pidfile")                                            // 进程id存放位置，运行期间加锁，重新加载配置时不变
+	USER_FILE                string = setting.String("userfile")                                           // HTTP控制接口的用户文件
+	SESSION_PROVIDER         string = setting.String("session::provider")                                  // 登录会话的存储方式
+	SESSION_MAXLIFETIME      int64  = setting.DefaultInt64("session::maxlifetime", sessionmaxlifetime)     // 登录会话的有效期，单位秒
+	RUNS_KEEP                int    = setting.DefaultInt("runs::keep", runskeep)                           // 最多保留的任务运行记录数
+	NOTIFY_FILE              string = setting.String("notifyfile")                                         // 邮件摘要的配置文件
+	ALERT_FILE               string = setting.String("alertfile")                                          // 数值告警的配置文件
+	WEBHOOK_FILE             string = setting.String("webhookfile")                                        // Webhook 的配置文件
 	FILE_DIR                 string = setting.String("fileoutdir")                                         // 文件（图片、HTML等）结果的输出目录
 	TEXT_DIR                 string = setting.String("textoutdir")                                         // excel或csv输出方式下，文本结果的输出目录
 	DB_NAME                  string = setting.String("dbname")                                             // 数据库名称
@@ -60,9 +74,63 @@ var (
 	LOG_SAVE           bool  = setting.DefaultBool("log::save", logsave)         // 是否保存所有日志到本地文件
 )
 
+// 运行期间可由 Reload() 替换的配置项。
+// 读取方以 Current() 取得当前的一份，不得修改；SIGHUP 时整份替换，无须加锁
+type Reloadable struct {
+	Proxy            string            // 代理IP文件路径
+	SpiderReload     int               // 检查动态规则目录变化的间隔，单位秒，0为不检查
+	ScriptTimeout    int               // 动态规则中脚本单次执行的超时，单位秒，0为不限
+	CronJobs         map[string]string // 定时任务，键为蜘蛛名称（不区分大小写），值为调度描述
+	SmtpHost         string            // 发送邮件的SMTP服务器地址，含端口
+	SmtpUsername     string            // SMTP用户名，为空时不认证
+	SmtpPassword     string            // SMTP密码
+	SmtpFrom         string            // 发件人地址
+	SmtpStartTLS     bool              // 服务器支持时是否使用STARTTLS
+	ArchiveSchedule  string            // 归档的调度描述，格式同 [cron] 段，为空时不归档
+	ArchiveRetention int               // 归档后原始数据的保留时长，单位小时，0为不清理
+	ArchiveMode      string            // 超过保留时长的原始数据的处理方式：delete | move
+	MysqlDropColumns bool              // 同步表结构时是否删除规则中已不存在的列
+}
+
+var current atomic.Value // *Reloadable
+
 func init() {
 	// 主要运行时参数的初始化
-	cache.Task = &cache.AppConf{
+	cache.Task = NewTask()
+	current.Store(newReloadable())
+}
+
+// 返回当前的可重新加载配置
+func Current() *Reloadable {
+	return current.Load().(*Reloadable)
+}
+
+// 替换可重新加载配置，r 此后不得再修改
+func SetCurrent(r *Reloadable) {
+	current.Store(r)
+}
+
+func newReloadable() *Reloadable {
+	return &Reloadable{
+		Proxy:            setting.String("proxylib"),
+		SpiderReload:     setting.DefaultInt("spiderreload", spiderreload),
+		ScriptTimeout:    setting.DefaultInt("scripttimeout", scripttimeout),
+		CronJobs:         section("cron"),
+		SmtpHost:         setting.String("smtp::host"),
+		SmtpUsername:     setting.String("smtp::username"),
+		SmtpPassword:     setting.String("smtp::password"),
+		SmtpFrom:         setting.String("smtp::from"),
+		SmtpStartTLS:     setting.DefaultBool("smtp::starttls", smtpstarttls),
+		ArchiveSchedule:  setting.String("archive::schedule"),
+		ArchiveRetention: setting.DefaultInt("archive::retention", archiveretention),
+		ArchiveMode:      setting.String("archive::mode"),
+		MysqlDropColumns: setting.DefaultBool("mysql::dropcolumns", mysqldropcolumns),
+	}
+}
+
+// 由配置文件生成运行时参数
+func NewTask() *cache.AppConf {
+	return &cache.AppConf{
 		Mode:           setting.DefaultInt("run::mode", mode),                 // 节点角色
 		Port:           setting.DefaultInt("run::port", port),                 // 主节点端口
 		Master:         setting.String("run::master"),                         // 服务器(主节点)地址，不含端口
@@ -77,6 +145,35 @@ func init() {
 	}
 }
 
+// 重新读取配置文件，整份替换 Current() 返回的配置项。
+// 运行参数不在此处修改，由调用方以 NewTask() 生成后交给 app 在两次任务之间替换；
+// setting 只在重新加载的协程中读写，NewTask() 须在同一协程中调用。
+func Reload() error {
+	iniconf, err := config.NewConfig("ini", CONFIG)
+	if err != nil {
+		return err
+	}
+	trySet(iniconf)
+	setting = iniconf
+	current.Store(newReloadable())
+	return nil
+}
+
+// 读取配置段，不存在时返回空表
+func section(name string) map[string]string {
+	s, err := setting.GetSection(name)
+	if err != nil {
+		return map[string]string{}
+	}
+	return s
+}
+
+// 解析日志级别名称，无法识别时返回 false
+func ParseLogLevel(l string) (int, bool) {
+	level := logLevel(l)
+	return level, level != -10
+}
+
 func logLevel(l string) int {
 	switch strings.ToLower(l) {
 	case "app":
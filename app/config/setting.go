@@ -15,6 +15,7 @@ import (
 var (
 	ServerHost 				*string									// <针对网页浏览>服务端ip
 	ServerPort 				*int 									// <针对网页浏览>服务端port
+	ServerEnable 			*bool 									// 执行任务时同时开启HTTP控制接口
 
 	RunMode           		*string									// 运行模式
 )
@@ -32,6 +33,21 @@ const (
 	phantomjs             string = WorkRoot + "/phantomjs"     						// phantomjs文件路径
 	proxylib              string = WorkRoot + "/proxy.lib"     						// 代理ip文件路径
 	spiderdir             string = WorkRoot + "/spiders"       						// 动态规则目录
+	spiderreload          int    = 5                           						// 检查动态规则目录变化的间隔，单位秒，0为不检查
+	scripttimeout         int    = 30                          						// 动态规则中脚本单次执行的超时，单位秒，0为不限
+	pidfile               string = "/tmp/" + NAME + "_" + AUTHOR + "_" + VERSION		// 进程id存放位置
+	userfile              string = WorkRoot + "/users.json"    						// HTTP控制接口的用户文件
+	sessionprovider       string = "memory"                    						// 登录会话的存储方式：memory | file
+	sessionmaxlifetime    int64  = 3600                        						// 登录会话的有效期，单位秒
+	runskeep              int    = 500                         						// 最多保留的任务运行记录数
+	notifyfile            string = WorkRoot + "/notify.json"   						// 邮件摘要的配置文件
+	alertfile             string = WorkRoot + "/alerts.json"   						// 数值告警的配置文件
+	webhookfile           string = WorkRoot + "/webhooks.json" 						// Webhook 的配置文件
+	smtphost              string = "127.0.0.1:25"              						// 发送邮件的SMTP服务器地址，含端口
+	smtpfrom              string = NAME + "@localhost"         						// 发件人地址
+	smtpstarttls          bool   = true                        						// 服务器支持时是否使用STARTTLS
+	archiveretention      int    = 720                         						// 归档后原始数据的保留时长，单位小时，0为不清理
+	archivemode           string = "delete"                    						// 超过保留时长的原始数据的处理方式：delete | move
 	fileoutdir            string = WorkRoot + "/file_out"      						// 文件（图片、HTML等）结果的输出目录
 	textoutdir            string = WorkRoot + "/text_out"      						// excel或csv输出方式下，文本结果的输出目录
 	dbname                string = common.TAG                         				// 数据库名称
@@ -41,6 +57,7 @@ const (
 	mysqlconnstring       string = "root:dingjing123@tcp(172.30.50.200:3306)"		// mysql连接字符串
 	mysqlconncap          int    = 2048                        						// mysql连接池容量
 	mysqlmaxallowedpacket int    = 1048576                     						// mysql通信缓冲区的最大长度，单位B，默认1MB
+	mysqldropcolumns      bool   = false                       						// 同步表结构时是否删除规则中已不存在的列
 	beanstalkHost         string = "localhost:11300"           						// beanstalkd队列默认主机（含端口）
 	beanstalkTube         string = ""                   							// beanstalkd队列默认tube
 	kafkabrokers          string = "127.0.0.1:9092"           		 				// kafka broker字符串,逗号分割
@@ -96,6 +113,24 @@ func defaultConfig(iniconf config.Configer) {
 	iniconf.Set("phantomjs", phantomjs)
 	iniconf.Set("proxylib", proxylib)
 	iniconf.Set("spiderdir", spiderdir)
+	iniconf.Set("spiderreload", strconv.Itoa(spiderreload))
+	iniconf.Set("scripttimeout", strconv.Itoa(scripttimeout))
+	iniconf.Set("pidfile", pidfile)
+	iniconf.Set("userfile", userfile)
+	iniconf.Set("session::provider", sessionprovider)
+	iniconf.Set("session::maxlifetime", strconv.FormatInt(sessionmaxlifetime, 10))
+	iniconf.Set("runs::keep", strconv.Itoa(runskeep))
+	iniconf.Set("notifyfile", notifyfile)
+	iniconf.Set("alertfile", alertfile)
+	iniconf.Set("webhookfile", webhookfile)
+	iniconf.Set("smtp::host", smtphost)
+	iniconf.Set("smtp::username", "")
+	iniconf.Set("smtp::password", "")
+	iniconf.Set("smtp::from", smtpfrom)
+	iniconf.Set("smtp::starttls", fmt.Sprint(smtpstarttls))
+	iniconf.Set("archive::schedule", "")
+	iniconf.Set("archive::retention", strconv.Itoa(archiveretention))
+	iniconf.Set("archive::mode", archivemode)
 	iniconf.Set("fileoutdir", fileoutdir)
 	iniconf.Set("textoutdir", textoutdir)
 	iniconf.Set("dbname", dbname)
@@ -105,6 +140,7 @@ func defaultConfig(iniconf config.Configer) {
 	iniconf.Set("mysql::connstring", mysqlconnstring)
 	iniconf.Set("mysql::conncap", strconv.Itoa(mysqlconncap))
 	iniconf.Set("mysql::maxallowedpacket", strconv.Itoa(mysqlmaxallowedpacket))
+	iniconf.Set("mysql::dropcolumns", fmt.Sprint(mysqldropcolumns))
 	iniconf.Set("kafka::brokers", kafkabrokers)
 	iniconf.Set("run::mode", strconv.Itoa(mode))
 	iniconf.Set("run::port", strconv.Itoa(port))
@@ -170,6 +206,67 @@ func trySet(iniconf config.Configer) {
 		iniconf.Set("spiderdir", spiderdir)
 	}
 
+	if v, e := iniconf.Int("spiderreload"); v < 0 || e != nil {
+		iniconf.Set("spiderreload", strconv.Itoa(spiderreload))
+	}
+
+	if v, e := iniconf.Int("scripttimeout"); v < 0 || e != nil {
+		iniconf.Set("scripttimeout", strconv.Itoa(scripttimeout))
+	}
+
+	if v := iniconf.String("pidfile"); v == "" {
+		iniconf.Set("pidfile", pidfile)
+	}
+
+	if v := iniconf.String("userfile"); v == "" {
+		iniconf.Set("userfile", userfile)
+	}
+
+	if v := iniconf.String("session::provider"); v != "memory" && v != "file" {
+		iniconf.Set("session::provider", sessionprovider)
+	}
+
+	if v, e := iniconf.Int64("session::maxlifetime"); v <= 0 || e != nil {
+		iniconf.Set("session::maxlifetime", strconv.FormatInt(sessionmaxlifetime, 10))
+	}
+
+	if v, e := iniconf.Int("runs::keep"); v < 0 || e != nil {
+		iniconf.Set("runs::keep", strconv.Itoa(runskeep))
+	}
+
+	if v := iniconf.String("notifyfile"); v == "" {
+		iniconf.Set("notifyfile", notifyfile)
+	}
+
+	if v := iniconf.String("alertfile"); v == "" {
+		iniconf.Set("alertfile", alertfile)
+	}
+
+	if v := iniconf.String("webhookfile"); v == "" {
+		iniconf.Set("webhookfile", webhookfile)
+	}
+
+	if v := iniconf.String("smtp::host"); v == "" {
+		iniconf.Set("smtp::host", smtphost)
+	}
+
+	if v := iniconf.String("smtp::from"); v == "" {
+		iniconf.Set("smtp::from", smtpfrom)
+	}
+
+	if _, e := iniconf.Bool("smtp::starttls"); e != nil {
+		iniconf.Set("smtp::starttls", fmt.Sprint(smtpstarttls))
+	}
+
+	// 保留时长不足两天时，尚在汇总的数据可能已被清理
+	if v, e := iniconf.Int("archive::retention"); v < 0 || (v > 0 && v < 48) || e != nil {
+		iniconf.Set("archive::retention", strconv.Itoa(archiveretention))
+	}
+
+	if v := iniconf.String("archive::mode"); v != "delete" && v != "move" {
+		iniconf.Set("archive::mode", archivemode)
+	}
+
 	if v := iniconf.String("fileoutdir"); v == "" {
 		iniconf.Set("fileoutdir", fileoutdir)
 	}
@@ -206,6 +303,10 @@ func trySet(iniconf config.Configer) {
 		iniconf.Set("mysql::maxallowedpacket", strconv.Itoa(mysqlmaxallowedpacket))
 	}
 
+	if _, e := iniconf.Bool("mysql::dropcolumns"); e != nil {
+		iniconf.Set("mysql::dropcolumns", fmt.Sprint(mysqldropcolumns))
+	}
+
 	if v := iniconf.String("kafka::brokers"); v == "" {
 		iniconf.Set("kafka::brokers", kafkabrokers)
 	}
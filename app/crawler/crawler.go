@@ -98,7 +98,7 @@ func (self *crawler) run() {
 			defer func() {
 				self.FreeOne()
 			}()
-			logs.Log.Debug("Start: %v", req.GetUrl())
+			logs.Log.Spider(self.Spider.GetName()).Debug("Start: [%s] %v", self.Spider.GetName(), req.GetUrl())
 			self.Process(req)
 		}()
 
@@ -116,12 +116,14 @@ func (self *crawler) Process(req *request.Request) {
 		downUrl = req.GetUrl()
 		sp      = self.Spider
 	)
+	requestsTotal.Inc(sp.GetName())
 	defer func() {
 		if p := recover(); p != nil {
 			if sp.IsStopping() {
 				// println("Process$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$")
 				return
 			}
+			failuresTotal.Inc(sp.GetName(), "panic")
 			// 返回是否作为新的失败请求被添加至队列尾部
 			if sp.DoHistory(req, false) {
 				// 统计失败数
@@ -138,34 +140,53 @@ func (self *crawler) Process(req *request.Request) {
 				stack = stack[:end]
 			}
 			stack = bytes.Replace(stack, []byte("\n"), []byte("\r\n"), -1)
-			logs.Log.Error(" *     Panic  [process][%s]: %s\r\n[TRACE]\r\n%s", downUrl, p, stack)
+			logs.Log.Spider(sp.GetName()).Error(" *     Panic  [process][%s][%s]: %s\r\n[TRACE]\r\n%s", sp.GetName(), downUrl, p, stack)
 		}
 	}()
 
 	var ctx = self.Downloader.Download(sp, req) // download page
 
 	if err := ctx.GetError(); err != nil {
+		failuresTotal.Inc(sp.GetName(), failureClass(ctx, err))
 		// 返回是否作为新的失败请求被添加至队列尾部
 		if sp.DoHistory(req, false) {
 			// 统计失败数
 			cache.PageFailCount()
 		}
 		// 提示错误
-		logs.Log.Error(" *     Fail  [download][%v]: %v\n", downUrl, err)
+		logs.Log.Spider(sp.GetName()).Error(" *     Fail  [download][%s][%v]: %v\n", sp.GetName(), downUrl, err)
 		return
 	}
 
 	// 过程处理，提炼数据
 	ctx.Parse(req.GetRuleName())
 
+	// 解析时通过 ctx.SetError() 标记的错误同样视为失败请求
+	if err := ctx.GetError(); err != nil {
+		failuresTotal.Inc(sp.GetName(), "parse")
+		if sp.DoHistory(req, false) {
+			cache.PageFailCount()
+		}
+		logs.Log.Spider(sp.GetName()).Error(" *     Fail  [parse][%s][%v]: %v\n", sp.GetName(), downUrl, err)
+		return
+	}
+
+	var (
+		files = ctx.PullFiles()
+		items = ctx.PullItems()
+	)
+	sp.CountResult(len(items), len(files))
+	itemsTotal.Add(float64(len(items)), sp.GetName())
+	filesTotal.Add(float64(len(files)), sp.GetName())
+
 	// 该条请求文件结果存入pipeline
-	for _, f := range ctx.PullFiles() {
+	for _, f := range files {
 		if self.Pipeline.CollectFile(f) != nil {
 			break
 		}
 	}
 	// 该条请求文本结果存入pipeline
-	for _, item := range ctx.PullItems() {
+	for _, item := range items {
 		logs.Log.Debug("Save data to pipeline:%v", item)
 		if self.Pipeline.CollectData(item) != nil {
 			break
@@ -177,9 +198,10 @@ func (self *crawler) Process(req *request.Request) {
 
 	// 统计成功页数
 	cache.PageSuccCount()
+	successesTotal.Inc(sp.GetName())
 
 	// 提示抓取成功
-	logs.Log.Informational("Success: %v", downUrl)
+	logs.Log.Spider(sp.GetName()).Informational("Success: [%s] %v", sp.GetName(), downUrl)
 
 	// 释放ctx准备复用
 	spider.PutContext(ctx)
@@ -106,8 +106,11 @@ func (self *cq) Stop() {
 		return
 	}
 	self.status = status.STOP
-	close(self.usable)
-	self.usable = nil
+	// 任务尚未开始采集时资源队列还未创建
+	if self.usable != nil {
+		close(self.usable)
+		self.usable = nil
+	}
 	self.Unlock()
 
 	for _, crawler := range self.all {
@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"errors"
+	"net"
+
+	"skynet-service/app/runtime/metrics"
+	"skynet-service/app/spider"
+)
+
+var (
+	requestsTotal = metrics.NewCounter("skynet_requests_total",
+		"Requests processed, by spider.", "spider")
+	successesTotal = metrics.NewCounter("skynet_successes_total",
+		"Requests downloaded and parsed successfully, by spider.", "spider")
+	failuresTotal = metrics.NewCounter("skynet_failures_total",
+		"Failed requests, by spider and error class.", "spider", "class")
+	itemsTotal = metrics.NewCounter("skynet_items_total",
+		"Text items collected, by spider.", "spider")
+	filesTotal = metrics.NewCounter("skynet_files_total",
+		"Files collected, by spider.", "spider")
+)
+
+// 下载失败的错误分类
+func failureClass(ctx *spider.Context, err error) string {
+	if resp := ctx.Response; resp != nil && resp.StatusCode >= 500 {
+		return "http_5xx"
+	} else if resp != nil && resp.StatusCode >= 400 {
+		return "http_4xx"
+	}
+	var (
+		dnsErr *net.DNSError
+		netErr net.Error
+		opErr  *net.OpError
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr):
+		return "connection"
+	}
+	return "other"
+}
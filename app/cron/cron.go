@@ -0,0 +1,283 @@
+// 定时任务调度
+package cron
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"skynet-service/app"
+	"skynet-service/app/logs"
+	"skynet-service/app/runtime/cache"
+	"skynet-service/app/spider"
+)
+
+// 每个定时任务保留的运行记录数
+const MaxRuns = 100
+
+type (
+	// 定时调度器，同一时刻只执行一批任务，同一蜘蛛不会重叠运行
+	Cron struct {
+		app     app.App
+		jobs    []*Job
+		pending []*Job        // 等待执行的任务
+		wake    chan struct{} // 通知执行协程
+		stop    chan struct{}
+		done    chan struct{}
+		running bool
+		sync.RWMutex
+	}
+	// 定时任务
+	Job struct {
+		Spider   string // 蜘蛛名称
+		Spec     string // 调度描述
+		schedule Schedule
+		next     time.Time // 下次执行时刻
+		busy     bool      // 排队或运行中
+		runs     []*Run    // 最近的运行记录
+	}
+	// 单次运行记录
+	Run struct {
+		Start   time.Time
+		End     time.Time
+		Reports []*cache.Report // 该蜘蛛本次运行的报告（每个Keyin一份）
+	}
+)
+
+func New(a app.App) *Cron {
+	return &Cron{
+		app:  a,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// 添加定时任务
+func (self *Cron) Add(spiderName, spec string) error {
+	if self.app.GetSpiderByName(spiderName) == nil {
+		return fmt.Errorf("cron: spider not found: %s", spiderName)
+	}
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	self.Lock()
+	defer self.Unlock()
+	for _, j := range self.jobs {
+		if j.Spider == spiderName {
+			return fmt.Errorf("cron: duplicate job for spider: %s", spiderName)
+		}
+	}
+	self.jobs = append(self.jobs, &Job{
+		Spider:   spiderName,
+		Spec:     spec,
+		schedule: schedule,
+		next:     schedule.Next(time.Now()),
+	})
+	if self.running {
+		self.notify()
+	}
+	return nil
+}
+
+// 以 specs 替换全部定时任务，键为蜘蛛名称，值为调度描述；
+// 调度描述未变的任务保留下次执行时刻与运行记录，有误时不做任何修改
+func (self *Cron) Replace(specs map[string]string) error {
+	schedules := make(map[string]Schedule, len(specs))
+	for name, spec := range specs {
+		if self.app.GetSpiderByName(name) == nil {
+			return fmt.Errorf("cron: spider not found: %s", name)
+		}
+		schedule, err := Parse(spec)
+		if err != nil {
+			return err
+		}
+		schedules[name] = schedule
+	}
+
+	now := time.Now()
+	self.Lock()
+	defer self.Unlock()
+	old := make(map[string]*Job, len(self.jobs))
+	for _, j := range self.jobs {
+		old[j.Spider] = j
+	}
+	jobs := make([]*Job, 0, len(specs))
+	for name, spec := range specs {
+		// 原有任务就地修改，排队或运行中的任务结束时仍能复位
+		j, ok := old[name]
+		if !ok {
+			j = &Job{Spider: name}
+		}
+		if !ok || j.Spec != spec {
+			j.Spec = spec
+			j.schedule = schedules[name]
+			j.next = j.schedule.Next(now)
+		}
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Spider < jobs[j].Spider })
+	self.jobs = jobs
+	if self.running {
+		self.notify()
+	}
+	return nil
+}
+
+// 返回全部定时任务
+func (self *Cron) Jobs() []*Job {
+	self.RLock()
+	defer self.RUnlock()
+	jobs := make([]*Job, len(self.jobs))
+	copy(jobs, self.jobs)
+	return jobs
+}
+
+// 启动调度
+func (self *Cron) Start() {
+	self.Lock()
+	defer self.Unlock()
+	if self.running {
+		return
+	}
+	self.running = true
+	self.stop = make(chan struct{})
+	self.done = make(chan struct{})
+	go self.loop()
+	go self.work()
+}
+
+// 停止调度，并终止正在执行的任务
+func (self *Cron) Stop() {
+	self.Lock()
+	if !self.running {
+		self.Unlock()
+		return
+	}
+	self.running = false
+	close(self.stop)
+	self.Unlock()
+
+	if !self.app.IsStopped() {
+		self.app.Stop()
+	}
+	<-self.done
+}
+
+// 返回任务的下次执行时刻
+func (self *Cron) Next(j *Job) time.Time {
+	self.RLock()
+	defer self.RUnlock()
+	return j.next
+}
+
+// 返回任务是否正在排队或运行
+func (self *Cron) IsBusy(j *Job) bool {
+	self.RLock()
+	defer self.RUnlock()
+	return j.busy
+}
+
+// 返回任务最近的运行记录，按时间先后排列
+func (self *Cron) Runs(j *Job) []*Run {
+	self.RLock()
+	defer self.RUnlock()
+	runs := make([]*Run, len(j.runs))
+	copy(runs, j.runs)
+	return runs
+}
+
+// 计时协程：到期任务加入等待队列
+func (self *Cron) loop() {
+	Loop(self.stop, func(now time.Time) (next time.Time) {
+		self.Lock()
+		defer self.Unlock()
+		for _, j := range self.jobs {
+			if !j.next.After(now) {
+				if j.busy {
+					logs.Log.Warning(" *     [定时任务：%s]   上次运行尚未结束，跳过本次执行", j.Spider)
+				} else {
+					j.busy = true
+					self.pending = append(self.pending, j)
+				}
+				j.next = j.schedule.Next(now)
+			}
+			next = Earlier(next, j.next)
+		}
+		if len(self.pending) > 0 {
+			self.notify()
+		}
+		return next
+	})
+}
+
+// 执行协程：逐批运行等待中的任务
+func (self *Cron) work() {
+	defer close(self.done)
+	for {
+		select {
+		case <-self.stop:
+			return
+		case <-self.wake:
+		}
+
+		self.Lock()
+		jobs := self.pending
+		self.pending = nil
+		self.Unlock()
+
+		if len(jobs) > 0 {
+			self.run(jobs)
+		}
+	}
+}
+
+func (self *Cron) run(jobs []*Job) {
+	var spiders []*spider.Spider
+	for _, j := range jobs {
+		if sp := self.app.GetSpiderByName(j.Spider); sp != nil {
+			spiders = append(spiders, sp)
+		}
+	}
+
+	// 经由 HTTP 接口等发起的任务尚未结束
+	if err := self.app.TryPrepare(spiders); err != nil {
+		logs.Log.Warning(" *     [定时任务]   %v，跳过本次执行", err)
+		self.Lock()
+		for _, j := range jobs {
+			j.busy = false
+		}
+		self.Unlock()
+		return
+	}
+	r := Run{Start: time.Now()}
+	logs.Log.Informational(" *     [定时任务]   开始运行 %v 个蜘蛛", len(spiders))
+	self.app.Run()
+	r.End = time.Now()
+	reports := self.app.GetReports()
+
+	self.Lock()
+	defer self.Unlock()
+	for _, j := range jobs {
+		jr := r
+		for _, rep := range reports {
+			if rep.SpiderName == j.Spider {
+				jr.Reports = append(jr.Reports, rep)
+			}
+		}
+		j.runs = append(j.runs, &jr)
+		if len(j.runs) > MaxRuns {
+			j.runs = j.runs[len(j.runs)-MaxRuns:]
+		}
+		j.busy = false
+	}
+}
+
+// 唤醒执行协程，须在持有锁时调用
+func (self *Cron) notify() {
+	select {
+	case self.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,71 @@
+package cron
+
+import (
+	"testing"
+
+	"skynet-service/app"
+	"skynet-service/app/spider"
+)
+
+// 只实现 Replace() 用到的方法
+type fakeApp struct {
+	app.App
+	names []string
+}
+
+func (self *fakeApp) GetSpiderByName(name string) *spider.Spider {
+	for _, n := range self.names {
+		if n == name {
+			return &spider.Spider{Name: name}
+		}
+	}
+	return nil
+}
+
+func TestReplace(t *testing.T) {
+	c := New(&fakeApp{names: []string{"a", "b", "c"}})
+	if err := c.Replace(map[string]string{"a": "@every 1h", "b": "@every 2h"}); err != nil {
+		t.Fatal(err)
+	}
+	jobs := c.Jobs()
+	if len(jobs) != 2 || jobs[0].Spider != "a" || jobs[1].Spider != "b" {
+		t.Fatalf("jobs = %v", jobs)
+	}
+	a, b := jobs[0], jobs[1]
+	a.runs = []*Run{{}}
+	nextA, nextB := c.Next(a), c.Next(b)
+
+	for _, specs := range []map[string]string{
+		{"a": "@every 1h", "nope": "@every 1h"},
+		{"a": "bad spec"},
+	} {
+		if err := c.Replace(specs); err == nil {
+			t.Fatalf("Replace(%v) succeeded", specs)
+		}
+	}
+	if len(c.Jobs()) != 2 {
+		t.Fatalf("failed Replace() changed jobs: %v", c.Jobs())
+	}
+
+	// a 不变，b 改为每天，新增 c
+	if err := c.Replace(map[string]string{"a": "@every 1h", "b": "@daily", "c": "@every 1h"}); err != nil {
+		t.Fatal(err)
+	}
+	jobs = c.Jobs()
+	if len(jobs) != 3 || jobs[0] != a || jobs[1] != b || jobs[2].Spider != "c" {
+		t.Fatalf("jobs = %v", jobs)
+	}
+	if !c.Next(a).Equal(nextA) || len(c.Runs(a)) != 1 {
+		t.Fatal("unchanged job was reset")
+	}
+	if b.Spec != "@daily" || c.Next(b).Equal(nextB) {
+		t.Fatalf("changed job: spec %q, next %v", b.Spec, c.Next(b))
+	}
+
+	if err := c.Replace(map[string]string{"c": "@every 1h"}); err != nil {
+		t.Fatal(err)
+	}
+	if jobs = c.Jobs(); len(jobs) != 1 || jobs[0].Spider != "c" {
+		t.Fatalf("jobs = %v", jobs)
+	}
+}
@@ -0,0 +1,33 @@
+package cron
+
+import "time"
+
+// 计时循环，直至 stop 被关闭：每次醒来调用 tick(now) 处理到期的计划，
+// tick 返回各计划中最早的下次执行时刻，为零值时表示没有计划；
+// 最长每分钟醒来一次，以纳入期间新增的计划。Cron 与邮件摘要等共用
+func Loop(stop <-chan struct{}, tick func(now time.Time) time.Time) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		next := now.Add(time.Minute)
+		if t := tick(now); !t.IsZero() && t.Before(next) {
+			next = t
+		}
+		timer.Reset(time.Until(next))
+	}
+}
+
+// 两个执行时刻中较早的一个，零值表示没有
+func Earlier(a, b time.Time) time.Time {
+	if a.IsZero() || !b.IsZero() && b.Before(a) {
+		return b
+	}
+	return a
+}
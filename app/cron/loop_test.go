@@ -0,0 +1,41 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoop(t *testing.T) {
+	stop, done := make(chan struct{}), make(chan struct{})
+	ticks := make(chan time.Time, 10)
+	go func() {
+		defer close(done)
+		Loop(stop, func(now time.Time) time.Time {
+			ticks <- now
+			return now.Add(20 * time.Millisecond)
+		})
+	}()
+
+	first := <-ticks
+	second := <-ticks
+	if d := second.Sub(first); d < 20*time.Millisecond || d > time.Second {
+		t.Fatalf("ticks %v apart", d)
+	}
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Loop() did not return after stop")
+	}
+}
+
+func TestEarlier(t *testing.T) {
+	var zero time.Time
+	a := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := a.Add(time.Hour)
+	for _, c := range [][3]time.Time{{zero, zero, zero}, {zero, a, a}, {a, zero, a}, {a, b, a}, {b, a, a}} {
+		if got := Earlier(c[0], c[1]); !got.Equal(c[2]) {
+			t.Errorf("Earlier(%v, %v) = %v", c[0], c[1], got)
+		}
+	}
+}
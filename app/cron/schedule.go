@@ -0,0 +1,210 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 调度计划，返回给定时刻之后的下一次执行时刻
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// 固定间隔调度
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// 五段式 cron 调度：分 时 日 月 周
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// 字段取值范围
+type bounds struct {
+	min, max uint
+}
+
+var (
+	minuteBounds = bounds{0, 59}
+	hourBounds   = bounds{0, 23}
+	domBounds    = bounds{1, 31}
+	monthBounds  = bounds{1, 12}
+	dowBounds    = bounds{0, 7}
+)
+
+// 预定义调度
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// 解析调度描述，支持：
+// 五段式 cron 表达式，如 "*/5 * * * *"；
+// 预定义描述，如 "@daily"；
+// 固定间隔，如 "@every 5m" 或 "5m"。
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("cron: empty spec")
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		return parseInterval(strings.TrimSpace(spec[len("@every "):]))
+	}
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+	if !strings.ContainsAny(spec, " \t") {
+		return parseInterval(spec)
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, found %d: %s", len(fields), spec)
+	}
+
+	var (
+		s   = &cronSchedule{}
+		err error
+	)
+	if s.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, err
+	}
+	// 周日可写作0或7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+func parseInterval(s string) (Schedule, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("cron: invalid interval %q: %v", s, err)
+	}
+	if d < time.Second {
+		return nil, fmt.Errorf("cron: interval %v is shorter than 1s", d)
+	}
+	return &intervalSchedule{interval: d}, nil
+}
+
+// 解析单个字段，支持 * ? a a-b a,b */n a-b/n
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		var (
+			rangeAndStep = strings.SplitN(expr, "/", 2)
+			lowAndHigh   = strings.SplitN(rangeAndStep[0], "-", 2)
+			start, end   uint
+			step         uint = 1
+			err          error
+		)
+		switch {
+		case lowAndHigh[0] == "*" || lowAndHigh[0] == "?":
+			start, end = b.min, b.max
+		default:
+			if start, err = parseUint(lowAndHigh[0]); err != nil {
+				return 0, err
+			}
+			end = start
+			if len(lowAndHigh) == 2 {
+				if end, err = parseUint(lowAndHigh[1]); err != nil {
+					return 0, err
+				}
+			}
+		}
+		if len(rangeAndStep) == 2 {
+			if step, err = parseUint(rangeAndStep[1]); err != nil {
+				return 0, err
+			}
+			if step == 0 {
+				return 0, fmt.Errorf("cron: step of range should be a positive number: %s", expr)
+			}
+			// a/n 等价于 a-max/n
+			if len(lowAndHigh) == 1 && lowAndHigh[0] != "*" && lowAndHigh[0] != "?" {
+				end = b.max
+			}
+		}
+		if start < b.min || end > b.max || start > end {
+			return 0, fmt.Errorf("cron: %s out of range [%d, %d]", expr, b.min, b.max)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+	return bits, nil
+}
+
+func parseUint(s string) (uint, error) {
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("cron: invalid number %q", s)
+	}
+	return uint(n), nil
+}
+
+func (self *intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(self.interval)
+}
+
+func (self *cronSchedule) Next(t time.Time) time.Time {
+	// 从下一整分钟开始查找
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+
+	// 五年内无匹配时放弃
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		if self.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !self.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if self.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if self.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// 日与周均被限定时，满足其一即可
+func (self *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := self.dom&(1<<uint(t.Day())) != 0
+	dowMatch := self.dow&(1<<uint(t.Weekday())) != 0
+	if self.domStar || self.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
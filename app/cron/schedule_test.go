@@ -0,0 +1,41 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	base := time.Date(2021, 3, 15, 10, 2, 30, 0, time.Local) // 周一
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"@every 5m", base.Add(5 * time.Minute)},
+		{"90s", base.Add(90 * time.Second)},
+		{"*/5 * * * *", time.Date(2021, 3, 15, 10, 5, 0, 0, time.Local)},
+		{"0 6,18 * * *", time.Date(2021, 3, 15, 18, 0, 0, 0, time.Local)},
+		{"30 9-17/4 * * *", time.Date(2021, 3, 15, 13, 30, 0, 0, time.Local)},
+		{"@daily", time.Date(2021, 3, 16, 0, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2021, 3, 21, 0, 0, 0, 0, time.Local)},
+		{"0 0 1 * 3", time.Date(2021, 3, 17, 0, 0, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local)},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", c.spec, err)
+		}
+		if got := s.Next(base); !got.Equal(c.next) {
+			t.Errorf("Parse(%q).Next() = %v, want %v", c.spec, got, c.next)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 10ms", "abc"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) should fail", spec)
+		}
+	}
+}
@@ -4,97 +4,239 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strconv"
 
 	"skynet-service/app/logs"
 	"syscall"
 	"time"
 )
 
-var runIndex = 0
+// 守护进程相关的环境变量
+const (
+	EnvRole     = "SKYNET_DAEMON_ROLE"     // 进程角色
+	EnvRestarts = "SKYNET_DAEMON_RESTARTS" // 工作进程已被重启的次数
+)
+
+// 进程角色
+const (
+	RoleNone       = ""           // 未转入后台
+	RoleSupervisor = "supervisor" // 监督进程，负责拉起并监视工作进程
+	RoleWorker     = "worker"     // 工作进程，执行实际任务
+)
 
 type Daemon struct {
-	Stop 			bool			// 是否退出
+	MinBackoff  time.Duration // 首次重启前的等待时长
+	MaxBackoff  time.Duration // 重启等待时长上限，每次崩溃后等待时长翻倍
+	StableAfter time.Duration // 工作进程运行超过该时长视为稳定，重置退避与崩溃计数
+	MaxCrashes  int           // 连续崩溃次数上限，超过后不再重启
+
+	// 以下为空时使用实际的进程与时钟，供测试替换
+	startWorker func(restarts int) (worker, error)
+	now         func() time.Time
+	after       func(time.Duration) <-chan time.Time
 }
 
-/**
- * 把本身程序转化为后台运行(启动一个子进程，然后自己退出)
- */
-func Background (isExit bool) (*exec.Cmd, error) {
-	// 判断是子进程还是父进程
-	runIndex++
+// 被监督的工作进程
+type worker interface {
+	Pid() int
+	Wait() error
+	Signal(sig os.Signal) error
+}
 
-	// 设置子进程环境变量，启动次数
-	env := os.Environ()
-	env = append (env, fmt.Sprintf("%d", runIndex))
+// 由本程序启动的工作进程
+type procWorker struct {
+	cmd *exec.Cmd
+}
 
-	// 启动子进程
-	cmd, err := startProc (os.Args, env)
-	if nil != err {
-		logs.Log.Error("sub process start error:%v", err)
-		return nil, err
-	} else {
-		logs.Log.Informational("sub process start ok!")
-	}
+func (w *procWorker) Pid () int {
+	return w.cmd.Process.Pid
+}
 
-	if isExit {
-		os.Exit(0)
-	}
+func (w *procWorker) Wait () error {
+	return w.cmd.Wait()
+}
 
-	return cmd, nil
+func (w *procWorker) Signal (sig os.Signal) error {
+	return w.cmd.Process.Signal(sig)
 }
 
 func NewDaemon () *Daemon {
-	return &Daemon {}
+	return &Daemon {
+		MinBackoff:  time.Second,
+		MaxBackoff:  5 * time.Minute,
+		StableAfter: time.Minute,
+		MaxCrashes:  10,
+	}
+}
+
+// 返回当前进程的角色
+func Role () string {
+	return os.Getenv(EnvRole)
 }
 
 /**
- * 启动后台守护进程
+ * 把本身程序转化为后台运行(启动一个监督进程，然后自己退出)
  */
-func (d* Daemon) Run () {
-	Background(true)		// 启动一个守护进程之后退出
-
-	errNum := 0
-	// 守护进程启动一个子进程并循环监视
-	for !d.Stop {
-		// daemon 描述信息
-		logs.Log.Emergency("pid:%d, errNum:%d sub process fail", os.Getpid(), errNum)
-
-		t := time.Now().Unix()	// 启动时间戳
-		cmd, err := Background(false)
-		if nil != err {
-			logs.Log.Alert("sub process start failed! %s", err)
-			errNum++
-			continue
+func Background () error {
+	_, err := startProc(RoleSupervisor, 0)
+	if nil != err {
+		return fmt.Errorf("start supervisor error: %v", err)
+	}
+	os.Exit(0)
+	return nil
+}
+
+/**
+ * 监督进程主循环：启动工作进程并监视，异常退出时按指数退避重启，
+ * SIGINT/SIGTERM/SIGHUP 转发给工作进程，返回进程退出码
+ */
+func (d *Daemon) Run () int {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	logs.Log.Informational("supervisor pid:%d started", os.Getpid())
+	return d.supervise(sigs)
+}
+
+// 监督循环，信号由 sigs 传入
+func (d *Daemon) supervise (sigs chan os.Signal) int {
+	var (
+		crashes = 0
+		backoff = d.MinBackoff
+	)
+	for restarts := 0; ; restarts++ {
+		start := d.clock()
+		w, err := d.start(restarts)
+		if nil == err {
+			logs.Log.Informational("worker pid:%d started, restarts:%d", w.Pid(), restarts)
+			var stopping bool
+			stopping, err = d.wait(w, sigs)
+			if stopping {
+				logs.Log.Informational("worker pid:%d stopped: %v", w.Pid(), err)
+				return 0
+			}
+			if nil == err {
+				logs.Log.Informational("worker pid:%d exited normally after %v", w.Pid(), d.clock().Sub(start))
+				return 0
+			}
+			logs.Log.Error("worker pid:%d exited after %v: %v", w.Pid(), d.clock().Sub(start), err)
+		} else {
+			logs.Log.Error("worker start error: %v", err)
 		}
 
-		// 子进程
-		if nil == cmd {
-			logs.Log.Informational("sub process pid:%d starting...", os.Getpid())
-			break
+		// 稳定运行过一段时间后重新计数
+		if d.clock().Sub(start) >= d.StableAfter {
+			crashes = 0
+			backoff = d.MinBackoff
+		}
+		crashes++
+		if crashes > d.MaxCrashes {
+			logs.Log.Critical("worker crashed %d times in a row, supervisor gives up", crashes - 1)
+			return 1
 		}
 
-		// 父进程
-		err = cmd.Wait()
-		dat := time.Now().Unix() - t
+		logs.Log.Warning("worker will restart in %v", backoff)
+		if d.sleep(backoff, sigs) {
+			return 0
+		}
+		backoff *= 2
+		if backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+}
+
+// 启动工作进程
+func (d *Daemon) start (restarts int) (worker, error) {
+	if d.startWorker != nil {
+		return d.startWorker(restarts)
+	}
+	cmd, err := startProc(RoleWorker, restarts)
+	if nil != err {
+		return nil, err
+	}
+	return &procWorker{cmd: cmd}, nil
+}
 
-		errNum++
+func (d *Daemon) clock () time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
 
-		logs.Log.Informational("sub process pid:%d runs %d second, error:%v", cmd.ProcessState.Pid(), dat, err)
+// 等待工作进程退出，期间转发信号，返回是否因退出信号而结束
+func (d *Daemon) wait (w worker, sigs chan os.Signal) (bool, error) {
+	exited := make(chan error, 1)
+	go func() {
+		exited <- w.Wait()
+	}()
+
+	stopping := false
+	for {
+		select {
+		case sig := <-sigs:
+			logs.Log.Informational("supervisor received %v, forwarding to worker pid:%d", sig, w.Pid())
+			if sig != syscall.SIGHUP {
+				stopping = true
+			}
+			w.Signal(sig)
+		case err := <-exited:
+			return stopping, err
+		}
 	}
 }
 
-func startProc (args, env []string) (*exec.Cmd, error) {
+// 退避等待，期间收到退出信号时返回 true
+func (d *Daemon) sleep (dur time.Duration, sigs chan os.Signal) bool {
+	var timeout <-chan time.Time
+	if d.after != nil {
+		timeout = d.after(dur)
+	} else {
+		timer := time.NewTimer(dur)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		select {
+		case sig := <-sigs:
+			if sig != syscall.SIGHUP {
+				return true
+			}
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+func startProc (role string, restarts int) (*exec.Cmd, error) {
+	path, err := os.Executable()
+	if nil != err {
+		return nil, err
+	}
+
+	// 设置子进程环境变量：角色与重启次数
+	env := append(os.Environ(),
+		EnvRole + "=" + role,
+		EnvRestarts + "=" + strconv.Itoa(restarts),
+	)
+
 	cmd := &exec.Cmd {
-		Path:			args[0],
-		Args:			args,
+		Path:			path,
+		Args:			os.Args,
 		Env: 			env,
-		SysProcAttr: 	&syscall.SysProcAttr {Setsid: true},
+	}
+	// 监督进程脱离终端，工作进程与监督进程同组
+	if role == RoleSupervisor {
+		cmd.SysProcAttr = &syscall.SysProcAttr {Setsid: true}
 	}
 
-	err := cmd.Start()
+	err = cmd.Start()
 	if nil != err {
 		return nil, err
 	}
 
 	return cmd, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,145 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 手动推进的时钟，退避等待立即结束并记录等待时长
+type fakeClock struct {
+	t      time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) after(d time.Duration) <-chan time.Time {
+	c.sleeps = append(c.sleeps, d)
+	c.t = c.t.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.t
+	return ch
+}
+
+// 运行 run 后以 err 退出的工作进程；exit 不为空时一直运行到收到 SIGTERM
+type fakeWorker struct {
+	clock   *fakeClock
+	run     time.Duration
+	err     error
+	exit    chan struct{}
+	signals []os.Signal
+}
+
+func (w *fakeWorker) Pid() int { return 1 }
+
+func (w *fakeWorker) Wait() error {
+	if w.exit != nil {
+		<-w.exit
+		return errors.New("signal: terminated")
+	}
+	w.clock.t = w.clock.t.Add(w.run)
+	return w.err
+}
+
+func (w *fakeWorker) Signal(sig os.Signal) error {
+	w.signals = append(w.signals, sig)
+	if sig == syscall.SIGTERM {
+		close(w.exit)
+	}
+	return nil
+}
+
+func testDaemon(clock *fakeClock, workers func(restarts int) (worker, error)) *Daemon {
+	return &Daemon{
+		MinBackoff:  time.Second,
+		MaxBackoff:  4 * time.Second,
+		StableAfter: time.Minute,
+		MaxCrashes:  4,
+		startWorker: workers,
+		now:         clock.now,
+		after:       clock.after,
+	}
+}
+
+func TestSuperviseBackoff(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	var restarts []int
+	d := testDaemon(clock, func(n int) (worker, error) {
+		restarts = append(restarts, n)
+		if n == 2 {
+			return nil, errors.New("no such file")
+		}
+		return &fakeWorker{clock: clock, run: 10 * time.Second, err: errors.New("exit status 1")}, nil
+	})
+
+	// 启动失败同样计为崩溃，等待时长翻倍至上限，超过次数上限后放弃
+	if code := d.supervise(make(chan os.Signal, 1)); code != 1 {
+		t.Fatalf("supervise() = %d, want 1", code)
+	}
+	if want := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(restarts, want) {
+		t.Errorf("restarts = %v, want %v", restarts, want)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	if !reflect.DeepEqual(clock.sleeps, want) {
+		t.Errorf("sleeps = %v, want %v", clock.sleeps, want)
+	}
+}
+
+func TestSuperviseStableReset(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	runs := []time.Duration{10 * time.Second, 10 * time.Second, 2 * time.Minute, 10 * time.Second, 0}
+	d := testDaemon(clock, func(n int) (worker, error) {
+		w := &fakeWorker{clock: clock, run: runs[n], err: errors.New("exit status 2")}
+		if n == len(runs)-1 {
+			w.err = nil
+		}
+		return w, nil
+	})
+
+	// 稳定运行后退避与崩溃计数重置，正常退出时监督进程随之退出
+	if code := d.supervise(make(chan os.Signal, 1)); code != 0 {
+		t.Fatalf("supervise() = %d, want 0", code)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, time.Second, 2 * time.Second}
+	if !reflect.DeepEqual(clock.sleeps, want) {
+		t.Errorf("sleeps = %v, want %v", clock.sleeps, want)
+	}
+}
+
+func TestSuperviseSignals(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	w := &fakeWorker{clock: clock, exit: make(chan struct{})}
+	starts := 0
+	d := testDaemon(clock, func(n int) (worker, error) {
+		starts++
+		return w, nil
+	})
+
+	// SIGHUP 只转发，SIGTERM 转发后不再重启
+	sigs := make(chan os.Signal)
+	done := make(chan int)
+	go func() { done <- d.supervise(sigs) }()
+	sigs <- syscall.SIGHUP
+	sigs <- syscall.SIGTERM
+	if code := <-done; code != 0 || starts != 1 {
+		t.Fatalf("supervise() = %d, starts = %d", code, starts)
+	}
+	if want := []os.Signal{syscall.SIGHUP, syscall.SIGTERM}; !reflect.DeepEqual(w.signals, want) {
+		t.Errorf("forwarded = %v, want %v", w.signals, want)
+	}
+
+	// 退避等待期间收到退出信号时不再重启
+	never := &Daemon{after: func(time.Duration) <-chan time.Time { return nil }}
+	sigs = make(chan os.Signal, 2)
+	sigs <- syscall.SIGHUP
+	sigs <- syscall.SIGINT
+	if !never.sleep(time.Hour, sigs) {
+		t.Error("sleep() should stop on SIGINT")
+	}
+}
@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 加锁的pid文件，进程存活期间持有文件锁
+type PidFile struct {
+	path string
+	fp   *os.File
+}
+
+// 创建并锁定pid文件，已有进程持有时返回错误
+func CreatePidFile (path string) (*PidFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+		return nil, err
+	}
+
+	// 加锁成功前不能截断文件，否则会抹掉正在运行的进程的pid
+	fp, err := os.OpenFile(path, os.O_RDWR | os.O_CREATE, 0644)
+	if nil != err {
+		return nil, fmt.Errorf("open pid file error: %v", err)
+	}
+
+	err = syscall.Flock(int(fp.Fd()), syscall.LOCK_EX | syscall.LOCK_NB)
+	if nil != err {
+		fp.Close()
+		if pid, e := ReadPid(path); nil == e {
+			return nil, fmt.Errorf("skynet service is running, pid: %d", pid)
+		}
+		return nil, fmt.Errorf("skynet service is running")
+	}
+
+	if err = fp.Truncate(0); nil == err {
+		_, err = fp.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	}
+	if nil != err {
+		fp.Close()
+		return nil, fmt.Errorf("write pid error: %v", err)
+	}
+
+	return &PidFile{path: path, fp: fp}, nil
+}
+
+// 删除pid文件并释放锁
+func (p *PidFile) Remove () error {
+	err := os.Remove(p.path)
+	p.fp.Close()
+	return err
+}
+
+// 读取pid文件中的进程号
+func ReadPid (path string) (int, error) {
+	b, err := ioutil.ReadFile(path)
+	if nil != err {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if nil != err || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid file: %s", path)
+	}
+	return pid, nil
+}
+
+// 检查pid文件是否被存活进程锁定，并返回其进程号
+func Running (path string) (int, bool) {
+	fp, err := os.Open(path)
+	if nil != err {
+		return 0, false
+	}
+	defer fp.Close()
+
+	// 能加锁说明持有者已退出，pid文件已失效
+	if nil == syscall.Flock(int(fp.Fd()), syscall.LOCK_SH | syscall.LOCK_NB) {
+		syscall.Flock(int(fp.Fd()), syscall.LOCK_UN)
+		return 0, false
+	}
+
+	pid, err := ReadPid(path)
+	if nil != err {
+		return 0, true
+	}
+	return pid, true
+}
+
+// 向pid文件记录的存活进程发送信号，返回其进程号
+func Signal (path string, sig syscall.Signal) (int, error) {
+	pid, ok := Running(path)
+	if !ok {
+		return 0, fmt.Errorf("skynet service is not running")
+	}
+	if pid <= 0 {
+		return 0, fmt.Errorf("invalid pid file: %s", path)
+	}
+	if err := syscall.Kill(pid, sig); nil != err {
+		return pid, fmt.Errorf("send %v to pid %d error: %v", sig, pid, err)
+	}
+	return pid, nil
+}
+
+// 强制结束进程；进程为进程组组长时(如监督进程)一并结束其工作进程
+func Kill (pid int) error {
+	if pgid, err := syscall.Getpgid(pid); nil == err && pgid == pid {
+		return syscall.Kill(-pid, syscall.SIGKILL)
+	}
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
+
+// 等待pid文件的持有者退出，超时返回 false
+func WaitExit (path string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, ok := Running(path); !ok {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestPidFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "skynet.pid")
+	pf, err := CreatePidFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := ReadPid(path); err != nil || pid != os.Getpid() {
+		t.Fatalf("ReadPid() = %d, %v", pid, err)
+	}
+
+	// 已被持有时失败，且不抹掉持有者的 pid
+	if _, err := CreatePidFile(path); err == nil || !strings.Contains(err.Error(), "running, pid: ") {
+		t.Fatalf("second CreatePidFile() = %v", err)
+	}
+	if pid, ok := Running(path); !ok || pid != os.Getpid() {
+		t.Fatalf("Running() = %d, %v", pid, ok)
+	}
+
+	if err := pf.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Running(path); ok {
+		t.Fatal("Running() after Remove()")
+	}
+	pf, err = CreatePidFile(path)
+	if err != nil {
+		t.Fatalf("CreatePidFile() after Remove() = %v", err)
+	}
+	pf.Remove()
+}
+
+func TestStalePidFile(t *testing.T) {
+	// 进程异常退出后留下的 pid 文件未被锁定，视为已失效
+	path := filepath.Join(t.TempDir(), "skynet.pid")
+	if err := ioutil.WriteFile(path, []byte("999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pid, ok := Running(path); ok {
+		t.Fatalf("Running() = %d, true", pid)
+	}
+	if _, err := Signal(path, syscall.SIGHUP); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Fatalf("Signal() = %v", err)
+	}
+
+	pf, err := CreatePidFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pf.Remove()
+	if pid, err := ReadPid(path); err != nil || pid != os.Getpid() {
+		t.Fatalf("ReadPid() = %d, %v", pid, err)
+	}
+
+	if err := ioutil.WriteFile(path+".bad", []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadPid(path + ".bad"); err == nil {
+		t.Fatal("ReadPid() should reject an invalid pid file")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/cookiejar"
+	"time"
 
 	"skynet-service/app/config"
 	"skynet-service/app/downloader/request"
@@ -29,13 +30,20 @@ func (self *Surfer) Download(sp *spider.Spider, cReq *request.Request) *spider.C
 
 	var resp *http.Response
 	var err error
+	var start = time.Now()
 
 	switch cReq.GetDownloaderID() {
 	case request.SURF_ID:
 		resp, err = self.surf.Download(cReq)
+		downloadSeconds.Observe(time.Since(start).Seconds(), "surf")
 
 	case request.PHANTOM_ID:
 		resp, err = self.phantom.Download(cReq)
+		downloadSeconds.Observe(time.Since(start).Seconds(), "phantom")
+	}
+
+	if resp.Body != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, spider: sp.GetName()}
 	}
 
 	if resp.StatusCode >= 400 {
@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"io"
+	"sync/atomic"
+
+	"skynet-service/app/runtime/metrics"
+)
+
+var (
+	downloadSeconds = metrics.NewHistogram("skynet_download_duration_seconds",
+		"Time spent waiting for response headers, by downloader.", metrics.DefBuckets, "downloader")
+	downloadBytes = metrics.NewCounter("skynet_download_bytes_total",
+		"Response body bytes read, by spider.", "spider")
+)
+
+// 统计读取字节数的响应体，关闭时计入指标
+type countingBody struct {
+	io.ReadCloser
+	spider string
+	n      int64
+}
+
+func (self *countingBody) Read(p []byte) (int, error) {
+	n, err := self.ReadCloser.Read(p)
+	atomic.AddInt64(&self.n, int64(n))
+	return n, err
+}
+
+func (self *countingBody) Close() error {
+	if n := atomic.SwapInt64(&self.n, 0); n > 0 {
+		downloadBytes.Add(float64(n), self.spider)
+	}
+	return self.ReadCloser.Close()
+}
@@ -52,7 +52,10 @@ func NewParam(req Request) (param *Param, err error) {
 		param.method = method
 	case "POST":
 		param.method = method
-		param.header.Add("Content-Type", "application/x-www-form-urlencoded")
+		// 已指定 Content-Type 时(如 JSON)不覆盖
+		if param.header.Get("Content-Type") == "" {
+			param.header.Add("Content-Type", "application/x-www-form-urlencoded")
+		}
 		param.body = strings.NewReader(req.GetPostData())
 	case "POST-M":
 		param.method = "POST"
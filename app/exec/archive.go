@@ -0,0 +1,19 @@
+package exec
+
+import (
+	"fmt"
+	"time"
+
+	"skynet-service/app/pipeline/archive"
+)
+
+// archive 命令，立即汇总 mysql 输出的原始数据并清理超过保留时长的部分
+func runArchive () {
+	start := time.Now()
+	r, err := archive.Run(start)
+	if nil != err {
+		exitWithError(err)
+	}
+	fmt.Printf("archived %d tables: %d rows re-aggregated, %d hours, %d days updated, %d rows pruned in %v\n",
+		r.Tables, r.Rows, r.Hours, r.Days, r.Pruned, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,406 @@
+package exec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	cli "github.com/jawher/mow.cli"
+
+	"skynet-service/app"
+	"skynet-service/app/config"
+	"skynet-service/app/daemon"
+	"skynet-service/app/runtime/status"
+)
+
+// status 命令在服务未运行时的退出码(同 LSB 约定)
+const exitNotRunning = 3
+
+// 覆盖 cache.AppConf 的命令行参数
+type taskFlags struct {
+	mode       *string
+	thread     *int
+	pause      *int
+	outType    *string
+	limit      *int
+	keyins     *string
+	modeSet    bool
+	threadSet  bool
+	pauseSet   bool
+	outTypeSet bool
+	limitSet   bool
+	keyinsSet  bool
+}
+
+// 构建命令行
+func newCli () *cli.Cli {
+	c := cli.App(config.NAME, config.FullName)
+	c.Version("v version", config.FullName)
+
+	config.RunMode = c.String(cli.StringOpt{
+		Name:  "r runmode",
+		Value: "console",
+		Desc:  "运行方式：console | daemon",
+	})
+	config.ServerHost = c.String(cli.StringOpt{
+		Name:  "host",
+		Value: "127.0.0.1",
+		Desc:  "网页服务监听地址",
+	})
+	config.ServerPort = c.Int(cli.IntOpt{
+		Name:  "port",
+		Value: 9090,
+		Desc:  "网页服务监听端口",
+	})
+	config.ServerEnable = c.Bool(cli.BoolOpt{
+		Name:  "http",
+		Desc:  "执行任务时同时开启HTTP控制接口",
+	})
+
+	c.Before = func() {
+		runType, err := parseRunMode(*config.RunMode)
+		if nil != err {
+			exitWithError(err)
+		}
+		app.LogicApp.SetAppConf("RunType", runType)
+	}
+
+	c.Command("run", "运行爬虫任务，未指定蜘蛛时运行全部蜘蛛", cmdRun)
+	c.Command("cron", "按配置文件 [cron] 段定时运行蜘蛛", cmdCron)
+	c.Command("server", "以服务器模式运行，向客户端分发任务", cmdServer)
+	c.Command("client", "以客户端模式运行，从服务器领取任务", cmdClient)
+	c.Command("web", "仅开启HTTP控制接口，由接口远程运行任务", cli.ActionCommand(runWeb))
+	c.Command("status", "查看服务运行状态，未运行时退出码为3", cli.ActionCommand(serviceStatus))
+	c.Command("stop", "停止正在运行的服务", cmdStop)
+	c.Command("reload", "通知正在运行的服务重新加载配置文件", cli.ActionCommand(reloadService))
+	c.Command("user", "管理HTTP控制接口的用户", cmdUser)
+	c.Command("runs", "查看历次任务的运行记录", cmdRuns)
+	c.Command("notify", "查看或立即发送邮件摘要", cmdNotify)
+	c.Command("archive", "立即归档mysql输出的数据，按小时及天汇总数值字段", cli.ActionCommand(runArchive))
+	c.Command("list-spiders", "列出全部蜘蛛", cli.ActionCommand(listSpiders))
+	c.Command("list-outputs", "列出全部输出方式", cli.ActionCommand(listOutputs))
+	c.Command("validate-spiders", "检查动态规则文件，报告问题所在的行", cmdValidateSpiders)
+
+	// 未指定子命令时按配置文件运行全部蜘蛛
+	c.Action = func() {
+		if err := RunSpider(); nil != err {
+			exitWithError(err)
+		}
+	}
+
+	return c
+}
+
+// run 命令
+func cmdRun (cmd *cli.Cmd) {
+	cmd.Spec = "[OPTIONS] [SPIDER...]"
+
+	flags := addTaskFlags(cmd, true)
+	schedule := cmd.String(cli.StringOpt{
+		Name: "s schedule",
+		Desc: "定时运行，如 \"@every 5m\" 或 \"*/5 * * * *\"",
+	})
+	names := cmd.StringsArg("SPIDER", nil, "要运行的蜘蛛名称")
+
+	cmd.Action = func() {
+		if err := flags.apply(); nil != err {
+			exitWithError(err)
+		}
+		overrides = flags
+
+		var err error
+		if *schedule == "" {
+			err = RunSpider(*names...)
+		} else {
+			err = RunCron(scheduleAll(*names, *schedule))
+		}
+		if nil != err {
+			exitWithError(err)
+		}
+	}
+}
+
+// cron 命令
+func cmdCron (cmd *cli.Cmd) {
+	flags := addTaskFlags(cmd, true)
+
+	cmd.Action = func() {
+		if err := flags.apply(); nil != err {
+			exitWithError(err)
+		}
+		overrides = flags
+		cronFromConfig = true
+		if err := RunCron(config.Current().CronJobs); nil != err {
+			exitWithError(err)
+		}
+	}
+}
+
+// server 命令
+func cmdServer (cmd *cli.Cmd) {
+	cmd.Spec = "[OPTIONS] [SPIDER...]"
+
+	flags := addTaskFlags(cmd, false)
+	port := cmd.Int(cli.IntOpt{
+		Name:  "P node-port",
+		Value: app.LogicApp.GetAppConf("Port").(int),
+		Desc:  "分布式通信端口",
+	})
+	names := cmd.StringsArg("SPIDER", nil, "要分发的蜘蛛名称")
+
+	cmd.Action = func() {
+		if err := flags.apply(); nil != err {
+			exitWithError(err)
+		}
+		overrides = flags
+		app.LogicApp.SetAppConf("Mode", status.SERVER)
+		app.LogicApp.SetAppConf("Port", *port)
+		if err := RunSpider(*names...); nil != err {
+			exitWithError(err)
+		}
+	}
+}
+
+// client 命令
+func cmdClient (cmd *cli.Cmd) {
+	master := cmd.String(cli.StringOpt{
+		Name:  "M master",
+		Value: app.LogicApp.GetAppConf("Master").(string),
+		Desc:  "服务器地址，不含端口",
+	})
+	port := cmd.Int(cli.IntOpt{
+		Name:  "P node-port",
+		Value: app.LogicApp.GetAppConf("Port").(int),
+		Desc:  "分布式通信端口",
+	})
+
+	cmd.Action = func() {
+		app.LogicApp.SetAppConf("Mode", status.CLIENT)
+		app.LogicApp.SetAppConf("Master", *master)
+		app.LogicApp.SetAppConf("Port", *port)
+		if err := RunSpider(); nil != err {
+			exitWithError(err)
+		}
+	}
+}
+
+// web 命令
+func runWeb () {
+	if err := RunWeb(); nil != err {
+		exitWithError(err)
+	}
+}
+
+// status 命令
+func serviceStatus () {
+	if pid, ok := daemon.Running(config.PID_FILE); ok {
+		fmt.Printf("skynet service is running, pid: %d, pid file: %s\n", pid, config.PID_FILE)
+		return
+	}
+	if pid, err := daemon.ReadPid(config.PID_FILE); nil == err {
+		fmt.Printf("skynet service is not running, stale pid file: %s (pid: %d)\n", config.PID_FILE, pid)
+	} else {
+		fmt.Println("skynet service is not running")
+	}
+	cli.Exit(exitNotRunning)
+}
+
+// stop 命令：发送 SIGTERM 并等待退出，超时后可强制结束
+func cmdStop (cmd *cli.Cmd) {
+	timeout := cmd.Int(cli.IntOpt{
+		Name:  "t timeout",
+		Value: 30,
+		Desc:  "等待退出的秒数",
+	})
+	force := cmd.Bool(cli.BoolOpt{
+		Name:  "f force",
+		Desc:  "超时后强制结束进程",
+	})
+
+	cmd.Action = func() {
+		pid, err := daemon.Signal(config.PID_FILE, syscall.SIGTERM)
+		if nil != err {
+			if _, ok := daemon.Running(config.PID_FILE); !ok {
+				fmt.Println(err)
+				return
+			}
+			exitWithError(err)
+		}
+		fmt.Printf("stopping skynet service, pid: %d ...\n", pid)
+		if daemon.WaitExit(config.PID_FILE, time.Duration(*timeout) * time.Second) {
+			fmt.Println("skynet service stopped")
+			return
+		}
+		if !*force {
+			exitWithError(fmt.Errorf("skynet service pid: %d did not exit in %ds", pid, *timeout))
+		}
+		if err = daemon.Kill(pid); nil != err {
+			exitWithError(fmt.Errorf("kill pid %d error: %v", pid, err))
+		}
+		if !daemon.WaitExit(config.PID_FILE, 5 * time.Second) {
+			exitWithError(fmt.Errorf("skynet service pid: %d is still running", pid))
+		}
+		fmt.Println("skynet service killed")
+	}
+}
+
+// reload 命令
+func reloadService () {
+	pid, err := daemon.Signal(config.PID_FILE, syscall.SIGHUP)
+	if nil != err {
+		exitWithError(err)
+	}
+	fmt.Printf("reload signal sent to skynet service, pid: %d\n", pid)
+}
+
+// list-spiders 命令
+func listSpiders () {
+	for _, sp := range app.LogicApp.GetSpiderLib() {
+		fmt.Printf("%s\t%s\n", sp.GetName(), sp.GetDescription())
+	}
+}
+
+// list-outputs 命令
+func listOutputs () {
+	for _, out := range app.LogicApp.GetOutputLib() {
+		fmt.Println(out)
+	}
+}
+
+// 为每个蜘蛛设置相同的调度，names 为空时为全部蜘蛛
+func scheduleAll (names []string, spec string) map[string]string {
+	if len(names) == 0 {
+		for _, sp := range app.LogicApp.GetSpiderLib() {
+			names = append(names, sp.GetName())
+		}
+	}
+	jobs := make(map[string]string, len(names))
+	for _, name := range names {
+		jobs[name] = spec
+	}
+	return jobs
+}
+
+// 为命令添加任务参数，withMode 为 true 时允许指定节点角色
+func addTaskFlags (cmd *cli.Cmd, withMode bool) *taskFlags {
+	f := &taskFlags{}
+	if withMode {
+		f.mode = cmd.String(cli.StringOpt{
+			Name:      "m mode",
+			Desc:      "节点角色：offline | server | client",
+			SetByUser: &f.modeSet,
+		})
+	}
+	f.thread = cmd.Int(cli.IntOpt{
+		Name:      "t thread",
+		Value:     app.LogicApp.GetAppConf("ThreadNum").(int),
+		Desc:      "全局最大并发量",
+		SetByUser: &f.threadSet,
+	})
+	f.pause = cmd.Int(cli.IntOpt{
+		Name:      "p pause",
+		Value:     int(app.LogicApp.GetAppConf("Pausetime").(int64)),
+		Desc:      "暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)",
+		SetByUser: &f.pauseSet,
+	})
+	f.outType = cmd.String(cli.StringOpt{
+		Name:      "o outtype",
+		Value:     app.LogicApp.GetAppConf("OutType").(string),
+		Desc:      "输出方式：" + strings.Join(app.LogicApp.GetOutputLib(), " | "),
+		SetByUser: &f.outTypeSet,
+	})
+	f.limit = cmd.Int(cli.IntOpt{
+		Name:      "l limit",
+		Value:     int(app.LogicApp.GetAppConf("Limit").(int64)),
+		Desc:      "采集上限，0为不限",
+		SetByUser: &f.limitSet,
+	})
+	f.keyins = cmd.String(cli.StringOpt{
+		Name:      "k keyins",
+		Desc:      "自定义输入，形如 <a><b>",
+		SetByUser: &f.keyinsSet,
+	})
+	return f
+}
+
+// 将用户显式指定的参数写入全局配置
+func (self *taskFlags) apply () error {
+	if self.modeSet {
+		mode, err := parseMode(*self.mode)
+		if nil != err {
+			return err
+		}
+		app.LogicApp.SetAppConf("Mode", mode)
+	}
+	if self.threadSet {
+		if *self.thread <= 0 {
+			return fmt.Errorf("invalid thread: %d", *self.thread)
+		}
+		app.LogicApp.SetAppConf("ThreadNum", *self.thread)
+	}
+	if self.pauseSet {
+		if *self.pause < 0 {
+			return fmt.Errorf("invalid pause: %d", *self.pause)
+		}
+		app.LogicApp.SetAppConf("Pausetime", int64(*self.pause))
+	}
+	if self.outTypeSet {
+		if !validOutType(*self.outType) {
+			return fmt.Errorf("unknown outtype: %s", *self.outType)
+		}
+		app.LogicApp.SetAppConf("OutType", *self.outType)
+	}
+	if self.limitSet {
+		if *self.limit < 0 {
+			return fmt.Errorf("invalid limit: %d", *self.limit)
+		}
+		app.LogicApp.SetAppConf("Limit", int64(*self.limit))
+	}
+	if self.keyinsSet {
+		app.LogicApp.SetAppConf("Keyins", *self.keyins)
+	}
+	return nil
+}
+
+// 解析节点角色，支持名称与数字
+func parseMode (s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "offline":
+		return status.OFFLINE, nil
+	case "server":
+		return status.SERVER, nil
+	case "client":
+		return status.CLIENT, nil
+	}
+	if m, err := strconv.Atoi(s); nil == err && m >= status.OFFLINE && m <= status.CLIENT {
+		return m, nil
+	}
+	return status.UNSET, fmt.Errorf("unknown mode: %s", s)
+}
+
+// 解析运行方式
+func parseRunMode (s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "console":
+		return status.CONSOLE, nil
+	case "daemon":
+		return status.DAEMON, nil
+	}
+	return status.CONSOLE, fmt.Errorf("unknown runmode: %s", s)
+}
+
+func validOutType (outType string) bool {
+	for _, out := range app.LogicApp.GetOutputLib() {
+		if out == outType {
+			return true
+		}
+	}
+	return false
+}
+
+func exitWithError (err error) {
+	fmt.Printf("%v\n", err)
+	cli.Exit(1)
+}
@@ -1,9 +1,17 @@
 package exec
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"runtime"
+	"strings"
+
 	"skynet-service/app"
 	"skynet-service/app/common/gc"
+	"skynet-service/app/config"
+	"skynet-service/app/cron"
+	"skynet-service/app/runtime/status"
 	"skynet-service/app/spider"
 )
 
@@ -12,39 +20,165 @@ func init() {
 	gc.ManualGC()										// 开启手动GC
 }
 
+// 解析命令行并执行对应命令
 func Run () {
-	// 启动网页显示服务
+	newCli().Run(os.Args)
+}
 
-	// 启动爬虫任务
-	RunSpider()
+// 启动 spider
+// names 为空时运行全部蜘蛛
+func RunSpider(names ...string) error {
+	spiders, err := GetSpiders(names...)
+	if nil != err {
+		return err
+	}
 
-	// 启动所有 spider
-	//for true {
-	//	;
-	//}
+	if err = startService(); nil != err {
+		return err
+	}
+	defer stopService()
+
+	app.LogicApp.Init()
+	if *config.ServerEnable {
+		if err = startWeb(); nil != err {
+			return err
+		}
+	}
+
+	// 客户端模式下由服务端下发任务
+	if app.LogicApp.GetAppConf("Mode").(int) == status.CLIENT {
+		app.LogicApp.Run()
+		return nil
+	}
+
+	// Web 接口已开启时可能先一步发起了任务
+	if err = app.LogicApp.TryPrepare(spiders); nil != err {
+		return err
+	}
+	app.LogicApp.Run()
 
-	// 开始运行
-	//ctrl := make(chan os.Signal, 1)
-	//signal.Notify(ctrl, os.Interrupt, os.Kill)
-	////go web.Run()
-	//<-ctrl
+	// 服务端需保持运行，等待客户端领取任务
+	if app.LogicApp.GetAppConf("Mode").(int) == status.SERVER {
+		waitSignal()
+	}
+
+	return nil
 }
 
-// FIXME:// 此处需要根据命令行进行配置
-// 启动 spider
-func RunSpider() {
+// 按计划定时运行蜘蛛，阻塞直至收到退出信号
+// jobs 的键为蜘蛛名称，值为调度描述
+func RunCron(jobs map[string]string) error {
+	if app.LogicApp.GetAppConf("Mode").(int) == status.CLIENT {
+		return errors.New("cron is not supported in client mode")
+	}
+
+	c := cron.New(app.LogicApp)
+	if err := replaceJobs(c, jobs); nil != err {
+		return err
+	}
+	crontab = c
+
+	if err := startService(); nil != err {
+		return err
+	}
+	defer stopService()
+
 	app.LogicApp.Init()
+	if *config.ServerEnable {
+		if err := startWeb(); nil != err {
+			return err
+		}
+	}
+	if err := startNotify(); nil != err {
+		return err
+	}
+	if err := startArchive(); nil != err {
+		return err
+	}
+	startSpiderWatch()
+	c.Start()
+	waitSignal()
+	c.Stop()
 
-	GetAllSpider()
+	return nil
+}
 
-	app.LogicApp.Run()
+// 仅开启HTTP控制接口，由接口远程运行任务，阻塞直至收到退出信号
+func RunWeb() error {
+	if app.LogicApp.GetAppConf("Mode").(int) == status.CLIENT {
+		return errors.New("web is not supported in client mode")
+	}
+
+	if err := startService(); nil != err {
+		return err
+	}
+	defer stopService()
+
+	app.LogicApp.Init()
+	if err := startWeb(); nil != err {
+		return err
+	}
+	if err := startNotify(); nil != err {
+		return err
+	}
+	if err := startArchive(); nil != err {
+		return err
+	}
+	startSpiderWatch()
+	waitSignal()
+
+	return nil
 }
 
-// 扫描爬虫
-func GetAllSpider () {
+// 按名称选取蜘蛛，names 为空时返回全部蜘蛛
+func GetSpiders (names ...string) ([]*spider.Spider, error) {
 	var spiders []*spider.Spider
-	for _, sp := range app.LogicApp.GetSpiderLib() {
+	if len(names) == 0 {
+		for _, sp := range app.LogicApp.GetSpiderLib() {
+			spiders = append(spiders, sp.Copy())
+		}
+		return spiders, nil
+	}
+
+	for _, name := range names {
+		sp := findSpider(name)
+		if nil == sp {
+			return nil, fmt.Errorf("spider not found: %s", name)
+		}
 		spiders = append(spiders, sp.Copy())
 	}
-	app.LogicApp.SpiderPrepare(spiders)
-}
\ No newline at end of file
+	return spiders, nil
+}
+
+// 按名称查找蜘蛛，精确匹配失败时忽略大小写
+// 以 jobs 替换调度器中的全部定时任务，蜘蛛名称不区分大小写
+func replaceJobs (c *cron.Cron, jobs map[string]string) error {
+	specs := make(map[string]string, len(jobs))
+	for name, spec := range jobs {
+		sp := findSpider(name)
+		if nil == sp {
+			return fmt.Errorf("spider not found: %s", name)
+		}
+		if _, ok := specs[sp.GetName()]; ok {
+			return fmt.Errorf("duplicate job for spider: %s", sp.GetName())
+		}
+		specs[sp.GetName()] = spec
+	}
+	if len(specs) == 0 {
+		return errors.New("no cron jobs configured")
+	}
+	return c.Replace(specs)
+}
+
+func findSpider (name string) *spider.Spider {
+	if sp := app.LogicApp.GetSpiderByName(name); nil != sp {
+		return sp
+	}
+	for _, sp := range app.LogicApp.GetSpiderLib() {
+		if strings.EqualFold(sp.GetName(), name) {
+			return sp
+		}
+	}
+	return nil
+}
+
@@ -0,0 +1,57 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+
+	"skynet-service/app"
+	"skynet-service/app/config"
+	"skynet-service/app/notify"
+)
+
+// notify 命令，摘要配置见 config.NOTIFY_FILE
+func cmdNotify (cmd *cli.Cmd) {
+	cmd.Command("list", "列出全部邮件摘要", cli.ActionCommand(listDigests))
+	cmd.Command("send", "立即生成并发送邮件摘要", cmdNotifySend)
+}
+
+func listDigests () {
+	n := notify.New(app.LogicApp)
+	if err := n.Reload(); nil != err {
+		exitWithError(err)
+	}
+	for _, d := range n.Digests() {
+		fmt.Printf("%s\t%s\tnext: %s\t%s\n", d.Name, d.Schedule,
+			n.Next(d).Format("2006-01-02 15:04:05"), strings.Join(d.To, ","))
+	}
+}
+
+func cmdNotifySend (cmd *cli.Cmd) {
+	cmd.Spec = "[OPTIONS] NAME"
+	dryRun := cmd.Bool(cli.BoolOpt{
+		Name: "n dry-run",
+		Desc: "只输出纯文本摘要，不发送",
+	})
+	name := cmd.StringArg("NAME", "", "摘要名称")
+
+	cmd.Action = func() {
+		n := notify.New(app.LogicApp)
+		if err := n.Reload(); nil != err {
+			exitWithError(err)
+		}
+		if *dryRun {
+			msg, err := n.Preview(*name)
+			if nil != err {
+				exitWithError(err)
+			}
+			fmt.Printf("Subject: %s\nTo: %s\n\n%s", msg.Subject, strings.Join(msg.To, ", "), msg.Text)
+			return
+		}
+		if err := n.Send(*name); nil != err {
+			exitWithError(err)
+		}
+		fmt.Printf("digest %s sent via %s\n", *name, config.Current().SmtpHost)
+	}
+}
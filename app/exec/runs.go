@@ -0,0 +1,68 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	cli "github.com/jawher/mow.cli"
+
+	"skynet-service/app/runtime/runs"
+)
+
+// runs 命令
+func cmdRuns (cmd *cli.Cmd) {
+	cmd.Command("list", "按时间倒序列出运行记录", cmdRunsList)
+	cmd.Command("show", "查看一条运行记录的详细信息", cmdRunsShow)
+}
+
+func cmdRunsList (cmd *cli.Cmd) {
+	limit := cmd.Int(cli.IntOpt{
+		Name:  "n limit",
+		Value: 20,
+		Desc:  "最多列出的记录数，0为不限",
+	})
+	spider := cmd.String(cli.StringOpt{
+		Name: "s spider",
+		Desc: "只列出包含该蜘蛛的记录",
+	})
+
+	cmd.Action = func() {
+		list, err := runs.Runs.List(*spider, *limit)
+		if nil != err {
+			exitWithError(err)
+		}
+		fmt.Println("ID\tSTART\tDURATION\tREASON\tITEMS\tFILES\tSUCCESS\tFAILURE\tERRORS\tSPIDERS")
+		for _, run := range list {
+			names := make([]string, 0, len(run.Spiders))
+			for _, sp := range run.Spiders {
+				if sp.Keyin != "" {
+					names = append(names, sp.Name+"("+sp.Keyin+")")
+				} else {
+					names = append(names, sp.Name)
+				}
+			}
+			fmt.Printf("%s\t%s\t%v\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+				run.ID, run.Start.Format("2006-01-02 15:04:05"), run.End.Sub(run.Start).Round(time.Second),
+				run.StopReason, run.Items, run.Files, run.Success, run.Failure, run.ErrorNum, strings.Join(names, ","))
+		}
+	}
+}
+
+func cmdRunsShow (cmd *cli.Cmd) {
+	id := cmd.StringArg("ID", "", "运行记录id")
+
+	cmd.Action = func() {
+		run, err := runs.Runs.Get(*id)
+		if os.IsNotExist(err) {
+			exitWithError(fmt.Errorf("run not found: %s", *id))
+		}
+		if nil != err {
+			exitWithError(err)
+		}
+		b, _ := json.MarshalIndent(run, "", "  ")
+		fmt.Println(string(b))
+	}
+}
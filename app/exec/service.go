@@ -0,0 +1,228 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"net"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"skynet-service/app"
+	"skynet-service/app/alert"
+	"skynet-service/app/config"
+	"skynet-service/app/cron"
+	"skynet-service/app/daemon"
+	"skynet-service/app/logs"
+	"skynet-service/app/notify"
+	"skynet-service/app/notify/webhook"
+	"skynet-service/app/pipeline/archive"
+	"skynet-service/app/runtime/status"
+	"skynet-service/app/runtime/user"
+	"skynet-service/app/scheduler"
+	"skynet-service/app/spider"
+	"skynet-service/app/web"
+)
+
+var (
+	pidFile        *daemon.PidFile
+	webServer      *web.Server
+	notifier       *notify.Notifier
+	archiver       *archive.Archiver
+	quit           = make(chan struct{}) // 收到退出信号时关闭
+	quitOnce       sync.Once
+	overrides      *taskFlags // 命令行覆盖的参数，重新加载配置后再次应用
+	crontab        *cron.Cron // 定时调度器，须在监听信号之前设置
+	cronFromConfig bool       // 定时任务来自配置文件 [cron] 段，重新加载配置时随之更新
+)
+
+// 启动服务进程，须在执行任务前调用。
+// daemon 方式下原进程转入后台后退出，后台的监督进程循环拉起工作进程，
+// 只有工作进程会从该函数返回。
+func startService() error {
+	if app.LogicApp.GetAppConf("RunType").(int) == status.DAEMON {
+		switch daemon.Role() {
+		case daemon.RoleWorker:
+			watchSignals()
+			return nil
+
+		case daemon.RoleSupervisor:
+			pf, err := daemon.CreatePidFile(config.PID_FILE)
+			if nil != err {
+				logs.Log.Error("%v", err)
+				os.Exit(1)
+			}
+			code := daemon.NewDaemon().Run()
+			pf.Remove()
+			logs.Log.Close()
+			os.Exit(code)
+
+		default:
+			// 转入后台前检查，保证错误能输出到终端
+			pf, err := daemon.CreatePidFile(config.PID_FILE)
+			if nil != err {
+				return err
+			}
+			pf.Remove()
+			return daemon.Background()
+		}
+	}
+
+	pf, err := daemon.CreatePidFile(config.PID_FILE)
+	if nil != err {
+		return err
+	}
+	pidFile = pf
+	watchSignals()
+	return nil
+}
+
+// 开启HTTP控制接口，须在 app.LogicApp.Init() 之后调用
+func startWeb() error {
+	webServer = web.New(app.LogicApp)
+	addr := net.JoinHostPort(*config.ServerHost, strconv.Itoa(*config.ServerPort))
+	if err := webServer.Start(addr); nil != err {
+		webServer = nil
+		return err
+	}
+	return nil
+}
+
+// 按摘要配置文件定时发送邮件摘要，未配置摘要时不启动
+func startNotify() error {
+	n := notify.New(app.LogicApp)
+	if err := n.Reload(); nil != err {
+		return err
+	}
+	notifier = n
+	notifier.Start()
+	if digests := notifier.Digests(); len(digests) > 0 {
+		logs.Log.Informational(" *     已开启 %v 个邮件摘要", len(digests))
+	}
+	return nil
+}
+
+// 按 [archive] 段的调度描述定时归档，未设置时不启动
+func startArchive() error {
+	if config.Current().ArchiveSchedule == "" {
+		return nil
+	}
+	schedule, err := cron.Parse(config.Current().ArchiveSchedule)
+	if nil != err {
+		return fmt.Errorf("archive schedule: %v", err)
+	}
+	archiver = archive.New(schedule)
+	archiver.Start()
+	logs.Log.Informational(" *     已开启定时归档：%s", config.Current().ArchiveSchedule)
+	return nil
+}
+
+// 按 spiderreload 的间隔检查动态规则目录，新增、修改或删除的规则在下一次任务中生效
+func startSpiderWatch() {
+	if config.Current().SpiderReload <= 0 {
+		return
+	}
+	spider.Dynamic.Start(time.Duration(config.Current().SpiderReload) * time.Second)
+	logs.Log.Informational(" *     已开启动态规则热加载：%s", config.SPIDER_DIR)
+}
+
+// 服务退出前的收尾工作
+func stopService() {
+	spider.Dynamic.Stop()
+	if nil != webServer {
+		webServer.Stop()
+		webServer = nil
+	}
+	if nil != notifier {
+		notifier.Stop()
+		notifier = nil
+	}
+	if nil != archiver {
+		archiver.Stop()
+		archiver = nil
+	}
+	webhook.Close()
+	if nil != pidFile {
+		pidFile.Remove()
+		pidFile = nil
+	}
+	logs.Log.Close()
+}
+
+// 监听系统信号：SIGINT/SIGTERM 终止任务并退出，再次收到时强制退出；SIGHUP 重新加载配置
+func watchSignals() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		stopping := false
+		for sig := range sigs {
+			if sig == syscall.SIGHUP {
+				reload()
+				continue
+			}
+			if stopping {
+				logs.Log.Warning(" *     再次收到信号 %v，强制退出", sig)
+				os.Exit(1)
+			}
+			stopping = true
+			logs.Log.Informational(" *     收到信号 %v，正在终止任务...", sig)
+			quitOnce.Do(func() { close(quit) })
+			go app.LogicApp.Stop()
+		}
+	}()
+}
+
+// 阻塞直至收到退出信号
+func waitSignal () {
+	<-quit
+}
+
+// 重新加载配置文件
+func reload() {
+	if err := config.Reload(); nil != err {
+		logs.Log.Error(" *     重新加载配置失败：%v", err)
+		return
+	}
+	app.LogicApp.ReloadAppConf(config.NewTask())
+	if nil != overrides {
+		if err := overrides.apply(); nil != err {
+			logs.Log.Error(" *     重新应用命令行参数失败：%v", err)
+		}
+	}
+	scheduler.ReloadProxyLib()
+	if err := user.GAllUsers.Load(); nil != err {
+		logs.Log.Error(" *     重新读取用户文件失败：%v", err)
+	}
+	if nil != notifier {
+		if err := notifier.Reload(); nil != err {
+			logs.Log.Error(" *     重新读取邮件摘要配置失败：%v", err)
+		}
+	}
+	if nil != archiver {
+		archiver.Stop()
+		archiver = nil
+		if err := startArchive(); nil != err {
+			logs.Log.Error(" *     重新开启定时归档失败：%v", err)
+		}
+	}
+	if err := webhook.Load(config.WEBHOOK_FILE); nil != err {
+		logs.Log.Error(" *     重新读取 Webhook 配置失败：%v", err)
+	}
+	if err := alert.Alerts.Load(config.ALERT_FILE); nil != err {
+		logs.Log.Error(" *     重新读取告警配置失败：%v", err)
+	}
+	spider.Dynamic.Scan()
+	if spider.Dynamic.Running() {
+		spider.Dynamic.Stop()
+		startSpiderWatch()
+	}
+	// 在重新扫描动态规则之后，以便引用新增的蜘蛛
+	if nil != crontab && cronFromConfig {
+		if err := replaceJobs(crontab, config.Current().CronJobs); nil != err {
+			logs.Log.Error(" *     重新设置定时任务失败，保留原有任务：%v", err)
+		}
+	}
+	logs.Log.Informational(" *     配置已重新加载，将在下一次任务中生效")
+}
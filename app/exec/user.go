@@ -0,0 +1,102 @@
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+
+	"skynet-service/app/runtime/user"
+)
+
+// user 命令，修改后通知运行中的服务 reload 生效
+func cmdUser (cmd *cli.Cmd) {
+	cmd.Command("add", "添加用户，从标准输入读取密码", cmdUserAdd)
+	cmd.Command("del", "删除用户", cmdUserDel)
+	cmd.Command("passwd", "修改密码，从标准输入读取密码", cmdUserPasswd)
+	cmd.Command("role", "修改用户角色", cmdUserRole)
+	cmd.Command("list", "列出全部用户", cli.ActionCommand(listUsers))
+}
+
+func cmdUserAdd (cmd *cli.Cmd) {
+	cmd.Spec = "[OPTIONS] NAME"
+	role := cmd.String(cli.StringOpt{
+		Name:  "R role",
+		Value: user.RoleViewer,
+		Desc:  "角色：" + user.RoleViewer + " | " + user.RoleOperator,
+	})
+	name := cmd.StringArg("NAME", "", "用户名")
+
+	cmd.Action = func() {
+		if !user.ValidRole(*role) {
+			exitWithError(fmt.Errorf("unknown role: %s", *role))
+		}
+		password, err := readPassword()
+		if nil != err {
+			exitWithError(err)
+		}
+		if err = user.GAllUsers.AddUser(*name, password, *role); nil != err {
+			exitWithError(err)
+		}
+		fmt.Printf("user %s added\n", *name)
+	}
+}
+
+func cmdUserDel (cmd *cli.Cmd) {
+	name := cmd.StringArg("NAME", "", "用户名")
+
+	cmd.Action = func() {
+		if err := user.GAllUsers.DelUser(*name); nil != err {
+			exitWithError(err)
+		}
+		fmt.Printf("user %s deleted\n", *name)
+	}
+}
+
+func cmdUserPasswd (cmd *cli.Cmd) {
+	name := cmd.StringArg("NAME", "", "用户名")
+
+	cmd.Action = func() {
+		if _, ok := user.GAllUsers.GetUser(*name); !ok {
+			exitWithError(fmt.Errorf("user not found: %s", *name))
+		}
+		password, err := readPassword()
+		if nil != err {
+			exitWithError(err)
+		}
+		if err = user.GAllUsers.SetPassword(*name, password); nil != err {
+			exitWithError(err)
+		}
+		fmt.Printf("password of user %s changed\n", *name)
+	}
+}
+
+func cmdUserRole (cmd *cli.Cmd) {
+	name := cmd.StringArg("NAME", "", "用户名")
+	role := cmd.StringArg("ROLE", "", "角色：" + user.RoleViewer + " | " + user.RoleOperator)
+
+	cmd.Action = func() {
+		if err := user.GAllUsers.SetRole(*name, *role); nil != err {
+			exitWithError(err)
+		}
+		fmt.Printf("role of user %s changed to %s\n", *name, *role)
+	}
+}
+
+func listUsers () {
+	for _, u := range user.GAllUsers.List() {
+		fmt.Printf("%s\t%s\n", u.Name, u.Role)
+	}
+}
+
+// 从标准输入读取一行作为密码
+func readPassword () (string, error) {
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if nil != err && line == "" {
+		return "", fmt.Errorf("read password error: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
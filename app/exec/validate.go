@@ -0,0 +1,31 @@
+package exec
+
+import (
+	"fmt"
+
+	cli "github.com/jawher/mow.cli"
+
+	"skynet-service/app/config"
+	"skynet-service/app/spider"
+)
+
+// validate-spiders 命令：检查动态规则文件，有问题时退出码为1
+func cmdValidateSpiders (cmd *cli.Cmd) {
+	cmd.Spec = "[FILE...]"
+	files := cmd.StringsArg("FILE", nil, "要检查的规则文件，未指定时检查动态规则目录中的全部文件")
+
+	cmd.Action = func() {
+		list := *files
+		if len(list) == 0 {
+			list = spider.DynamicFiles(config.SPIDER_DIR)
+		}
+		diags := spider.ValidateFiles(list)
+		for _, d := range diags {
+			fmt.Println(d)
+		}
+		fmt.Printf("%d files checked, %d problems found\n", len(list), len(diags))
+		if len(diags) > 0 {
+			cli.Exit(1)
+		}
+	}
+}
@@ -10,6 +10,22 @@ import (
 	"skynet-service/app/logs/logs"
 )
 
+// 日志级别，数值越小越重要
+const (
+	LevelApp           = logs.LevelApp
+	LevelEmergency     = logs.LevelEmergency
+	LevelAlert         = logs.LevelAlert
+	LevelCritical      = logs.LevelCritical
+	LevelError         = logs.LevelError
+	LevelWarning       = logs.LevelWarning
+	LevelNotice        = logs.LevelNotice
+	LevelInformational = logs.LevelInformational
+	LevelDebug         = logs.LevelDebug
+)
+
+// 日志订阅
+type Subscription = logs.Subscription
+
 type (
 	Logs interface {
 		// 设置实时log信息显示终端
@@ -22,6 +38,11 @@ type (
 		EnableStealOne(bool)
 		// 按先后顺序实时捕获日志副本，每次返回1条，normal标记日志是否被关闭
 		StealOne() (level int, msg string, normal bool)
+		// 订阅日志副本，可同时存在多个订阅者，level 为最低接收级别，
+		// spider 不为空时只接收经由 Spider(spider) 打印的日志，缓冲区满时丢弃新日志
+		Subscribe(level int, spider string, buflen int) *logs.Subscription
+		// 取消订阅
+		Unsubscribe(s *logs.Subscription)
 		// 正常关闭日志输出
 		Close()
 		// 返回运行状态，如0,"RUN"
@@ -29,6 +50,9 @@ type (
 		DelLogger(adaptername string) error
 		SetLogger(adaptername string, config map[string]interface{}) error
 
+		// 返回打印某蜘蛛日志的记录器，日志带有蜘蛛名称，供订阅者筛选
+		Spider(name string) *logs.SpiderLogger
+
 		// 以下打印方法除正常log输出外，若为客户端或服务端模式还将进行socket信息发送
 		Debug(format string, v ...interface{})
 		Informational(format string, v ...interface{})
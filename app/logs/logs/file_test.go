@@ -26,6 +26,7 @@ import (
 func TestFile(t *testing.T) {
 	log := NewLogger(10000)
 	log.SetLogger("file", map[string]interface{}{"filename": "test.log"})
+	log.App("app")
 	log.Debug("debug")
 	log.Informational("info")
 	log.Notice("notice")
@@ -60,8 +61,9 @@ func TestFile(t *testing.T) {
 func TestFile2(t *testing.T) {
 	log := NewLogger(10000)
 	log.SetLogger("file", map[string]interface{}{"filename": "test2.log", "level": LevelError})
+	log.App("app")
 	log.Debug("debug")
-	log.Info("info")
+	log.Informational("info")
 	log.Notice("notice")
 	log.Warning("warning")
 	log.Error("error")
@@ -95,7 +97,7 @@ func TestFileRotate(t *testing.T) {
 	log := NewLogger(10000)
 	log.SetLogger("file", map[string]interface{}{"filename": "test3.log", "maxlines": 4})
 	log.Debug("debug")
-	log.Info("info")
+	log.Informational("info")
 	log.Notice("notice")
 	log.Warning("warning")
 	log.Error("error")
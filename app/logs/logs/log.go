@@ -44,6 +44,8 @@ import (
 	"path"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // RFC5424 log message levels.
@@ -106,12 +108,32 @@ type BeeLogger struct {
 	stealLevel          int
 	stealLevelPreset    int
 	outputs             map[string]LoggerInterface
+	subscribers         map[*Subscription]bool
 	status              int
 }
 
 type logMsg struct {
-	level int
-	msg   string
+	level  int
+	msg    string
+	when   time.Time
+	spider string
+}
+
+// Subscription receives copies of log messages without blocking the logger.
+// Unlike StealOne, any number of subscriptions may exist at the same time,
+// messages are dropped when the buffer is full.
+type Subscription struct {
+	level   int
+	spider  string
+	ch      chan *logMsg
+	dropped uint64
+}
+
+// SpiderLogger logs messages on behalf of a spider,
+// subscriptions may select them by the spider name.
+type SpiderLogger struct {
+	bl     *BeeLogger
+	spider string
 }
 
 // NewLogger returns a new BeeLogger.
@@ -123,6 +145,7 @@ func NewLogger(channellen int64, stealLevel ...int) *BeeLogger {
 	bl.loggerFuncCallDepth = 2
 	bl.msg = make(chan *logMsg, channellen)
 	bl.outputs = make(map[string]LoggerInterface)
+	bl.subscribers = make(map[*Subscription]bool)
 	bl.status = WORK
 	bl.steal = make(chan *logMsg, channellen)
 	if len(stealLevel) > 0 {
@@ -172,13 +195,15 @@ func (bl *BeeLogger) DelLogger(adaptername string) error {
 	}
 }
 
-func (bl *BeeLogger) writerMsg(loglevel int, msg string) error {
+func (bl *BeeLogger) writerMsg(loglevel int, spider string, msg string) error {
 	if i, s := bl.Status(); i != WORK {
 		return errors.New("The current status is " + s)
 	}
 
 	lm := new(logMsg)
 	lm.level = loglevel
+	lm.when = time.Now()
+	lm.spider = spider
 	if bl.enableFuncCallDepth {
 		_, file, line, ok := runtime.Caller(bl.loggerFuncCallDepth)
 		if !ok {
@@ -194,6 +219,7 @@ func (bl *BeeLogger) writerMsg(loglevel int, msg string) error {
 	if lm.level <= bl.stealLevel {
 		bl.stealOne(lm)
 	}
+	bl.publish(lm)
 
 	if bl.asynchronous {
 		bl.msg <- lm
@@ -262,7 +288,7 @@ func (bl *BeeLogger) App(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[P] "+format, v...)
-	bl.writerMsg(LevelApp, msg)
+	bl.writerMsg(LevelApp, "", msg)
 }
 
 // Log EMERGENCY level message.
@@ -271,7 +297,7 @@ func (bl *BeeLogger) Emergency(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[M] "+format, v...)
-	bl.writerMsg(LevelEmergency, msg)
+	bl.writerMsg(LevelEmergency, "", msg)
 }
 
 // Log ALERT level message.
@@ -280,7 +306,7 @@ func (bl *BeeLogger) Alert(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[A] "+format, v...)
-	bl.writerMsg(LevelAlert, msg)
+	bl.writerMsg(LevelAlert, "", msg)
 }
 
 // Log CRITICAL level message.
@@ -289,7 +315,7 @@ func (bl *BeeLogger) Critical(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[C] "+format, v...)
-	bl.writerMsg(LevelCritical, msg)
+	bl.writerMsg(LevelCritical, "", msg)
 }
 
 // Log ERROR level message.
@@ -298,7 +324,7 @@ func (bl *BeeLogger) Error(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[E] "+format, v...)
-	bl.writerMsg(LevelError, msg)
+	bl.writerMsg(LevelError, "", msg)
 }
 
 // Log WARNING level message.
@@ -307,7 +333,7 @@ func (bl *BeeLogger) Warning(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[W] "+format, v...)
-	bl.writerMsg(LevelWarning, msg)
+	bl.writerMsg(LevelWarning, "", msg)
 }
 
 // Log NOTICE level message.
@@ -316,7 +342,7 @@ func (bl *BeeLogger) Notice(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[N] "+format, v...)
-	bl.writerMsg(LevelNotice, msg)
+	bl.writerMsg(LevelNotice, "", msg)
 }
 
 // Log INFORMATIONAL level message.
@@ -325,7 +351,7 @@ func (bl *BeeLogger) Informational(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[I] "+format, v...)
-	bl.writerMsg(LevelInformational, msg)
+	bl.writerMsg(LevelInformational, "", msg)
 }
 
 // Log DEBUG level message.
@@ -334,7 +360,44 @@ func (bl *BeeLogger) Debug(format string, v ...interface{}) {
 		return
 	}
 	msg := fmt.Sprintf("[D] "+format, v...)
-	bl.writerMsg(LevelDebug, msg)
+	bl.writerMsg(LevelDebug, "", msg)
+}
+
+// Spider returns a logger tagging messages with the spider name.
+func (bl *BeeLogger) Spider(name string) *SpiderLogger {
+	return &SpiderLogger{bl: bl, spider: name}
+}
+
+// Log ERROR level message of the spider.
+func (sl *SpiderLogger) Error(format string, v ...interface{}) {
+	if LevelError > sl.bl.level {
+		return
+	}
+	sl.bl.writerMsg(LevelError, sl.spider, fmt.Sprintf("[E] "+format, v...))
+}
+
+// Log WARNING level message of the spider.
+func (sl *SpiderLogger) Warning(format string, v ...interface{}) {
+	if LevelWarning > sl.bl.level {
+		return
+	}
+	sl.bl.writerMsg(LevelWarning, sl.spider, fmt.Sprintf("[W] "+format, v...))
+}
+
+// Log INFORMATIONAL level message of the spider.
+func (sl *SpiderLogger) Informational(format string, v ...interface{}) {
+	if LevelInformational > sl.bl.level {
+		return
+	}
+	sl.bl.writerMsg(LevelInformational, sl.spider, fmt.Sprintf("[I] "+format, v...))
+}
+
+// Log DEBUG level message of the spider.
+func (sl *SpiderLogger) Debug(format string, v ...interface{}) {
+	if LevelDebug > sl.bl.level {
+		return
+	}
+	sl.bl.writerMsg(LevelDebug, sl.spider, fmt.Sprintf("[D] "+format, v...))
 }
 
 // flush all chan data.
@@ -349,6 +412,10 @@ func (bl *BeeLogger) Close() {
 	bl.lock.Lock()
 	bl.status = CLOSE
 	close(bl.steal)
+	for s := range bl.subscribers {
+		close(s.ch)
+	}
+	bl.subscribers = nil
 	bl.lock.Unlock()
 
 	bl.lock.RLock()
@@ -414,6 +481,64 @@ func (bl *BeeLogger) stealOne(lm *logMsg) {
 	bl.steal <- lm
 }
 
+// Subscribe returns a subscription receiving messages whose level is not above level,
+// only messages logged by Spider(spider) are received unless spider is empty,
+// buflen messages are buffered.
+func (bl *BeeLogger) Subscribe(level int, spider string, buflen int) *Subscription {
+	s := &Subscription{
+		level:  level,
+		spider: spider,
+		ch:     make(chan *logMsg, buflen),
+	}
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	if bl.status == CLOSE {
+		close(s.ch)
+		return s
+	}
+	bl.subscribers[s] = true
+	return s
+}
+
+// Unsubscribe stops delivering messages to s, pending Next calls return ok == false.
+func (bl *BeeLogger) Unsubscribe(s *Subscription) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	if bl.subscribers[s] {
+		delete(bl.subscribers, s)
+		close(s.ch)
+	}
+}
+
+func (bl *BeeLogger) publish(lm *logMsg) {
+	bl.lock.RLock()
+	defer bl.lock.RUnlock()
+	for s := range bl.subscribers {
+		if lm.level > s.level || (s.spider != "" && s.spider != lm.spider) {
+			continue
+		}
+		select {
+		case s.ch <- lm:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// Next blocks until a message arrives, ok is false after unsubscribed or the logger closed.
+func (s *Subscription) Next() (level int, msg string, when time.Time, ok bool) {
+	lm := <-s.ch
+	if lm == nil {
+		return 0, "", time.Time{}, false
+	}
+	return lm.level, lm.msg, lm.when, true
+}
+
+// Dropped returns the number of messages discarded because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
 func (bl *BeeLogger) Status() (int, string) {
 	bl.lock.RLock()
 	defer bl.lock.RUnlock()
@@ -0,0 +1,52 @@
+package logs
+
+import (
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	bl := NewLogger(100)
+	warn := bl.Subscribe(LevelWarning, "", 10)
+	debug := bl.Subscribe(LevelDebug, "", 2)
+
+	bl.Error("error")
+	bl.Informational("info")
+	bl.Debug("debug")
+
+	if level, msg, _, ok := warn.Next(); !ok || level != LevelError || msg != "[E] error" {
+		t.Errorf("warn.Next() = %d, %q, %v", level, msg, ok)
+	}
+	for _, want := range []string{"[E] error", "[I] info"} {
+		if _, msg, _, ok := debug.Next(); !ok || msg != want {
+			t.Errorf("debug.Next() = %q, %v, want %q", msg, ok, want)
+		}
+	}
+	if n := debug.Dropped(); n != 1 {
+		t.Errorf("debug.Dropped() = %d, want 1", n)
+	}
+
+	bl.Unsubscribe(warn)
+	if _, _, _, ok := warn.Next(); ok {
+		t.Error("Next() after Unsubscribe should not be ok")
+	}
+	bl.Close()
+	if _, _, _, ok := debug.Next(); ok {
+		t.Error("Next() after Close should not be ok")
+	}
+}
+
+// 按蜘蛛名称筛选，不依赖日志内容
+func TestSubscribeSpider(t *testing.T) {
+	bl := NewLogger(100)
+	a := bl.Subscribe(LevelDebug, "a", 10)
+	bl.Spider("b").Error("fail [a]")
+	bl.Informational("a")
+	bl.Spider("a").Warning("fail [%s]", "x")
+	if level, msg, _, ok := a.Next(); !ok || level != LevelWarning || msg != "[W] fail [x]" {
+		t.Errorf("a.Next() = %d, %q, %v", level, msg, ok)
+	}
+	bl.Unsubscribe(a)
+	if _, _, _, ok := a.Next(); ok {
+		t.Error("a received messages of other spiders")
+	}
+}
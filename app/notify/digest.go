@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"text/template"
+	"time"
+
+	"skynet-service/app/aid/change"
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+	"skynet-service/app/notify/mail"
+	"skynet-service/app/pipeline/collector"
+	"skynet-service/app/runtime/cache"
+	"skynet-service/app/runtime/runs"
+	"skynet-service/app/spider"
+)
+
+// 默认标题模板
+const defaultSubject = `[{{.App}}] {{.Name}} 采集摘要 {{.Until.Format "2006-01-02 15:04"}}`
+
+//go:embed templates
+var builtin embed.FS
+
+type (
+	// 摘要模板的数据
+	DigestData struct {
+		App     string
+		Name    string
+		Since   time.Time // 统计起始时刻
+		Until   time.Time // 统计截止时刻
+		Spiders []*SpiderDigest
+	}
+	// 单个蜘蛛在统计时段内的运行情况及输出结果
+	SpiderDigest struct {
+		Name    string
+		Runs    int             // 运行次数
+		Items   uint64          // 文本结果数
+		Files   uint64          // 文件结果数
+		Success uint64          // 成功页数
+		Failure uint64          // 失败页数
+		Errors  uint64          // 错误日志数
+		Changes []*change.Event // 结果变化事件，按时间先后排列
+		Tables  []*collector.Table
+		Error   string // 读取输出结果失败的原因
+	}
+	templates struct {
+		subject *template.Template
+		html    *htmltemplate.Template
+		text    *template.Template
+	}
+)
+
+// 解析模板，文件名为空时使用内置模板
+func parseTemplates(subject, htmlFile, textFile string) (*templates, error) {
+	if subject == "" {
+		subject = defaultSubject
+	}
+	var (
+		t   = new(templates)
+		err error
+	)
+	if t.subject, err = template.New("subject").Parse(subject); err != nil {
+		return nil, err
+	}
+	b, err := readTemplate(htmlFile, "templates/digest.html")
+	if err != nil {
+		return nil, err
+	}
+	if t.html, err = htmltemplate.New("html").Parse(string(b)); err != nil {
+		return nil, err
+	}
+	if b, err = readTemplate(textFile, "templates/digest.txt"); err != nil {
+		return nil, err
+	}
+	if t.text, err = template.New("text").Parse(string(b)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func readTemplate(file, builtinName string) ([]byte, error) {
+	if file == "" {
+		return builtin.ReadFile(builtinName)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// 收集统计时段内各蜘蛛的运行记录与输出结果
+func (self *Notifier) build(d *Digest, now time.Time) *DigestData {
+	data := &DigestData{
+		App:   config.NAME,
+		Name:  d.Name,
+		Since: now.Add(-d.window),
+		Until: now,
+	}
+
+	var spiders []*spider.Spider
+	if len(d.Spiders) == 0 {
+		spiders = self.app.GetSpiderLib()
+	} else {
+		for _, name := range d.Spiders {
+			if sp := self.app.GetSpiderByName(name); sp != nil {
+				spiders = append(spiders, sp)
+			} else {
+				logs.Log.Warning(" *     [邮件摘要：%s]   蜘蛛不存在：%s", d.Name, name)
+			}
+		}
+	}
+
+	list, err := runs.Runs.List("", 0)
+	if err != nil {
+		logs.Log.Warning(" *     [邮件摘要：%s]   读取运行记录失败：%v", d.Name, err)
+	}
+	for _, sp := range spiders {
+		sd := &SpiderDigest{Name: sp.GetName()}
+		for _, run := range list {
+			if run.Start.Before(data.Since) || !run.HasSpider(sd.Name) {
+				continue
+			}
+			sd.Runs++
+			sd.Errors += run.ErrorNum
+			for _, s := range run.Spiders {
+				if s.Name == sd.Name {
+					sd.Items += s.Items
+					sd.Files += s.Files
+					sd.Success += s.Success
+					sd.Failure += s.Failure
+				}
+			}
+		}
+		if sd.Changes, err = change.List(sd.Name, data.Since, d.Limit); err != nil {
+			logs.Log.Warning(" *     [邮件摘要：%s]   读取变化事件失败：%v", d.Name, err)
+		}
+		for i, j := 0, len(sd.Changes)-1; i < j; i, j = i+1, j-1 {
+			sd.Changes[i], sd.Changes[j] = sd.Changes[j], sd.Changes[i]
+		}
+		sd.Tables, err = collector.Query(cache.Task.OutType, sp, data.Since, d.Limit)
+		if err != nil {
+			sd.Error = err.Error()
+		}
+		data.Spiders = append(data.Spiders, sd)
+	}
+	return data
+}
+
+// 由模板生成邮件
+func (self *Digest) render(data *DigestData) (*mail.Message, error) {
+	var subject, html, text bytes.Buffer
+	if err := self.tmpl.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if err := self.tmpl.html.Execute(&html, data); err != nil {
+		return nil, err
+	}
+	if err := self.tmpl.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	return &mail.Message{
+		From:    config.Current().SmtpFrom,
+		To:      self.To,
+		Subject: subject.String(),
+		HTML:    html.String(),
+		Text:    text.String(),
+		Date:    data.Until,
+	}, nil
+}
@@ -0,0 +1,141 @@
+// 通过 SMTP 发送邮件
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// 连接 SMTP 服务器的超时时长
+const dialTimeout = 30 * time.Second
+
+type (
+	// SMTP 服务器
+	Server struct {
+		Addr     string // 地址，含端口
+		Username string // 为空时不认证
+		Password string
+		StartTLS bool // 服务器支持时使用 STARTTLS 加密连接
+	}
+	// 邮件，Text 与 HTML 至少有一个不为空，同时存在时作为 multipart/alternative 发送
+	Message struct {
+		From    string
+		To      []string
+		Subject string
+		Text    string
+		HTML    string
+		Date    time.Time // 为零值时使用当前时间
+	}
+)
+
+// 发送邮件
+func Send(s Server, m *Message) error {
+	if len(m.To) == 0 {
+		return fmt.Errorf("mail: no recipients")
+	}
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("mail: invalid server address %q: %v", s.Addr, err)
+	}
+	conn, err := net.DialTimeout("tcp", s.Addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && s.StartTLS {
+		if err = c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(m.From); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(m.Bytes()); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// 生成邮件原文
+func (self *Message) Bytes() []byte {
+	date := self.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		buf.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", self.From)
+	header("To", strings.Join(self.To, ", "))
+	header("Subject", mime.BEncoding.Encode("UTF-8", self.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	switch {
+	case self.HTML == "":
+		writePart(&buf, "text/plain", self.Text)
+	case self.Text == "":
+		writePart(&buf, "text/html", self.HTML)
+	default:
+		boundary := newBoundary()
+		header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+		buf.WriteString("\r\n")
+		buf.WriteString("--" + boundary + "\r\n")
+		writePart(&buf, "text/plain", self.Text)
+		buf.WriteString("--" + boundary + "\r\n")
+		writePart(&buf, "text/html", self.HTML)
+		buf.WriteString("--" + boundary + "--\r\n")
+	}
+	return buf.Bytes()
+}
+
+// 写入一段 base64 编码的正文及其头部
+func writePart(buf *bytes.Buffer, contentType, body string) {
+	buf.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	b := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(b) > 76 {
+		buf.WriteString(b[:76] + "\r\n")
+		b = b[76:]
+	}
+	buf.WriteString(b + "\r\n")
+}
+
+func newBoundary() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "skynet-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,114 @@
+package mail
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"strings"
+	"testing"
+)
+
+// 仅实现收信所需命令的 SMTP 服务器，收到的邮件写入 msgs
+func fakeServer(t *testing.T) (addr string, rcpts chan []string, msgs chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	rcpts, msgs = make(chan []string, 1), make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+		var to []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250-fake")
+				reply("250 8BITMIME")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				to = append(to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(l, "."))
+				}
+				rcpts <- to
+				msgs <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), rcpts, msgs
+}
+
+func TestSend(t *testing.T) {
+	addr, rcpts, msgs := fakeServer(t)
+	m := &Message{
+		From:    "skynet@localhost",
+		To:      []string{"a@localhost", "b@localhost"},
+		Subject: "采集日报",
+		Text:    "纯文本",
+		HTML:    "<p>网页</p>",
+	}
+	if err := Send(Server{Addr: addr, StartTLS: true}, m); err != nil {
+		t.Fatal(err)
+	}
+	if to := <-rcpts; strings.Join(to, ",") != "a@localhost,b@localhost" {
+		t.Errorf("recipients = %v", to)
+	}
+
+	msg, err := netmail.ReadMessage(strings.NewReader(<-msgs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); s != m.Subject {
+		t.Errorf("Subject = %q, want %q", s, m.Subject)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []string{m.Text, m.HTML} {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		if string(b) != want {
+			t.Errorf("part = %q, want %q", b, want)
+		}
+	}
+}
+
+func TestSendNoRecipients(t *testing.T) {
+	if err := Send(Server{Addr: "127.0.0.1:25"}, &Message{From: "a@localhost"}); err == nil {
+		t.Error("Send() without recipients should fail")
+	}
+}
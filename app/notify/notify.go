@@ -0,0 +1,232 @@
+// 邮件摘要：按计划读取已输出的采集结果，经模板生成摘要后通过 SMTP 发送
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"skynet-service/app"
+	"skynet-service/app/config"
+	"skynet-service/app/cron"
+	"skynet-service/app/logs"
+	"skynet-service/app/notify/mail"
+)
+
+// 默认值
+const (
+	defaultWindow = 24 * time.Hour // 摘要的统计时长
+	defaultLimit  = 50             // 每张数据表最多列出的行数
+)
+
+type (
+	// 摘要配置，读取自 config.NOTIFY_FILE 中的 JSON 数组
+	Digest struct {
+		Name         string   `json:"name"`
+		Schedule     string   `json:"schedule"`      // 调度描述，格式同 [cron] 段
+		To           []string `json:"to"`            // 收件人
+		Subject      string   `json:"subject"`       // 标题模板，为空时使用默认标题
+		Spiders      []string `json:"spiders"`       // 包含的蜘蛛，为空时包含全部蜘蛛
+		Window       string   `json:"window"`        // 统计时长，如 "12h"，为空时为24小时
+		Limit        int      `json:"limit"`         // 每张数据表最多列出的行数，为0时使用默认值
+		HTMLTemplate string   `json:"html_template"` // 自定义网页模板文件，为空时使用内置模板
+		TextTemplate string   `json:"text_template"` // 自定义纯文本模板文件，为空时使用内置模板
+		schedule     cron.Schedule
+		window       time.Duration
+		tmpl         *templates
+		next         time.Time // 下次发送时刻
+	}
+	// 按计划发送摘要
+	Notifier struct {
+		app     app.App
+		digests []*Digest
+		stop    chan struct{}
+		done    chan struct{}
+		sync.Mutex
+	}
+)
+
+func New(a app.App) *Notifier {
+	return &Notifier{app: a}
+}
+
+// 读取摘要配置文件，文件不存在时没有摘要
+func Load(path string) ([]*Digest, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var digests []*Digest
+	if err = json.Unmarshal(b, &digests); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	names := map[string]bool{}
+	now := time.Now()
+	for _, d := range digests {
+		if err = d.init(now); err != nil {
+			return nil, fmt.Errorf("%s: digest %q: %v", path, d.Name, err)
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("%s: duplicate digest %q", path, d.Name)
+		}
+		names[d.Name] = true
+	}
+	return digests, nil
+}
+
+// 检查配置并解析调度、统计时长与模板
+func (self *Digest) init(now time.Time) (err error) {
+	if self.Name == "" {
+		return fmt.Errorf("name can not be empty")
+	}
+	if len(self.To) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	if self.schedule, err = cron.Parse(self.Schedule); err != nil {
+		return err
+	}
+	self.window = defaultWindow
+	if self.Window != "" {
+		if self.window, err = time.ParseDuration(self.Window); err != nil || self.window <= 0 {
+			return fmt.Errorf("invalid window: %q", self.Window)
+		}
+	}
+	if self.Limit <= 0 {
+		self.Limit = defaultLimit
+	}
+	if self.tmpl, err = parseTemplates(self.Subject, self.HTMLTemplate, self.TextTemplate); err != nil {
+		return err
+	}
+	self.next = self.schedule.Next(now)
+	return nil
+}
+
+// 重新读取摘要配置
+func (self *Notifier) Reload() error {
+	digests, err := Load(config.NOTIFY_FILE)
+	if err != nil {
+		return err
+	}
+	self.Lock()
+	self.digests = digests
+	self.Unlock()
+	return nil
+}
+
+// 返回全部摘要
+func (self *Notifier) Digests() []*Digest {
+	self.Lock()
+	defer self.Unlock()
+	digests := make([]*Digest, len(self.digests))
+	copy(digests, self.digests)
+	return digests
+}
+
+// 返回摘要的下次发送时刻
+func (self *Notifier) Next(d *Digest) time.Time {
+	self.Lock()
+	defer self.Unlock()
+	return d.next
+}
+
+// 启动发送协程
+func (self *Notifier) Start() {
+	self.Lock()
+	defer self.Unlock()
+	if self.stop != nil {
+		return
+	}
+	self.stop = make(chan struct{})
+	self.done = make(chan struct{})
+	go self.loop()
+}
+
+// 停止发送协程，等待正在发送的摘要完成
+func (self *Notifier) Stop() {
+	self.Lock()
+	if self.stop == nil {
+		self.Unlock()
+		return
+	}
+	close(self.stop)
+	done := self.done
+	self.stop = nil
+	self.Unlock()
+	<-done
+}
+
+// 立即发送指定摘要
+func (self *Notifier) Send(name string) error {
+	for _, d := range self.Digests() {
+		if d.Name == name {
+			return self.send(d, time.Now())
+		}
+	}
+	return fmt.Errorf("digest not found: %s", name)
+}
+
+// 生成指定摘要但不发送
+func (self *Notifier) Preview(name string) (*mail.Message, error) {
+	for _, d := range self.Digests() {
+		if d.Name == name {
+			return d.render(self.build(d, time.Now()))
+		}
+	}
+	return nil, fmt.Errorf("digest not found: %s", name)
+}
+
+func (self *Notifier) loop() {
+	self.Lock()
+	stop, done := self.stop, self.done
+	self.Unlock()
+	defer close(done)
+
+	cron.Loop(stop, func(now time.Time) (next time.Time) {
+		var due []*Digest
+		self.Lock()
+		for _, d := range self.digests {
+			if !d.next.After(now) {
+				due = append(due, d)
+				d.next = d.schedule.Next(now)
+			}
+			next = cron.Earlier(next, d.next)
+		}
+		self.Unlock()
+
+		for _, d := range due {
+			if err := self.send(d, now); err != nil {
+				logs.Log.Error(" *     [邮件摘要：%s]   发送失败：%v", d.Name, err)
+			}
+		}
+		return next
+	})
+}
+
+// 生成并发送摘要
+func (self *Notifier) send(d *Digest, now time.Time) error {
+	msg, err := d.render(self.build(d, now))
+	if err != nil {
+		return err
+	}
+	if err = mail.Send(smtpServer(), msg); err != nil {
+		return err
+	}
+	logs.Log.Informational(" *     [邮件摘要：%s]   已发送至 %v", d.Name, d.To)
+	return nil
+}
+
+func smtpServer() mail.Server {
+	conf := config.Current()
+	return mail.Server{
+		Addr:     conf.SmtpHost,
+		Username: conf.SmtpUsername,
+		Password: conf.SmtpPassword,
+		StartTLS: conf.SmtpStartTLS,
+	}
+}
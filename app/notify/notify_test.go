@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"skynet-service/app"
+	"skynet-service/app/config"
+	"skynet-service/app/runtime/cache"
+	"skynet-service/app/runtime/runs"
+	"skynet-service/app/spider"
+)
+
+// 只实现摘要用到的方法
+type fakeApp struct {
+	app.App
+	spiders []*spider.Spider
+}
+
+func (self *fakeApp) GetSpiderLib() []*spider.Spider { return self.spiders }
+
+func (self *fakeApp) GetSpiderByName(name string) *spider.Spider {
+	for _, sp := range self.spiders {
+		if sp.Name == name {
+			return sp
+		}
+	}
+	return nil
+}
+
+// 以临时目录中的运行记录与 csv 输出构造摘要的数据来源
+func setup(t *testing.T, now time.Time) *Notifier {
+	oldRuns, oldDir, oldOut := runs.Runs, config.TEXT_DIR, cache.Task.OutType
+	t.Cleanup(func() { runs.Runs, config.TEXT_DIR, cache.Task.OutType = oldRuns, oldDir, oldOut })
+
+	runs.Runs = runs.NewStore(t.TempDir(), 0)
+	for _, r := range []*runs.Run{
+		{Start: now.Add(-48 * time.Hour), Spiders: []runs.Spider{{Name: "gold", Items: 100}}},
+		{Start: now.Add(-2 * time.Hour), Spiders: []runs.Spider{{Name: "gold", Items: 2, Success: 3, Failure: 1}}, ErrorNum: 4},
+		{Start: now.Add(-time.Hour), Spiders: []runs.Spider{{Name: "gold", Items: 1, Success: 1}, {Name: "silver", Items: 7}}},
+	} {
+		if err := runs.Runs.Save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache.Task.OutType = "csv"
+	config.TEXT_DIR = t.TempDir()
+	dir := filepath.Join(config.TEXT_DIR, "batch", "gold")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	csv := "标题,下载时间\n旧,2000-01-01 00:00:00\n新金价," + now.Add(-time.Hour).Format("2006-01-02 15:04:05") + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "1-1.csv"), []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return New(&fakeApp{spiders: []*spider.Spider{{Name: "gold"}, {Name: "silver"}}})
+}
+
+func TestBuildRender(t *testing.T) {
+	now := time.Now()
+	n := setup(t, now)
+	d := &Digest{Name: "daily", Schedule: "@daily", To: []string{"a@example.com"}, Spiders: []string{"gold", "nope"}}
+	if err := d.init(now); err != nil {
+		t.Fatal(err)
+	}
+
+	data := n.build(d, now)
+	if len(data.Spiders) != 1 {
+		t.Fatalf("spiders = %+v", data.Spiders)
+	}
+	sd := data.Spiders[0]
+	if sd.Name != "gold" || sd.Runs != 2 || sd.Items != 3 || sd.Success != 4 || sd.Failure != 1 || sd.Errors != 4 {
+		t.Fatalf("digest = %+v", sd)
+	}
+	if len(sd.Tables) != 1 || len(sd.Tables[0].Rows) != 1 || sd.Tables[0].Rows[0][0] != "新金价" {
+		t.Fatalf("tables = %+v, error %q", sd.Tables, sd.Error)
+	}
+
+	msg, err := d.render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(msg.Subject, "daily 采集摘要") || len(msg.To) != 1 || !msg.Date.Equal(now) {
+		t.Errorf("message = %+v", msg)
+	}
+	for _, s := range []string{"== gold ==", "运行 2 次，数据 3 条", "新金价"} {
+		if !strings.Contains(msg.Text, s) {
+			t.Errorf("text missing %q:\n%s", s, msg.Text)
+		}
+	}
+	if !strings.Contains(msg.HTML, "新金价") || strings.Contains(msg.HTML, "旧") {
+		t.Errorf("html:\n%s", msg.HTML)
+	}
+
+	// 未指定蜘蛛时包含全部蜘蛛，不支持查询的输出方式记录原因
+	cache.Task.OutType = "kafka"
+	d.Spiders = nil
+	data = n.build(d, now)
+	if len(data.Spiders) != 2 || data.Spiders[1].Items != 7 || data.Spiders[1].Error == "" {
+		t.Fatalf("all spiders = %+v", data.Spiders)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.json")
+	if digests, err := Load(path); err != nil || digests != nil {
+		t.Fatalf("missing file: %v, %v", digests, err)
+	}
+	for _, content := range []string{
+		`[{"name": "", "schedule": "@daily", "to": ["a@example.com"]}]`,
+		`[{"name": "a", "schedule": "@daily"}]`,
+		`[{"name": "a", "schedule": "nope", "to": ["a@example.com"]}]`,
+		`[{"name": "a", "schedule": "@daily", "to": ["a@example.com"], "window": "-1h"}]`,
+		`[{"name": "a", "schedule": "@daily", "to": ["a@example.com"]}, {"name": "a", "schedule": "@daily", "to": ["b@example.com"]}]`,
+	} {
+		ioutil.WriteFile(path, []byte(content), 0644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: no error", content)
+		}
+	}
+	ioutil.WriteFile(path, []byte(`[{"name": "a", "schedule": "@every 1h", "to": ["a@example.com"], "window": "12h"}]`), 0644)
+	digests, err := Load(path)
+	if err != nil || len(digests) != 1 || digests[0].window != 12*time.Hour || digests[0].Limit != defaultLimit || digests[0].next.IsZero() {
+		t.Fatalf("Load() = %+v, %v", digests, err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	n := setup(t, time.Now())
+	addr, rcpts, msgs := fakeSMTP(t)
+	defer config.SetCurrent(config.Current())
+	conf := *config.Current()
+	conf.SmtpHost, conf.SmtpFrom, conf.SmtpUsername, conf.SmtpStartTLS = addr, "skynet@example.com", "", false
+	config.SetCurrent(&conf)
+
+	d := &Digest{Name: "daily", Schedule: "@daily", To: []string{"a@example.com", "b@example.com"}}
+	if err := d.init(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	n.digests = []*Digest{d}
+	if err := n.Send("nope"); err == nil {
+		t.Fatal("unknown digest sent")
+	}
+	if err := n.Send("daily"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case to := <-rcpts:
+		if strings.Join(to, ",") != "a@example.com,b@example.com" {
+			t.Errorf("rcpts = %v", to)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+	if msg := <-msgs; !strings.Contains(msg, "Subject:") || !strings.Contains(msg, "multipart/alternative") {
+		t.Errorf("message:\n%s", msg)
+	}
+}
+
+// 仅实现收信所需命令的 SMTP 服务器
+func fakeSMTP(t *testing.T) (addr string, rcpts chan []string, msgs chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	rcpts, msgs = make(chan []string, 1), make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+		var to []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				to = append(to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				rcpts <- to
+				msgs <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), rcpts, msgs
+}
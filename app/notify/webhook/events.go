@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"skynet-service/app/aid/change"
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+	"skynet-service/app/runtime/cache"
+)
+
+type (
+	// run.finished 事件的内容
+	RunReport struct {
+		RunID      string         `json:"run_id"`
+		Start      time.Time      `json:"start"`
+		End        time.Time      `json:"end"`
+		StopReason string         `json:"stop_reason"` // finished | stopped
+		Items      uint64         `json:"items"`
+		Files      uint64         `json:"files"`
+		Success    uint64         `json:"success"`
+		Failure    uint64         `json:"failure"`
+		Spiders    []SpiderReport `json:"spiders"`
+	}
+	// 单个蜘蛛的报告，同时作为 spider.failed 事件的内容
+	SpiderReport struct {
+		RunID   string `json:"run_id,omitempty"`
+		Name    string `json:"name"`
+		Keyin   string `json:"keyin"`
+		Items   uint64 `json:"items"`
+		Files   uint64 `json:"files"`
+		Success uint64 `json:"success"`
+		Failure uint64 `json:"failure"`
+		Elapsed int64  `json:"elapsed"` // 用时，单位毫秒
+	}
+)
+
+var (
+	hooks   = map[string]*Hook{}
+	hooksMu sync.RWMutex
+)
+
+func init() {
+	if err := Load(config.WEBHOOK_FILE); err != nil {
+		logs.Log.Error(" *     读取 Webhook 配置失败：%v", err)
+	}
+	change.Handle(func(e *change.Event) {
+		Publish(Change, e)
+	})
+}
+
+// 读取 Webhook 配置文件，文件不存在时没有 Webhook；
+// 原有 Webhook 队列中的事件仍按原配置发送
+func Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		b, err = []byte("[]"), nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []*Hook
+	if err = json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	m := make(map[string]*Hook, len(list))
+	for _, h := range list {
+		if err = h.init(); err != nil {
+			return fmt.Errorf("%s: webhook %q: %v", path, h.Name, err)
+		}
+		if _, ok := m[h.Name]; ok {
+			return fmt.Errorf("%s: duplicate webhook %q", path, h.Name)
+		}
+		m[h.Name] = h
+	}
+
+	for _, h := range m {
+		h.start()
+	}
+	hooksMu.Lock()
+	old := hooks
+	hooks = m
+	hooksMu.Unlock()
+	go func() {
+		for _, h := range old {
+			h.stop(0)
+		}
+	}()
+	return nil
+}
+
+// 停止全部 Webhook，等待队列中的事件发送完毕，退出前调用
+func Close() {
+	hooksMu.Lock()
+	old := hooks
+	hooks = map[string]*Hook{}
+	hooksMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, h := range old {
+		wg.Add(1)
+		go func(h *Hook) {
+			defer wg.Done()
+			h.stop(closeTimeout)
+		}(h)
+	}
+	wg.Wait()
+}
+
+// 是否存在该名称的 Webhook
+func Exists(name string) bool {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	_, ok := hooks[name]
+	return ok
+}
+
+// 向订阅了该类型的全部 Webhook 推送事件
+func Publish(typ string, data interface{}) {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	if len(hooks) == 0 {
+		return
+	}
+	e := NewEvent(typ, data)
+	for _, h := range hooks {
+		if h.Subscribed(typ) {
+			h.enqueue(e)
+		}
+	}
+}
+
+// 向指定名称的 Webhook 推送事件，不检查订阅的事件类型
+func PublishTo(name, typ string, data interface{}) error {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	h, ok := hooks[name]
+	if !ok {
+		return fmt.Errorf("webhook not found: %s", name)
+	}
+	h.enqueue(NewEvent(typ, data))
+	return nil
+}
+
+// 推送任务结束及蜘蛛失败事件
+func PublishRun(runID string, start time.Time, reports []*cache.Report, stopped bool) {
+	r := &RunReport{
+		RunID:      runID,
+		Start:      start,
+		End:        time.Now(),
+		StopReason: "finished",
+		Spiders:    make([]SpiderReport, 0, len(reports)),
+	}
+	if stopped {
+		r.StopReason = "stopped"
+	}
+	for _, rep := range reports {
+		s := SpiderReport{
+			RunID:   runID,
+			Name:    rep.SpiderName,
+			Keyin:   rep.Keyin,
+			Items:   rep.DataNum,
+			Files:   rep.FileNum,
+			Success: rep.Success,
+			Failure: rep.Failure,
+			Elapsed: int64(rep.Time / time.Millisecond),
+		}
+		r.Items += s.Items
+		r.Files += s.Files
+		r.Success += s.Success
+		r.Failure += s.Failure
+		if s.Failure > 0 && s.Success == 0 && s.Items == 0 && s.Files == 0 {
+			Publish(SpiderFailed, s)
+		}
+		s.RunID = ""
+		r.Spiders = append(r.Spiders, s)
+	}
+	Publish(RunFinished, r)
+}
@@ -0,0 +1,313 @@
+// Webhook：以 POST 方式推送 JSON 格式的事件，附带 HMAC-SHA256 签名；
+// 失败时按指数退避重试，仍失败时写入死信文件。
+// 请求经由 surfer 下载器发送，可使用代理及超时设置
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"skynet-service/app/config"
+	"skynet-service/app/downloader/surfer"
+	"skynet-service/app/logs"
+)
+
+// 事件类型
+const (
+	RunFinished  = "run.finished"  // 任务运行结束
+	SpiderFailed = "spider.failed" // 蜘蛛全部请求失败且无采集结果
+	Change       = "change"        // 结果变化
+	Alert        = "alert"         // 数值告警，仅发送给告警规则指定的 Webhook
+)
+
+// 请求头
+const (
+	SignatureHeader = "X-Skynet-Signature" // "sha256=" + 请求体的 HMAC-SHA256 十六进制值
+	EventHeader     = "X-Skynet-Event"     // 事件类型
+	DeliveryHeader  = "X-Skynet-Delivery"  // 事件id，重试时不变
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	defaultRetries = 3
+	backoffBase    = 2 * time.Second // 首次重试前的等待时长，此后每次加倍
+	backoffMax     = 5 * time.Minute
+	queueSize      = 256              // 每个 Webhook 待发送事件的缓冲长度
+	closeTimeout   = 30 * time.Second // 退出时等待事件发送的最长时间
+)
+
+type (
+	// Webhook 配置，读取自 config.WEBHOOK_FILE 中的 JSON 数组
+	Hook struct {
+		Name    string            `json:"name"`
+		URL     string            `json:"url"`
+		Secret  string            `json:"secret"`  // 签名密钥，为空时不签名
+		Events  []string          `json:"events"`  // 订阅的事件类型，为空时订阅 run.finished、spider.failed 与 change
+		Proxy   string            `json:"proxy"`   // 代理地址，如 "http://127.0.0.1:8080"
+		Timeout string            `json:"timeout"` // 连接及请求超时，默认30秒
+		Retries int               `json:"retries"` // 失败后的重试次数，为0时使用默认值，小于0时不重试
+		Header  map[string]string `json:"header"`  // 附加的请求头
+		timeout time.Duration
+		queue   chan *Event
+		quit    chan struct{} // 关闭后后台协程不再发送，剩余事件写入死信文件
+		done    chan struct{}
+	}
+	// 推送的事件，即请求体
+	Event struct {
+		ID   string      `json:"id"`
+		Type string      `json:"type"`
+		Time time.Time   `json:"time"`
+		Data interface{} `json:"data"`
+	}
+	// 死信文件中的一行
+	deadLetter struct {
+		Time     time.Time `json:"time"`
+		Hook     string    `json:"hook"`
+		URL      string    `json:"url"`
+		Attempts int       `json:"attempts"`
+		Error    string    `json:"error"`
+		Event    *Event    `json:"event"`
+	}
+)
+
+var (
+	deadMu   sync.Mutex
+	deadFile = config.WebhookDead // 死信文件路径
+)
+
+func NewEvent(typ string, data interface{}) *Event {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &Event{
+		ID:   hex.EncodeToString(id),
+		Type: typ,
+		Time: time.Now(),
+		Data: data,
+	}
+}
+
+func (self *Hook) init() (err error) {
+	switch {
+	case self.Name == "":
+		return fmt.Errorf("name can not be empty")
+	case self.URL == "":
+		return fmt.Errorf("url can not be empty")
+	}
+	for _, typ := range self.Events {
+		switch typ {
+		case RunFinished, SpiderFailed, Change:
+		default:
+			return fmt.Errorf("unknown event type: %s", typ)
+		}
+	}
+	self.timeout = defaultTimeout
+	if self.Timeout != "" {
+		if self.timeout, err = time.ParseDuration(self.Timeout); err != nil || self.timeout <= 0 {
+			return fmt.Errorf("invalid timeout: %q", self.Timeout)
+		}
+	}
+	if self.Retries == 0 {
+		self.Retries = defaultRetries
+	}
+	return nil
+}
+
+// 是否订阅了该类型的事件
+func (self *Hook) Subscribed(typ string) bool {
+	if typ == Alert {
+		return false
+	}
+	if len(self.Events) == 0 {
+		return true
+	}
+	for _, t := range self.Events {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// 计算签名
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 发送一次，不重试
+func (self *Hook) Post(e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	header := make(http.Header)
+	for k, v := range self.Header {
+		header.Set(k, v)
+	}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("User-Agent", config.NAME+"/"+config.VERSION)
+	header.Set(EventHeader, e.Type)
+	header.Set(DeliveryHeader, e.ID)
+	if self.Secret != "" {
+		header.Set(SignatureHeader, Sign(self.Secret, body))
+	}
+
+	resp, err := surfer.Download(&surfer.DefaultRequest{
+		Url:           self.URL,
+		Method:        "POST",
+		Header:        header,
+		PostData:      string(body),
+		DialTimeout:   self.timeout,
+		ConnTimeout:   self.timeout,
+		TryTimes:      1,
+		RedirectTimes: -1,
+		Proxy:         self.Proxy,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Body != nil {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("response status %s", resp.Status)
+	}
+	return nil
+}
+
+// 发送事件，失败时按退避间隔重试，全部失败后写入死信文件
+func (self *Hook) Deliver(e *Event) error {
+	return self.deliver(e, nil)
+}
+
+// 同 Deliver()，等待重试期间 quit 被关闭时不再重试，直接写入死信文件
+func (self *Hook) deliver(e *Event, quit <-chan struct{}) error {
+	var (
+		err     error
+		attempt int
+		wait    = backoffBase
+	)
+retry:
+	for {
+		attempt++
+		if err = self.Post(e); err == nil {
+			return nil
+		}
+		if attempt > self.Retries {
+			break
+		}
+		logs.Log.Warning(" *     [Webhook：%s]   第 %d 次发送事件 %s 失败，%v 后重试：%v", self.Name, attempt, e.ID, wait, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-quit:
+			timer.Stop()
+			err = fmt.Errorf("not sent before exit: %v", err)
+			break retry
+		}
+		if wait *= 2; wait > backoffMax {
+			wait = backoffMax
+		}
+	}
+
+	logs.Log.Error(" *     [Webhook：%s]   事件 %s(%s) 发送失败，已写入死信文件：%v", self.Name, e.ID, e.Type, err)
+	if derr := writeDead(&deadLetter{
+		Time:     time.Now(),
+		Hook:     self.Name,
+		URL:      self.URL,
+		Attempts: attempt,
+		Error:    err.Error(),
+		Event:    e,
+	}); derr != nil {
+		logs.Log.Error(" *     [Webhook：%s]   写入死信文件失败：%v", self.Name, derr)
+	}
+	return err
+}
+
+// 在后台依次发送队列中的事件，quit 关闭后将剩余事件写入死信文件
+func (self *Hook) start() {
+	self.queue = make(chan *Event, queueSize)
+	self.quit = make(chan struct{})
+	self.done = make(chan struct{})
+	go func() {
+		defer close(self.done)
+		for e := range self.queue {
+			select {
+			case <-self.quit:
+				writeDead(&deadLetter{
+					Time:  time.Now(),
+					Hook:  self.Name,
+					URL:   self.URL,
+					Error: "not sent before exit",
+					Event: e,
+				})
+			default:
+				self.deliver(e, self.quit)
+			}
+		}
+	}()
+}
+
+// 加入发送队列，队列已满时直接写入死信文件
+func (self *Hook) enqueue(e *Event) {
+	select {
+	case self.queue <- e:
+	default:
+		logs.Log.Error(" *     [Webhook：%s]   待发送事件过多，事件 %s(%s) 已写入死信文件", self.Name, e.ID, e.Type)
+		writeDead(&deadLetter{
+			Time:  time.Now(),
+			Hook:  self.Name,
+			URL:   self.URL,
+			Error: "queue full",
+			Event: e,
+		})
+	}
+}
+
+// 停止接收事件，等待队列中的事件发送完毕；
+// 超过 timeout(大于0时)后通知后台协程停止重试，由其将正在重试及尚未发送的事件写入死信文件，
+// 正在进行的一次请求仍须等待其结束(至多为 Hook 的超时)，返回时后台协程已退出
+func (self *Hook) stop(timeout time.Duration) {
+	close(self.queue)
+	if timeout > 0 {
+		select {
+		case <-self.done:
+			return
+		case <-time.After(timeout):
+			close(self.quit)
+		}
+	}
+	<-self.done
+}
+
+func writeDead(d *deadLetter) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	deadMu.Lock()
+	defer deadMu.Unlock()
+	if err = os.MkdirAll(filepath.Dir(deadFile), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(deadFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliver(t *testing.T) {
+	var got []*http.Request
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = append(got, r)
+		bodies = append(bodies, b)
+		if r.Header.Get(EventHeader) == Alert {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	defer func(old string) { deadFile = old }(deadFile)
+	deadFile = filepath.Join(t.TempDir(), "webhook_dead.jsonl")
+
+	h := &Hook{Name: "test", URL: srv.URL, Secret: "key", Retries: -1}
+	if err := h.init(); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEvent(RunFinished, map[string]int{"items": 3})
+	if err := h.Deliver(e); err != nil {
+		t.Fatalf("Deliver() = %v", err)
+	}
+	r, body := got[0], bodies[0]
+	if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("method = %s, content-type = %q", r.Method, r.Header["Content-Type"])
+	}
+	if sig := r.Header.Get(SignatureHeader); sig != Sign("key", body) {
+		t.Errorf("signature = %q, want %q", sig, Sign("key", body))
+	}
+	var back Event
+	if err := json.Unmarshal(body, &back); err != nil || back.ID != e.ID || back.Type != RunFinished {
+		t.Errorf("body = %s, %v", body, err)
+	}
+
+	// 失败且不重试时写入死信文件
+	if err := h.Deliver(NewEvent(Alert, nil)); err == nil {
+		t.Fatal("Deliver() should fail on status 500")
+	}
+	if len(got) != 2 {
+		t.Errorf("requests = %d, want 2", len(got))
+	}
+	b, err := ioutil.ReadFile(deadFile)
+	if err != nil || !strings.Contains(string(b), `"hook":"test"`) || !strings.Contains(string(b), "500") {
+		t.Errorf("dead letter = %s, %v", b, err)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	defer func(old string) { deadFile = old }(deadFile)
+	deadFile = filepath.Join(t.TempDir(), "webhook_dead.jsonl")
+
+	// 首个事件发送失败后等待重试，其余事件留在队列中
+	h := &Hook{Name: "test", URL: srv.URL}
+	if err := h.init(); err != nil {
+		t.Fatal(err)
+	}
+	h.start()
+	events := []*Event{NewEvent(RunFinished, nil), NewEvent(RunFinished, nil), NewEvent(RunFinished, nil)}
+	for _, e := range events {
+		h.enqueue(e)
+	}
+	start := time.Now()
+	h.stop(100 * time.Millisecond)
+	if d := time.Since(start); d >= backoffBase {
+		t.Errorf("stop() took %v, the retry was not interrupted", d)
+	}
+	select {
+	case <-h.done:
+	default:
+		t.Fatal("worker still running after stop()")
+	}
+
+	b, err := ioutil.ReadFile(deadFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != len(events) {
+		t.Fatalf("dead letters:\n%s", b)
+	}
+	for i, line := range lines {
+		var d deadLetter
+		if err := json.Unmarshal([]byte(line), &d); err != nil {
+			t.Fatal(err)
+		}
+		if d.Event.ID != events[i].ID || !strings.Contains(d.Error, "not sent before exit") {
+			t.Errorf("dead letter %d = %s", i, line)
+		}
+		// 正在重试的事件记录已尝试的次数及失败原因
+		if i == 0 && (d.Attempts != 1 || !strings.Contains(d.Error, "500")) {
+			t.Errorf("retrying event = %s", line)
+		}
+	}
+}
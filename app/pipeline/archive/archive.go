@@ -0,0 +1,384 @@
+// 归档：将 mysql 输出的原始数据按蜘蛛及规则(即每张原始表)汇总为按小时、按天的统计表，
+// 记录各数值字段的最小、最大、首个、末个及平均值，并清理或转存超过保留时长的原始数据
+package archive
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"skynet-service/app/common/mysql"
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+)
+
+// 归档产生的表名后缀
+const (
+	HourlySuffix  = "__hourly"  // 按小时统计
+	DailySuffix   = "__daily"   // 按天统计
+	ArchiveSuffix = "__archive" // move 方式下转存的原始数据
+)
+
+// 超过保留时长的原始数据的处理方式
+const (
+	Delete = "delete"
+	Move   = "move"
+)
+
+const (
+	stateTable   = "skynet_archive_hours" // 各原始表已汇总的每小时数据的行数及校验值
+	timeLayout   = "2006-01-02 15:04:05"
+	maxTableName = 64 // mysql 表名的最大长度
+)
+
+// 不参与统计的字段
+var skipColumns = map[string]bool{
+	"id":           true,
+	"Url":          true,
+	"ParentUrl":    true,
+	"DownloadTime": true,
+}
+
+// 一次归档的结果
+type Result struct {
+	Tables int   // 汇总的原始表数
+	Rows   int64 // 重新汇总的原始数据行数
+	Hours  int   // 更新的小时统计时段数
+	Days   int   // 更新的天统计时段数
+	Pruned int64 // 清理或转存的原始数据行数
+}
+
+// 是否为归档产生的表
+func IsArchiveTable(name string) bool {
+	return name == stateTable ||
+		strings.HasSuffix(name, HourlySuffix) ||
+		strings.HasSuffix(name, DailySuffix) ||
+		strings.HasSuffix(name, ArchiveSuffix)
+}
+
+// 汇总全部原始表，并按 config.Current().ArchiveRetention 清理原始数据
+func Run(now time.Time) (*Result, error) {
+	mysql.Refresh()
+	db, err := mysql.DB()
+	if err != nil {
+		return nil, fmt.Errorf("Connect to mysql error: %v", err)
+	}
+	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS `" + stateTable + "` (" +
+		"table_name VARCHAR(64) NOT NULL, hour VARCHAR(13) NOT NULL, row_count BIGINT NOT NULL, checksum VARCHAR(20) NOT NULL, " +
+		"updated VARCHAR(19) NOT NULL, PRIMARY KEY (table_name, hour)) DEFAULT CHARSET=utf8"); err != nil {
+		return nil, err
+	}
+	names, err := queryStrings(db, "SHOW TABLES")
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := pruneCutoff(now, config.Current().ArchiveRetention)
+	result := &Result{}
+	for _, name := range names {
+		if IsArchiveTable(name) {
+			continue
+		}
+		ok, err := archiveTable(db, name, now, cutoff, result)
+		if err != nil {
+			logs.Log.Error(" *     [归档：%s]   %v", name, err)
+			continue
+		}
+		if ok {
+			result.Tables++
+		}
+	}
+	return result, nil
+}
+
+// 汇总一张原始表，无自增主键或下载时间字段时跳过并返回 false。
+// 原始数据可能被原地更新(按唯一键覆盖)或晚于 id 更大的行提交，因此不以 id 为水位，
+// 而是每次比较各小时数据的行数及校验值，重新统计有变化的小时
+func archiveTable(db *sql.DB, name string, now time.Time, cutoff string, result *Result) (bool, error) {
+	if utf8.RuneCountInString(name+ArchiveSuffix) > maxTableName {
+		logs.Log.Warning(" *     [归档：%s]   表名过长，跳过", name)
+		return false, nil
+	}
+	columns, err := queryStrings(db, "SHOW COLUMNS FROM `"+name+"`")
+	if err != nil {
+		return false, err
+	}
+	var hasId, hasTime bool
+	for _, c := range columns {
+		hasId = hasId || c == "id"
+		hasTime = hasTime || c == "DownloadTime"
+	}
+	if !hasId || !hasTime {
+		return false, nil
+	}
+
+	current, err := queryBuckets(db, checksumSQL(name, columns))
+	if err != nil {
+		return false, err
+	}
+	archived, err := queryBuckets(db, "SELECT hour, row_count, checksum FROM `"+stateTable+"` WHERE table_name = ?", name)
+	if err != nil {
+		return false, err
+	}
+	hours := changedHours(current, archived, cutoff)
+	if len(hours) > 0 {
+		if err = createStatTable(db, name+HourlySuffix); err != nil {
+			return false, err
+		}
+		if err = createStatTable(db, name+DailySuffix); err != nil {
+			return false, err
+		}
+	}
+	days := map[string]bool{}
+	for _, h := range hours {
+		rows, err := readHour(db, name, Hour(h))
+		if err != nil {
+			return false, err
+		}
+		if err = replaceStats(db, name+HourlySuffix, []string{Hour(h)}, Aggregate(rows, Hour)); err != nil {
+			return false, err
+		}
+		// 数据已全部移出该小时，不再记录
+		b, ok := current[h]
+		if ok {
+			_, err = db.Exec("REPLACE INTO `"+stateTable+"` (table_name, hour, row_count, checksum, updated) VALUES (?, ?, ?, ?, ?)",
+				name, h, b.rows, b.checksum, now.Format(timeLayout))
+		} else {
+			_, err = db.Exec("DELETE FROM `"+stateTable+"` WHERE table_name = ? AND hour = ?", name, h)
+		}
+		if err != nil {
+			return false, err
+		}
+		days[Day(h)] = true
+		result.Hours++
+		result.Rows += int64(len(rows))
+	}
+	for day := range days {
+		hourly, err := readStats(db, name+HourlySuffix, day)
+		if err != nil {
+			return false, err
+		}
+		if err = replaceStats(db, name+DailySuffix, []string{day}, Rollup(hourly, Day)); err != nil {
+			return false, err
+		}
+		result.Days++
+	}
+
+	if cutoff != "" && len(current) > 0 {
+		n, err := prune(db, name, cutoff)
+		if err != nil {
+			return true, err
+		}
+		result.Pruned += n
+	}
+	return true, nil
+}
+
+// 原始数据一小时内的行数及校验值
+type bucket struct {
+	rows     int64
+	checksum string
+}
+
+// 按小时(DownloadTime 的前13个字符)统计原始表的行数，及各行全部字段的 CRC32 异或值
+func checksumSQL(name string, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = "`" + c + "`"
+	}
+	return "SELECT LEFT(DownloadTime, 13), COUNT(*), BIT_XOR(CRC32(CONCAT_WS(0x1f, " + strings.Join(quoted, ", ") + "))) " +
+		"FROM `" + name + "` GROUP BY LEFT(DownloadTime, 13)"
+}
+
+// 须重新统计的小时，按时间先后排列：行数或校验值有变化的小时，
+// 以及已汇总但数据已全部移出的小时；早于 cutoff 的小时已被清理，不再统计
+func changedHours(current, archived map[string]bucket, cutoff string) []string {
+	var hours []string
+	for h, b := range current {
+		if len(h) == 13 && archived[h] != b {
+			hours = append(hours, h)
+		}
+	}
+	for h := range archived {
+		if _, ok := current[h]; !ok && len(h) == 13 && (cutoff == "" || Hour(h) >= cutoff) {
+			hours = append(hours, h)
+		}
+	}
+	sort.Strings(hours)
+	return hours
+}
+
+// 原始数据的清理时刻，取整到小时以使每个小时的数据被整体清理；retention 不大于0时不清理
+func pruneCutoff(now time.Time, retention int) string {
+	if retention <= 0 {
+		return ""
+	}
+	return now.Add(-time.Duration(retention) * time.Hour).Truncate(time.Hour).Format(timeLayout)
+}
+
+// 执行查询，返回以第一列为键的行数及校验值
+func queryBuckets(db *sql.DB, code string, args ...interface{}) (map[string]bucket, error) {
+	rows, err := db.Query(code, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m := map[string]bucket{}
+	for rows.Next() {
+		var (
+			hour sql.NullString
+			b    bucket
+		)
+		if err = rows.Scan(&hour, &b.rows, &b.checksum); err != nil {
+			return nil, err
+		}
+		m[hour.String] = b
+	}
+	return m, rows.Err()
+}
+
+// 读取一小时内的原始数据，按时间先后排列
+func readHour(db *sql.DB, name, hour string) ([]Row, error) {
+	start, err := time.ParseInLocation(timeLayout, hour, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT * FROM `"+name+"` WHERE DownloadTime >= ? AND DownloadTime < ? ORDER BY DownloadTime, id",
+		hour, start.Add(time.Hour).Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Row
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := Row{Fields: make(map[string]string, len(columns))}
+		for i, c := range columns {
+			switch {
+			case c == "DownloadTime":
+				row.Time = values[i].String
+			case !skipColumns[c] && values[i].Valid:
+				row.Fields[c] = values[i].String
+			}
+		}
+		list = append(list, row)
+	}
+	return list, rows.Err()
+}
+
+func createStatTable(db *sql.DB, name string) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS `" + name + "` (" +
+		"period VARCHAR(19) NOT NULL, field VARCHAR(191) NOT NULL, count BIGINT NOT NULL, " +
+		"min DOUBLE, max DOUBLE, first DOUBLE, last DOUBLE, avg DOUBLE, sum DOUBLE, " +
+		"PRIMARY KEY (period, field)) DEFAULT CHARSET=utf8")
+	return err
+}
+
+// 以 stats 替换统计表中 periods 各时段的全部统计
+func replaceStats(db *sql.DB, name string, periods []string, stats []*Stat) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, p := range periods {
+		if _, err = tx.Exec("DELETE FROM `"+name+"` WHERE period = ?", p); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, st := range stats {
+		if _, err = tx.Exec("INSERT INTO `"+name+"` (period, field, count, min, max, first, last, avg, sum) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			st.Period, st.Field, st.Count, st.Min, st.Max, st.First, st.Last, st.Avg(), st.Sum); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 读取统计表中以 prefix 开头的时段，按时段先后排列
+func readStats(db *sql.DB, name, prefix string) ([]*Stat, error) {
+	rows, err := db.Query("SELECT period, field, count, min, max, first, last, sum FROM `"+name+"` WHERE period LIKE ? ORDER BY period, field", prefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*Stat
+	for rows.Next() {
+		st := &Stat{}
+		if err = rows.Scan(&st.Period, &st.Field, &st.Count, &st.Min, &st.Max, &st.First, &st.Last, &st.Sum); err != nil {
+			return nil, err
+		}
+		list = append(list, st)
+	}
+	return list, rows.Err()
+}
+
+// 清理或转存早于 cutoff 的原始数据，这些数据所在的小时均已汇总
+func prune(db *sql.DB, name, cutoff string) (int64, error) {
+	where := " WHERE DownloadTime < ?"
+	move := config.Current().ArchiveMode == Move
+	// 建表会隐式提交事务，须在事务之外执行
+	if move {
+		if _, err := db.Exec("CREATE TABLE IF NOT EXISTS `" + name + ArchiveSuffix + "` LIKE `" + name + "`"); err != nil {
+			return 0, err
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	if move {
+		if _, err = tx.Exec("INSERT INTO `"+name+ArchiveSuffix+"` SELECT * FROM `"+name+"`"+where, cutoff); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	res, err := tx.Exec("DELETE FROM `"+name+"`"+where, cutoff)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	// 已清理的小时不再比较
+	if _, err = tx.Exec("DELETE FROM `"+stateTable+"` WHERE table_name = ? AND hour < ?", name, cutoff[:13]); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// 执行查询，返回每行的第一列
+func queryStrings(db *sql.DB, code string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(code, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []string
+	for rows.Next() {
+		var s sql.NullString
+		if err = rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		list = append(list, s.String)
+	}
+	return list, rows.Err()
+}
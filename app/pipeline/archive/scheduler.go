@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"sync"
+	"time"
+
+	"skynet-service/app/logs"
+)
+
+// 调度描述，如 cron.Parse 的结果
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+// 按计划执行归档
+type Archiver struct {
+	schedule Schedule
+	stop     chan struct{}
+	done     chan struct{}
+	sync.Mutex
+}
+
+func New(schedule Schedule) *Archiver {
+	return &Archiver{schedule: schedule}
+}
+
+// 在后台按计划归档
+func (self *Archiver) Start() {
+	self.Lock()
+	defer self.Unlock()
+	if self.stop != nil {
+		return
+	}
+	self.stop = make(chan struct{})
+	self.done = make(chan struct{})
+	go self.loop(self.stop, self.done)
+}
+
+// 停止后台归档，等待正在进行的归档完成
+func (self *Archiver) Stop() {
+	self.Lock()
+	if self.stop == nil {
+		self.Unlock()
+		return
+	}
+	close(self.stop)
+	done := self.done
+	self.stop = nil
+	self.Unlock()
+	<-done
+}
+
+func (self *Archiver) loop(stop, done chan struct{}) {
+	defer close(done)
+	for {
+		timer := time.NewTimer(time.Until(self.schedule.Next(time.Now())))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			start := time.Now()
+			r, err := Run(now)
+			if err != nil {
+				logs.Log.Error(" *     归档失败：%v", err)
+				continue
+			}
+			logs.Log.Informational(" *     归档完成：%d 张表，重新汇总 %d 行，更新 %d 个小时及 %d 天的统计，清理 %d 行，用时 %v",
+				r.Tables, r.Rows, r.Hours, r.Days, r.Pruned, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package archive
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// 一个时段内某个数值字段的统计
+	Stat struct {
+		Period string // 时段的起始时间，小时为 "2006-01-02 15:00:00"，天为 "2006-01-02"
+		Field  string
+		Count  int64
+		Min    float64
+		Max    float64
+		First  float64
+		Last   float64
+		Sum    float64
+	}
+	// 一行原始数据
+	Row struct {
+		Time   string            // 下载时间，格式为 "2006-01-02 15:04:05"
+		Fields map[string]string // 除 id 及默认字段外的各字段
+	}
+)
+
+func (self *Stat) Avg() float64 {
+	if self.Count == 0 {
+		return 0
+	}
+	return self.Sum / float64(self.Count)
+}
+
+// 按时间先后加入一个数值
+func (self *Stat) add(v float64) {
+	if self.Count == 0 {
+		self.Min, self.Max, self.First = v, v, v
+	}
+	if v < self.Min {
+		self.Min = v
+	}
+	if v > self.Max {
+		self.Max = v
+	}
+	self.Last = v
+	self.Sum += v
+	self.Count++
+}
+
+// 合并时间在其后的统计
+func (self *Stat) merge(o *Stat) {
+	if o.Count == 0 {
+		return
+	}
+	if self.Count == 0 {
+		self.Min, self.Max, self.First = o.Min, o.Max, o.First
+	}
+	if o.Min < self.Min {
+		self.Min = o.Min
+	}
+	if o.Max > self.Max {
+		self.Max = o.Max
+	}
+	self.Last = o.Last
+	self.Sum += o.Sum
+	self.Count += o.Count
+}
+
+// 按时段统计各字段的数值，rows 须按时间先后排列，无法解析为数值的值不计入
+func Aggregate(rows []Row, period func(t string) string) []*Stat {
+	stats := map[[2]string]*Stat{}
+	for _, row := range rows {
+		p := period(row.Time)
+		for field, s := range row.Fields {
+			v, ok := parseNumber(s)
+			if !ok {
+				continue
+			}
+			k := [2]string{p, field}
+			st, ok := stats[k]
+			if !ok {
+				st = &Stat{Period: p, Field: field}
+				stats[k] = st
+			}
+			st.add(v)
+		}
+	}
+	return sortStats(stats)
+}
+
+// 将较短时段的统计合并为较长时段，stats 须按时段先后排列
+func Rollup(stats []*Stat, period func(p string) string) []*Stat {
+	merged := map[[2]string]*Stat{}
+	for _, st := range stats {
+		k := [2]string{period(st.Period), st.Field}
+		m, ok := merged[k]
+		if !ok {
+			m = &Stat{Period: k[0], Field: k[1]}
+			merged[k] = m
+		}
+		m.merge(st)
+	}
+	return sortStats(merged)
+}
+
+func sortStats(m map[[2]string]*Stat) []*Stat {
+	list := make([]*Stat, 0, len(m))
+	for _, st := range m {
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Period != list[j].Period {
+			return list[i].Period < list[j].Period
+		}
+		return list[i].Field < list[j].Field
+	})
+	return list
+}
+
+// 所在小时，如 "2006-01-02 15:00:00"
+func Hour(t string) string {
+	if len(t) < 13 {
+		return t
+	}
+	return t[:13] + ":00:00"
+}
+
+// 所在日期，如 "2006-01-02"
+func Day(t string) string {
+	if len(t) < 10 {
+		return t
+	}
+	return t[:10]
+}
+
+// 解析数值，允许千分位逗号、百分号及首尾空白
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", "", -1), 64)
+	return v, err == nil && !math.IsNaN(v) && !math.IsInf(v, 0)
+}
@@ -0,0 +1,79 @@
+package archive
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	rows := []Row{
+		{"2024-01-01 09:05:00", map[string]string{"price": "2,010.5", "name": "gold"}},
+		{"2024-01-01 09:35:00", map[string]string{"price": "1990.5", "rate": "1.5%"}},
+		{"2024-01-01 10:00:00", map[string]string{"price": "2000", "rate": "n/a"}},
+	}
+	hourly := Aggregate(rows, Hour)
+	if len(hourly) != 3 {
+		t.Fatalf("len(hourly) = %d, want 3", len(hourly))
+	}
+	if st := hourly[0]; st.Period != "2024-01-01 09:00:00" || st.Field != "price" ||
+		st.Count != 2 || st.Min != 1990.5 || st.Max != 2010.5 || st.First != 2010.5 || st.Last != 1990.5 || st.Avg() != 2000.5 {
+		t.Errorf("hourly[0] = %+v", st)
+	}
+	if st := hourly[1]; st.Field != "rate" || st.Count != 1 || st.Avg() != 1.5 {
+		t.Errorf("hourly[1] = %+v", st)
+	}
+
+	daily := Rollup(hourly, Day)
+	if len(daily) != 2 {
+		t.Fatalf("len(daily) = %d, want 2", len(daily))
+	}
+	if st := daily[0]; st.Period != "2024-01-01" || st.Field != "price" ||
+		st.Count != 3 || st.Min != 1990.5 || st.Max != 2010.5 || st.First != 2010.5 || st.Last != 2000 || st.Sum != 6001 {
+		t.Errorf("daily[0] = %+v", st)
+	}
+}
+
+func TestChangedHours(t *testing.T) {
+	archived := map[string]bucket{
+		"2024-01-01 08": {3, "111"}, // 已清理
+		"2024-01-01 09": {2, "222"}, // 未变化
+		"2024-01-01 10": {2, "333"}, // 原地更新，行数不变
+		"2024-01-01 11": {1, "444"}, // 晚提交的行
+		"2024-01-01 12": {1, "555"}, // 数据已全部移出
+	}
+	current := map[string]bucket{
+		"2024-01-01 09": {2, "222"},
+		"2024-01-01 10": {2, "334"},
+		"2024-01-01 11": {2, "440"},
+		"2024-01-01 13": {1, "666"}, // 新数据
+		"2024-01-01":    {1, "777"}, // 下载时间格式有误
+	}
+	got := changedHours(current, archived, "2024-01-01 09:00:00")
+	if want := "[2024-01-01 10 2024-01-01 11 2024-01-01 12 2024-01-01 13]"; fmt.Sprint(got) != want {
+		t.Errorf("changedHours() = %v, want %s", got, want)
+	}
+	// 不清理时已移出的小时均须重新统计
+	if got = changedHours(nil, archived, ""); len(got) != len(archived) {
+		t.Errorf("changedHours(nil) = %v", got)
+	}
+}
+
+func TestPruneCutoff(t *testing.T) {
+	now := time.Date(2024, 1, 3, 10, 25, 0, 0, time.Local)
+	if got := pruneCutoff(now, 0); got != "" {
+		t.Errorf("pruneCutoff(0) = %q", got)
+	}
+	// 取整到小时，10:25 时保留 48 小时即清理 2024-01-01 10:00 之前的数据
+	if got := pruneCutoff(now, 48); got != "2024-01-01 10:00:00" {
+		t.Errorf("pruneCutoff(48) = %q", got)
+	}
+}
+
+func TestChecksumSQL(t *testing.T) {
+	got := checksumSQL("gold", []string{"id", "price", "DownloadTime"})
+	want := "SELECT LEFT(DownloadTime, 13), COUNT(*), BIT_XOR(CRC32(CONCAT_WS(0x1f, `id`, `price`, `DownloadTime`))) FROM `gold` GROUP BY LEFT(DownloadTime, 13)"
+	if got != want {
+		t.Errorf("checksumSQL() = %s", got)
+	}
+}
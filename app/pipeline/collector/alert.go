@@ -0,0 +1,18 @@
+package collector
+
+import (
+	"time"
+
+	"skynet-service/app/alert"
+	"skynet-service/app/pipeline/collector/data"
+)
+
+// 按告警规则检查结果，没有针对该蜘蛛的规则时跳过
+func (self *Collector) checkAlert(cell data.DataCell) {
+	name := self.Spider.GetName()
+	if !alert.Alerts.Watches(name) {
+		return
+	}
+	ruleName, _ := cell["RuleName"].(string)
+	alert.Alerts.Check(name, ruleName, cellItem(cell), time.Now())
+}
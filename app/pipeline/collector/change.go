@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"skynet-service/app/aid/change"
+	"skynet-service/app/logs"
+	"skynet-service/app/pipeline/collector/data"
+	"skynet-service/app/spider"
+)
+
+// 任一规则设置了 ItemKey 时创建变化检测器
+func newDetector(sp *spider.Spider) *change.Detector {
+	for _, rule := range sp.RuleTree.Trunk {
+		if rule.ItemKey != "" {
+			return change.New(sp.GetName(), sp.GetKeyin(), sp.GetSubName())
+		}
+	}
+	return nil
+}
+
+// 检测结果变化，规则未设置 ItemKey 时跳过
+func (self *Collector) detectChange(cell data.DataCell) {
+	ruleName, _ := cell["RuleName"].(string)
+	rule, ok := self.GetRule(ruleName)
+	if !ok || rule.ItemKey == "" {
+		return
+	}
+	item := cellItem(cell)
+	key := item[rule.ItemKey]
+	if key == "" {
+		logs.Log.Warning(" *     [结果变化：%s | %s]   标识字段 %s 为空，跳过检测", self.Spider.GetName(), ruleName, rule.ItemKey)
+		return
+	}
+	if e := self.detector.Check(ruleName, key, item); e != nil {
+		change.Emit(e)
+	}
+}
+
+// 以字符串形式返回结果的各字段
+func cellItem(cell data.DataCell) map[string]string {
+	vd, _ := cell["Data"].(map[string]interface{})
+	item := make(map[string]string, len(vd))
+	for field, v := range vd {
+		item[field] = spider.FormatValue(v)
+	}
+	return item
+}
+
+// 结束变化检测，中途终止时不产生删除事件
+func (self *Collector) finishDetect() {
+	change.Emit(self.detector.Finish(!self.Spider.IsStopping())...)
+}
@@ -7,6 +7,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"skynet-service/app/aid/change"
 	"skynet-service/app/pipeline/collector/data"
 	"skynet-service/app/runtime/cache"
 	"skynet-service/app/spider"
@@ -19,6 +20,7 @@ type Collector struct {
 	FileChan       chan data.FileCell 		// 文件收集通道
 	dataDocker     []data.DataCell    		// 分批输出结果缓存
 	outType        string             		// 输出方式
+	detector       *change.Detector   		// 结果变化检测，未设置 ItemKey 时为 nil
 	// size     [2]uint64 					// 数据总输出流量统计[文本，文件]，文本暂时未统计
 	dataBatch   uint64 						// 当前文本输出批次
 	fileBatch   uint64 						// 当前文件输出批次
@@ -32,6 +34,7 @@ func NewCollector(sp *spider.Spider) *Collector {
 	var self = &Collector{}
 	self.Spider = sp
 	self.outType = cache.Task.OutType
+	self.detector = newDetector(sp)
 	if cache.Task.DockerCap < 1 {
 		cache.Task.DockerCap = 1
 	}
@@ -96,6 +99,13 @@ func (self *Collector) Start() {
 				// println("DataChanStop$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$")
 			}()
 			for data := range self.DataChan {
+				// 检测结果变化
+				if self.detector != nil {
+					self.detectChange(data)
+				}
+				// 检查数值告警
+				self.checkAlert(data)
+
 				// 缓存分批数据
 				self.dataDocker = append(self.dataDocker, data)
 
@@ -136,6 +146,10 @@ func (self *Collector) Start() {
 		self.wait.Wait()
 		// println("OutputStopped$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$")
 
+		if self.detector != nil {
+			self.finishDetect()
+		}
+
 		// 返回报告
 		self.Report()
 	}()
@@ -200,11 +214,14 @@ func (self *Collector) addFileSum(add uint64) {
 
 // 返回报告
 func (self *Collector) Report() {
+	p, _ := self.Spider.Progress()
 	cache.ReportChan <- &cache.Report{
 		SpiderName: self.Spider.GetName(),
 		Keyin:      self.GetKeyin(),
 		DataNum:    self.dataSum(),
 		FileNum:    self.fileSum(),
+		Success:    p.Success,
+		Failure:    p.Failure,
 		// DataSize:   self.dataSize(),
 		// FileSize: self.fileSize(),
 		Time: time.Since(cache.StartTime),
@@ -32,13 +32,10 @@ func init() {
 			var subNamespace = util.FileNameReplace(self.subNamespace(datacell))
 
 			tmp := make(map[string]interface{})
-			for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
-				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					tmp[title] = v
-				} else {
-					tmp[title] = util.JsonString(vd[title])
-				}
+			rule := self.MustGetRule(datacell["RuleName"].(string))
+			vd := datacell["Data"].(map[string]interface{})
+			for _, title := range rule.ItemFields {
+				tmp[title] = itemValue(rule, title, vd[title])
 			}
 			if self.Spider.OutDefaultField() {
 				tmp["Url"] = datacell["Url"].(string)
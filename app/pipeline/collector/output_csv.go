@@ -3,12 +3,18 @@ package collector
 import (
 	"encoding/csv"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"skynet-service/app/common/util"
 	"skynet-service/app/config"
 	"skynet-service/app/logs"
 	"skynet-service/app/runtime/cache"
+	"skynet-service/app/spider"
 )
 
 /************************ CSV 输出 ***************************/
@@ -64,11 +70,7 @@ func init() {
 			row := []string{}
 			for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
 				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					row = append(row, v)
-				} else {
-					row = append(row, util.JsonString(vd[title]))
-				}
+				row = append(row, spider.FormatValue(vd[title]))
 			}
 			if self.Spider.OutDefaultField() {
 				row = append(row, datacell["Url"].(string))
@@ -79,4 +81,89 @@ func init() {
 		}
 		return
 	}
+	DataQuery["csv"] = func(sp *spider.Spider, since time.Time, limit int) ([]*Table, error) {
+		namespace := spiderNamespace(sp)
+		batches, err := ioutil.ReadDir(config.TEXT_DIR)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		var (
+			tables []*Table
+			index  = map[string]*Table{}
+		)
+		// 每次运行的输出目录以开始时间命名，按时间先后读取
+		sort.Slice(batches, func(i, j int) bool { return batches[i].Name() < batches[j].Name() })
+		for _, batch := range batches {
+			if !batch.IsDir() {
+				continue
+			}
+			dirs, _ := ioutil.ReadDir(filepath.Join(config.TEXT_DIR, batch.Name()))
+			for _, dir := range dirs {
+				// 目录的修改时间即最后一次写入文件的时间
+				if !dir.IsDir() || !inNamespace(dir.Name(), namespace) || dir.ModTime().Before(since) {
+					continue
+				}
+				files, _ := filepath.Glob(filepath.Join(config.TEXT_DIR, batch.Name(), dir.Name(), "*.csv"))
+				for _, file := range files {
+					columns, rows, err := readCsv(file, since)
+					if err != nil {
+						return nil, err
+					}
+					if len(rows) == 0 {
+						continue
+					}
+					key := dir.Name() + "\x00" + strings.Join(columns, "\x00")
+					table, ok := index[key]
+					if !ok {
+						table = &Table{Name: dir.Name(), Columns: columns}
+						index[key] = table
+						tables = append(tables, table)
+					}
+					table.Rows = lastRows(append(table.Rows, rows...), limit)
+				}
+			}
+		}
+		return tables, nil
+	}
+}
+
+// 读取 csv 文件，有下载时间字段时只返回 since 之后的行
+func readCsv(file string, since time.Time) (columns []string, rows [][]string, err error) {
+	info, err := os.Stat(file)
+	if err != nil || info.ModTime().Before(since) {
+		return nil, nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+	columns = records[0]
+	if len(columns) > 0 {
+		columns[0] = strings.TrimPrefix(columns[0], "\xEF\xBB\xBF")
+	}
+	timeCol := -1
+	for i, c := range columns {
+		if c == "下载时间" {
+			timeCol = i
+		}
+	}
+	start := since.Format("2006-01-02 15:04:05")
+	for _, row := range records[1:] {
+		if timeCol >= 0 && timeCol < len(row) && row[timeCol] < start {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
 }
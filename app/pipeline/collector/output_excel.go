@@ -3,12 +3,14 @@ package collector
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"skynet-service/app/common/util"
 	"skynet-service/app/common/xlsx"
 	"skynet-service/app/config"
 	"skynet-service/app/logs"
 	"skynet-service/app/runtime/cache"
+	"skynet-service/app/spider"
 )
 
 /************************ excel 输出 ***************************/
@@ -57,11 +59,7 @@ func init() {
 			for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
 				cell = row.AddCell()
 				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					cell.Value = v
-				} else {
-					cell.Value = util.JsonString(vd[title])
-				}
+				setExcelCell(cell, vd[title])
 			}
 			if self.Spider.OutDefaultField() {
 				row.AddCell().Value = datacell["Url"].(string)
@@ -85,3 +83,19 @@ func init() {
 		return
 	}
 }
+
+// 按值的类型设置单元格，使数值、布尔与时间字段在表格中保持原生类型
+func setExcelCell(cell *xlsx.Cell, v interface{}) {
+	switch x := v.(type) {
+	case int64:
+		cell.SetInt64(x)
+	case float64:
+		cell.SetFloat(x)
+	case bool:
+		cell.SetBool(x)
+	case time.Time:
+		cell.SetDateTime(x)
+	default:
+		cell.Value = spider.FormatValue(v)
+	}
+}
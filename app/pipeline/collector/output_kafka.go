@@ -61,13 +61,10 @@ func init() {
 				}
 			}
 			data := make(map[string]interface{})
-			for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
-				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					data[title] = v
-				} else {
-					data[title] = util.JsonString(vd[title])
-				}
+			rule := self.MustGetRule(datacell["RuleName"].(string))
+			vd := datacell["Data"].(map[string]interface{})
+			for _, title := range rule.ItemFields {
+				data[title] = itemValue(rule, title, vd[title])
 			}
 			if self.Spider.OutDefaultField() {
 				data["url"] = datacell["Url"].(string)
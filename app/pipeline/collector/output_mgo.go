@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	mgov2 "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 
 	"skynet-service/app/common/mgo"
 	"skynet-service/app/common/pool"
@@ -29,6 +30,7 @@ func init() {
 				namespace   = util.FileNameReplace(self.namespace())
 				collections = make(map[string]*mgov2.Collection)
 				dataMap     = make(map[string][]interface{})
+				upsertMap   = make(map[string][]interface{}) // 按唯一键更新的选择器与文档，成对存放
 				err         error
 			)
 
@@ -39,6 +41,7 @@ func init() {
 				if _, ok := collections[subNamespace]; !ok {
 					collections[subNamespace] = db.C(cName)
 				}
+				rule := self.MustGetRule(datacell["RuleName"].(string))
 				for k, v := range datacell["Data"].(map[string]interface{}) {
 					datacell[k] = v
 				}
@@ -49,7 +52,37 @@ func init() {
 					delete(datacell, "ParentUrl")
 					delete(datacell, "DownloadTime")
 				}
-				dataMap[subNamespace] = append(dataMap[subNamespace], datacell)
+				if len(rule.UniqueKey) == 0 {
+					dataMap[subNamespace] = append(dataMap[subNamespace], datacell)
+					continue
+				}
+				if _, ok := upsertMap[subNamespace]; !ok {
+					err = collections[subNamespace].EnsureIndex(mgov2.Index{Key: rule.UniqueKey, Unique: true})
+					if err != nil {
+						logs.Log.Error("%v", err)
+					}
+				}
+				selector := bson.M{}
+				for _, k := range rule.UniqueKey {
+					selector[k] = datacell[k]
+				}
+				upsertMap[subNamespace] = append(upsertMap[subNamespace], selector, bson.M{"$set": datacell})
+			}
+
+			for collection, pairs := range upsertMap {
+				c := collections[collection]
+				for i := 0; i < len(pairs); i += 2 * mgo.MaxLen {
+					end := i + 2*mgo.MaxLen
+					if end > len(pairs) {
+						end = len(pairs)
+					}
+					bulk := c.Bulk()
+					bulk.Unordered()
+					bulk.Upsert(pairs[i:end]...)
+					if _, err = bulk.Run(); err != nil {
+						logs.Log.Error("%v", err)
+					}
+				}
 			}
 
 			for collection, docs := range dataMap {
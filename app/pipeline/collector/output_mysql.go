@@ -1,12 +1,19 @@
 package collector
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"skynet-service/app/common/mysql"
 	"skynet-service/app/common/util"
 	"skynet-service/app/logs"
+	"skynet-service/app/pipeline/archive"
+	"skynet-service/app/spider"
 )
 
 /************************ Mysql 输出 ***************************/
@@ -41,6 +48,7 @@ func init() {
 		}
 		var (
 			mysqls    = make(map[string]*mysql.MyTable)
+			failed    = make(map[string]error) // 结构更新失败的表，本批跳过其数据
 			namespace = util.FileNameReplace(self.namespace())
 		)
 		
@@ -48,47 +56,243 @@ func init() {
 			subNamespace := util.FileNameReplace(self.subNamespace(datacell))
 			tName := joinNamespaces(namespace, subNamespace)
 			logs.Log.Debug("Table name:%s", tName)
+			if _, ok := failed[tName]; ok {
+				continue
+			}
+			rule := self.MustGetRule(datacell["RuleName"].(string))
+			fields := rule.ItemFields // 字段可能在解析过程中增加，以同一份列表建表与取值
+			want := self.mysqlTable(tName, rule, fields)
 			table, ok := mysqls[tName]
-			if !ok {
-				table, ok = getMysqlTable(tName)
+			if !ok || !table.SameColumns(want) {
 				if ok {
-					mysqls[tName] = table
-				} else {
-					table = mysql.New()
-					table.SetTableName(tName)
-					for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
-						table.AddColumn(title + ` MEDIUMTEXT`)
-					}
-					if self.Spider.OutDefaultField() {
-						table.AddColumn(`Url VARCHAR(255)`, `ParentUrl VARCHAR(255)`, `DownloadTime VARCHAR(50)`)
-					}
-					if err := table.Create(); err != nil {
-						logs.Log.Error("%v", err)
+					// 规则字段有变化，先写入按原结构缓存的数据
+					util.CheckErr(table.FlushInsert())
+				}
+				table, ok = getMysqlTable(tName)
+				if !ok || !table.SameColumns(want) {
+					// 新建表，或按新的字段同步已存在的表；
+					// 无法迁移时跳过该表本批的数据，不写入与规则不符的表，其余表照常写入，本批计为失败
+					if err := want.Create(); err != nil {
+						logs.Log.Error(" *     [数据输出：%v]   表 %v 结构更新失败：%v", self.Spider.GetName(), tName, err)
+						failed[tName] = err
 						continue
-					} else {
-						setMysqlTable(tName, table)
-						mysqls[tName] = table
 					}
+					setMysqlTable(tName, want)
+					table = want
 				}
+				mysqls[tName] = table
 			}
-			data := []string{}
-			for _, title := range self.MustGetRule(datacell["RuleName"].(string)).ItemFields {
-				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					data = append(data, v)
-				} else {
-					data = append(data, util.JsonString(vd[title]))
-				}
+			data := []interface{}{}
+			vd := datacell["Data"].(map[string]interface{})
+			for _, title := range fields {
+				data = append(data, mysqlValue(rule.FieldType(title), vd[title]))
 			}
 			if self.Spider.OutDefaultField() {
 				data = append(data, datacell["Url"].(string), datacell["ParentUrl"].(string), datacell["DownloadTime"].(string))
 			}
-			table.AutoInsert(data)
+			table.AutoInsertValues(data)
 		}
 		for _, tab := range mysqls {
 			util.CheckErr(tab.FlushInsert())
 		}
 		mysqls = nil
+		if len(failed) > 0 {
+			var names []string
+			for name := range failed {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			errs := make([]string, len(names))
+			for i, name := range names {
+				errs[i] = fmt.Sprintf("mysql table %s: %v", name, failed[name])
+			}
+			return errors.New(strings.Join(errs, "; "))
+		}
+		return nil
+	}
+	DataQuery["mysql"] = func(sp *spider.Spider, since time.Time, limit int) ([]*Table, error) {
+		mysql.Refresh()
+		db, err := mysql.DB()
+		if err != nil {
+			return nil, fmt.Errorf("Connect to mysql error: %v", err)
+		}
+		return queryMysql(db, sp, since, limit)
+	}
+}
+
+// 读取蜘蛛主命名空间下各表(不含归档表)中 since 之后的数据
+func queryMysql(db *sql.DB, sp *spider.Spider, since time.Time, limit int) ([]*Table, error) {
+	names, err := queryStrings(db, "SHOW TABLES")
+	if err != nil {
+		return nil, err
+	}
+	namespace := spiderNamespace(sp)
+	var tables []*Table
+	for _, name := range names {
+		if !inNamespace(name, namespace) || archive.IsArchiveTable(name) {
+			continue
+		}
+		table, err := queryMysqlTable(db, name, since, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(table.Rows) > 0 {
+			tables = append(tables, table)
+		}
+	}
+	return tables, nil
+}
+
+// 按规则的字段生成表结构
+func (self *Collector) mysqlTable(name string, rule *spider.Rule, fields []string) *mysql.MyTable {
+	table := mysql.New()
+	table.SetTableName(name)
+	for _, title := range fields {
+		table.AddColumn(title + ` ` + mysqlColumnType(rule.FieldType(title), rule.IsUniqueKey(title)))
+	}
+	table.SetUniqueKey(rule.UniqueKey...)
+	if self.Spider.OutDefaultField() {
+		table.AddColumn(`Url VARCHAR(255)`, `ParentUrl VARCHAR(255)`, `DownloadTime VARCHAR(50)`)
+	}
+	return table
+}
+
+// 字段类型对应的列类型，未声明类型的字段为 MEDIUMTEXT，
+// 唯一索引中的字符串字段为 VARCHAR 以便建立索引
+func mysqlColumnType(typ string, unique bool) string {
+	switch typ {
+	case "", spider.TypeString:
+		if unique {
+			return `VARCHAR(255)`
+		}
+	case spider.TypeInt:
+		return `BIGINT`
+	case spider.TypeFloat:
+		return `DOUBLE`
+	case spider.TypeBool:
+		return `TINYINT(1)`
+	case spider.TypeTime:
+		return `DATETIME`
+	case spider.TypeJSON:
+		return `JSON`
+	}
+	return `MEDIUMTEXT`
+}
+
+// 字段值对应的插入值：声明了类型的字段缺少值时为 NULL，
+// 时间按本地时间格式化(驱动默认按 UTC 发送 time.Time)
+func mysqlValue(typ string, v interface{}) interface{} {
+	switch typ {
+	case "", spider.TypeString:
+		return spider.FormatValue(v)
+	}
+	switch x := v.(type) {
+	case nil:
 		return nil
+	case time.Time:
+		return x.Format(spider.TimeLayout)
+	}
+	if typ == spider.TypeJSON {
+		return util.JsonString(v)
+	}
+	return v
+}
+
+// 读取一张表中 since 之后的数据，无下载时间字段时读取最近的 limit 行
+func queryMysqlTable(db *sql.DB, name string, since time.Time, limit int) (*Table, error) {
+	table := &Table{Name: name}
+	columns, err := queryStrings(db, "SHOW COLUMNS FROM `"+name+"`")
+	if err != nil {
+		return nil, err
+	}
+	var hasId, hasTime bool
+	for _, c := range columns {
+		switch c {
+		case "id":
+			hasId = true
+		case "DownloadTime":
+			hasTime = true
+		}
+	}
+
+	code := "SELECT * FROM `" + name + "`"
+	args := []interface{}{}
+	if hasTime {
+		code += " WHERE DownloadTime >= ?"
+		args = append(args, since.Format("2006-01-02 15:04:05"))
+	}
+	if hasId {
+		code += " ORDER BY id DESC"
+	}
+	if limit > 0 {
+		code += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	logs.Log.Debug("Sql code: %s", code)
+	rows, err := db.Query(code, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if table.Columns, err = rows.Columns(); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(table.Columns))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = v.String
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 去掉自增主键，并恢复为时间先后顺序
+	if hasId && len(table.Columns) > 0 && table.Columns[0] == "id" {
+		table.Columns = table.Columns[1:]
+		for i := range table.Rows {
+			table.Rows[i] = table.Rows[i][1:]
+		}
+	}
+	if hasId {
+		for i, j := 0, len(table.Rows)-1; i < j; i, j = i+1, j-1 {
+			table.Rows[i], table.Rows[j] = table.Rows[j], table.Rows[i]
+		}
+	}
+	return table, nil
+}
+
+// 执行查询，返回每行的第一列
+func queryStrings(db *sql.DB, code string) ([]string, error) {
+	rows, err := db.Query(code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for rows.Next() {
+		values := make([]sql.RawBytes, len(columns))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		list = append(list, string(values[0]))
 	}
+	return list, rows.Err()
 }
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"skynet-service/app/common/mysql"
+	"skynet-service/app/common/mysql/mysqltest"
+	"skynet-service/app/pipeline/collector/data"
+	"skynet-service/app/spider"
+)
+
+func TestMysqlOutputFailedMigration(t *testing.T) {
+	// 新建的表均无已有列；b 表的结构无法更新
+	fake := &mysqltest.DB{
+		Query: func(q string, args []driver.Value) (*mysqltest.Rows, error) {
+			return &mysqltest.Rows{Columns: []string{"COLUMN_NAME", "DATA_TYPE"}}, nil
+		},
+		Exec: func(q string, args []driver.Value) error {
+			if strings.HasSuffix(mysqltest.TableName(q), "__b") && !strings.HasPrefix(q, "INSERT") {
+				return errors.New("migration denied")
+			}
+			return nil
+		},
+	}
+	old, _ := mysql.DB()
+	defer mysql.SetDB(old)
+	mysql.SetDB(fake.Open())
+
+	sp := &spider.Spider{
+		Name:            "gold",
+		NotDefaultField: true,
+		RuleTree: &spider.RuleTree{Trunk: map[string]*spider.Rule{
+			"a": {ItemFields: []string{"title"}},
+			"b": {ItemFields: []string{"price"}},
+			"c": {ItemFields: []string{"volume"}},
+		}},
+	}
+	self := &Collector{Spider: sp}
+	for _, c := range []struct{ rule, field, value string }{
+		{"a", "title", "x"},
+		{"b", "price", "1"},
+		{"c", "volume", "2"},
+		{"b", "price", "3"},
+		{"a", "title", "y"},
+	} {
+		self.dataDocker = append(self.dataDocker, data.GetDataCell(c.rule, map[string]interface{}{c.field: c.value}, "", "", ""))
+	}
+
+	err := DataOutput["mysql"](self)
+	if err == nil || !strings.Contains(err.Error(), "__b: migration denied") {
+		t.Fatalf("err = %v", err)
+	}
+	inserts := fake.ExecsWithPrefix("INSERT INTO")
+	if len(inserts) != 2 {
+		t.Fatalf("inserts = %q", inserts)
+	}
+	for _, code := range inserts {
+		if strings.HasSuffix(mysqltest.TableName(code), "__b") {
+			t.Fatalf("rows of the failed table were written: %q", inserts)
+		}
+	}
+	for _, table := range []string{"__a", "__c"} {
+		found := false
+		for _, code := range inserts {
+			found = found || strings.HasSuffix(mysqltest.TableName(code), table)
+		}
+		if !found {
+			t.Errorf("rows of %s were not written: %q", table, inserts)
+		}
+	}
+	// b 表只尝试迁移一次
+	n := 0
+	for _, code := range fake.ExecsWithPrefix("CREATE TABLE") {
+		if strings.HasSuffix(mysqltest.TableName(code), "__b") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("table b created %d times", n)
+	}
+}
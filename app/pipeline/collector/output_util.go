@@ -2,6 +2,7 @@ package collector
 
 import (
 	"skynet-service/app/logs"
+	"skynet-service/app/spider"
 )
 
 // 主命名空间相对于数据库名，不依赖具体数据内容，可选
@@ -37,3 +38,12 @@ func joinNamespaces(namespace, subNamespace string) string {
 	}
 	return namespace
 }
+
+// 结构化输出(kafka、beanstalkd)的字段值，声明了类型的字段保持原生类型，其余为字符串
+func itemValue(rule *spider.Rule, title string, v interface{}) interface{} {
+	switch rule.FieldType(title) {
+	case "", spider.TypeString:
+		return spider.FormatValue(v)
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+
+	"skynet-service/app/common/util"
+	"skynet-service/app/spider"
+)
+
+// 已输出的一张数据表(主次命名空间)中的文本结果
+type Table struct {
+	Name    string     `json:"name"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// 支持读取已输出结果的输出方式，
+// 返回蜘蛛在 since 之后输出的文本结果，每张表最多返回最近的 limit 行
+var DataQuery = make(map[string]func(sp *spider.Spider, since time.Time, limit int) ([]*Table, error))
+
+// 从 outType 输出方式中读取蜘蛛在 since 之后输出的文本结果
+func Query(outType string, sp *spider.Spider, since time.Time, limit int) ([]*Table, error) {
+	query, ok := DataQuery[outType]
+	if !ok {
+		return nil, fmt.Errorf("output %s does not support query", outType)
+	}
+	return query(sp, since, limit)
+}
+
+// 蜘蛛输出结果所用的主命名空间，未自定义时不区分 Keyin
+func spiderNamespace(sp *spider.Spider) string {
+	if sp.Namespace == nil {
+		return util.FileNameReplace(sp.GetName())
+	}
+	return util.FileNameReplace(sp.Namespace(sp))
+}
+
+// 是否为主命名空间下的数据表
+func inNamespace(table, namespace string) bool {
+	return table == namespace || len(table) > len(namespace)+2 && table[:len(namespace)+2] == namespace+"__"
+}
+
+// 保留最后 limit 行
+func lastRows(rows [][]string, limit int) [][]string {
+	if limit > 0 && len(rows) > limit {
+		return rows[len(rows)-limit:]
+	}
+	return rows
+}
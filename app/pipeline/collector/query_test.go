@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"skynet-service/app/common/mysql/mysqltest"
+	"skynet-service/app/config"
+	"skynet-service/app/spider"
+)
+
+func TestCsvQuery(t *testing.T) {
+	defer func(old string) { config.TEXT_DIR = old }(config.TEXT_DIR)
+	config.TEXT_DIR = t.TempDir()
+	write := func(batch, table, file, content string) {
+		dir := filepath.Join(config.TEXT_DIR, batch, table)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("2020-01-01 000000", "gold", "1-1.csv", "\xEF\xBB\xBF标题,下载时间\nold,2020-01-01 00:00:00\na,2020-01-02 00:00:00\n")
+	write("2020-01-02 000000", "gold", "1-1.csv", "\xEF\xBB\xBF标题,下载时间\nb,2020-01-02 01:00:00\nc,2020-01-02 02:00:00\n")
+	write("2020-01-02 000000", "gold__detail", "1-1.csv", "\xEF\xBB\xBF价格\n1\n")
+	write("2020-01-02 000000", "golden", "1-1.csv", "\xEF\xBB\xBF标题\nx\n")
+
+	since := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	tables, err := DataQuery["csv"](&spider.Spider{Name: "gold"}, since, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 {
+		t.Fatalf("tables = %+v", tables)
+	}
+	if got := dump(tables[0]); got != "gold [标题 下载时间] [[b 2020-01-02 01:00:00] [c 2020-01-02 02:00:00]]" {
+		t.Errorf("table 0 = %s", got)
+	}
+	if got := dump(tables[1]); got != "gold__detail [价格] [[1]]" {
+		t.Errorf("table 1 = %s", got)
+	}
+
+	if tables, err = DataQuery["csv"](&spider.Spider{Name: "gold"}, time.Now().Add(time.Hour), 2); err != nil || len(tables) != 0 {
+		t.Fatalf("future since: %+v, %v", tables, err)
+	}
+}
+
+func TestMysqlQuery(t *testing.T) {
+	fake := fakeMysql(map[string][][]string{
+		// 首行为列名，其余为按 id 倒序返回的行
+		"gold":         {{"id", "标题", "DownloadTime"}, {"3", "c", "2020-01-02 02:00:00"}, {"2", "b", "2020-01-02 01:00:00"}},
+		"gold__notime": {{"标题"}, {"x"}},
+		"gold__hourly": {{"id"}, {"1"}},
+		"golden":       {{"id"}, {"1"}},
+	})
+	db := fake.Open()
+	defer db.Close()
+
+	since := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	tables, err := queryMysql(db, &spider.Spider{Name: "gold"}, since, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 {
+		t.Fatalf("tables = %+v", tables)
+	}
+	if got := dump(tables[0]); got != "gold [标题 DownloadTime] [[b 2020-01-02 01:00:00] [c 2020-01-02 02:00:00]]" {
+		t.Errorf("table 0 = %s", got)
+	}
+	if got := dump(tables[1]); got != "gold__notime [标题] [[x]]" {
+		t.Errorf("table 1 = %s", got)
+	}
+
+	want := []string{
+		"SHOW TABLES",
+		"SHOW COLUMNS FROM `gold`",
+		"SELECT * FROM `gold` WHERE DownloadTime >= ? ORDER BY id DESC LIMIT 2 [2020-01-01 12:00:00]",
+		"SHOW COLUMNS FROM `gold__notime`",
+		"SELECT * FROM `gold__notime` LIMIT 2",
+	}
+	if got := strings.Join(fake.Queries(), "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("queries:\n%s", got)
+	}
+}
+
+func dump(t *Table) string {
+	return fmt.Sprintf("%s %v %v", t.Name, t.Columns, t.Rows)
+}
+
+// 按表名返回固定数据的模拟数据库，各表首行为列名
+func fakeMysql(tables map[string][][]string) *mysqltest.DB {
+	f := &mysqltest.DB{}
+	f.Query = func(q string, args []driver.Value) (*mysqltest.Rows, error) {
+		switch {
+		case q == "SHOW TABLES":
+			var names [][]string
+			for name := range tables {
+				names = append(names, []string{name})
+			}
+			// 与 MySQL 一致，按表名排序
+			sort.Slice(names, func(i, j int) bool { return names[i][0] < names[j][0] })
+			return mysqltest.Strings([]string{"Tables_in_test"}, names...), nil
+		case strings.HasPrefix(q, "SHOW COLUMNS FROM `"):
+			var columns [][]string
+			for _, c := range tables[mysqltest.TableName(q)][0] {
+				columns = append(columns, []string{c})
+			}
+			return mysqltest.Strings([]string{"Field"}, columns...), nil
+		case strings.HasPrefix(q, "SELECT * FROM `"):
+			t := tables[mysqltest.TableName(q)]
+			return mysqltest.Strings(t[0], t[1:]...), nil
+		}
+		return nil, fmt.Errorf("unexpected query: %s", q)
+	}
+	return f
+}
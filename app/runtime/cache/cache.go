@@ -36,6 +36,8 @@ type Report struct {
 	Keyin      string
 	DataNum    uint64
 	FileNum    uint64
+	Success    uint64 // 成功页数
+	Failure    uint64 // 失败页数
 	// DataSize   uint64
 	// FileSize uint64
 	Time time.Duration
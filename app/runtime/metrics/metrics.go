@@ -0,0 +1,271 @@
+// 进程内的运行指标，以 Prometheus 文本格式导出
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 下载耗时的默认分桶，单位秒
+var DefBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type (
+	// 指标
+	Metric interface {
+		write(w *bufio.Writer)
+	}
+	// 只增计数器
+	Counter struct {
+		desc
+		series map[string]*counterSeries
+		sync.Mutex
+	}
+	counterSeries struct {
+		labels []string
+		value  float64
+	}
+	// 分桶统计
+	Histogram struct {
+		desc
+		buckets []float64
+		series  map[string]*histogramSeries
+		sync.Mutex
+	}
+	histogramSeries struct {
+		labels []string
+		counts []uint64 // 各分桶计数(非累计)，最后一个为 +Inf
+		sum    float64
+		count  uint64
+	}
+	// 采集时由回调取值的仪表
+	GaugeFunc struct {
+		desc
+		collect func() []Sample
+	}
+	// 仪表的一个取值
+	Sample struct {
+		Labels []string // 与定义时的标签名一一对应
+		Value  float64
+	}
+	desc struct {
+		name   string
+		help   string
+		labels []string
+	}
+)
+
+var registry = struct {
+	metrics []Metric
+	names   map[string]bool
+	sync.Mutex
+}{names: map[string]bool{}}
+
+// 注册指标，名称重复时 panic
+func register(name string, m Metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	registry.names[name] = true
+	registry.metrics = append(registry.metrics, m)
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name, help, labels},
+		series: map[string]*counterSeries{},
+	}
+	register(name, c)
+	return c
+}
+
+// 计数加一
+func (self *Counter) Inc(labelValues ...string) {
+	self.Add(1, labelValues...)
+}
+
+// 计数增加 v，v 不能为负数
+func (self *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := self.key(labelValues)
+	self.Lock()
+	s, ok := self.series[key]
+	if !ok {
+		s = &counterSeries{labels: labelValues}
+		self.series[key] = s
+	}
+	s.value += v
+	self.Unlock()
+}
+
+// 返回当前计数
+func (self *Counter) Value(labelValues ...string) float64 {
+	self.Lock()
+	defer self.Unlock()
+	if s, ok := self.series[self.key(labelValues)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (self *Counter) write(w *bufio.Writer) {
+	self.header(w, "counter")
+	self.Lock()
+	defer self.Unlock()
+	for _, key := range sortedKeys(self.series) {
+		s := self.series[key]
+		w.WriteString(self.name + formatLabels(self.labels, s.labels, "") + " " + formatValue(s.value) + "\n")
+	}
+}
+
+// buckets 为各分桶上界，须升序排列
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not sorted")
+	}
+	h := &Histogram{
+		desc:    desc{name, help, labels},
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+	register(name, h)
+	return h
+}
+
+// 记录一次观测值
+func (self *Histogram) Observe(v float64, labelValues ...string) {
+	key := self.key(labelValues)
+	i := sort.SearchFloat64s(self.buckets, v)
+	self.Lock()
+	s, ok := self.series[key]
+	if !ok {
+		s = &histogramSeries{labels: labelValues, counts: make([]uint64, len(self.buckets)+1)}
+		self.series[key] = s
+	}
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	self.Unlock()
+}
+
+func (self *Histogram) write(w *bufio.Writer) {
+	self.header(w, "histogram")
+	self.Lock()
+	defer self.Unlock()
+	for _, key := range sortedKeys(self.series) {
+		s := self.series[key]
+		var cumulative uint64
+		for i, le := range self.buckets {
+			cumulative += s.counts[i]
+			w.WriteString(self.name + "_bucket" + formatLabels(self.labels, s.labels, formatValue(le)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		w.WriteString(self.name + "_bucket" + formatLabels(self.labels, s.labels, "+Inf") + " " + strconv.FormatUint(s.count, 10) + "\n")
+		w.WriteString(self.name + "_sum" + formatLabels(self.labels, s.labels, "") + " " + formatValue(s.sum) + "\n")
+		w.WriteString(self.name + "_count" + formatLabels(self.labels, s.labels, "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// collect 在每次导出时调用
+func NewGaugeFunc(name, help string, labels []string, collect func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{
+		desc:    desc{name, help, labels},
+		collect: collect,
+	}
+	register(name, g)
+	return g
+}
+
+func (self *GaugeFunc) write(w *bufio.Writer) {
+	self.header(w, "gauge")
+	for _, s := range self.collect() {
+		w.WriteString(self.name + formatLabels(self.labels, s.Labels, "") + " " + formatValue(s.Value) + "\n")
+	}
+}
+
+// 按注册顺序以文本格式写出全部指标
+func WriteTo(w io.Writer) error {
+	registry.Lock()
+	list := make([]Metric, len(registry.metrics))
+	copy(list, registry.metrics)
+	registry.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range list {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (self *desc) header(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", self.name, escapeHelp(self.help), self.name, typ)
+}
+
+func (self *desc) key(labelValues []string) string {
+	if len(labelValues) != len(self.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", self.name, len(self.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch series := m.(type) {
+	case map[string]*counterSeries:
+		for k := range series {
+			keys = append(keys, k)
+		}
+	case map[string]*histogramSeries:
+		for k := range series {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 生成标签，le 不为空时追加分桶上界标签
+func formatLabels(names, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	c := NewCounter("test_requests_total", "Requests.", "spider")
+	c.Inc("b")
+	c.Add(2, "a\"")
+	c.Add(-1, "a\"")
+	h := NewHistogram("test_duration_seconds", "Duration.", []float64{0.1, 1}, "downloader")
+	h.Observe(0.05, "surf")
+	h.Observe(0.1, "surf")
+	h.Observe(3, "surf")
+	NewGaugeFunc("test_proxy_online", "Proxies.", nil, func() []Sample {
+		return []Sample{{Value: 4}}
+	})
+
+	var buf bytes.Buffer
+	if err := WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{spider="a\""} 2
+test_requests_total{spider="b"} 1
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{downloader="surf",le="0.1"} 2
+test_duration_seconds_bucket{downloader="surf",le="1"} 2
+test_duration_seconds_bucket{downloader="surf",le="+Inf"} 3
+test_duration_seconds_sum{downloader="surf"} 3.15
+test_duration_seconds_count{downloader="surf"} 3
+# HELP test_proxy_online Proxies.
+# TYPE test_proxy_online gauge
+test_proxy_online 4
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", got, want)
+	}
+	if v := c.Value("b"); v != 1 {
+		t.Errorf("Value(b) = %v, want 1", v)
+	}
+}
+
+func TestLabelMismatch(t *testing.T) {
+	c := NewCounter("test_mismatch_total", "Mismatch.", "spider")
+	defer func() {
+		if p := recover(); p == nil || !strings.Contains(p.(string), "expects 1 label") {
+			t.Errorf("recover() = %v", p)
+		}
+	}()
+	c.Inc()
+}
@@ -0,0 +1,287 @@
+// 任务运行记录，每次运行保存为 RunsDir 下的一个 JSON 文件
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+	"skynet-service/app/runtime/cache"
+)
+
+// 结束原因
+const (
+	Finished = "finished" // 全部蜘蛛运行完毕
+	Stopped  = "stopped"  // 中途被终止
+)
+
+const (
+	maxErrors = 100 // 每次运行最多保留的错误日志条数
+	errorBuf  = 256 // 错误日志订阅的缓冲区长度
+)
+
+type (
+	// 一次任务运行的记录
+	Run struct {
+		ID         string    `json:"id"`
+		Spiders    []Spider  `json:"spiders"`
+		Start      time.Time `json:"start"`
+		End        time.Time `json:"end"`
+		Items      uint64    `json:"items"`       // 文本结果总数
+		Files      uint64    `json:"files"`       // 文件结果总数
+		Success    uint64    `json:"success"`     // 成功页数
+		Failure    uint64    `json:"failure"`     // 失败页数
+		StopReason string    `json:"stop_reason"` // 结束原因
+		ErrorNum   uint64    `json:"error_num"`   // 错误日志总数
+		Errors     []string  `json:"errors"`      // 错误日志，最多保留 maxErrors 条
+	}
+	// 单个蜘蛛(按 Keyin 区分)的运行结果
+	Spider struct {
+		Name     string `json:"name"`
+		Keyin    string `json:"keyin"`
+		Finished bool   `json:"finished"` // 是否已输出报告，中途终止时可能为 false
+		Items    uint64 `json:"items"`
+		Files    uint64 `json:"files"`
+		Success  uint64 `json:"success"`
+		Failure  uint64 `json:"failure"`
+		Elapsed  int64  `json:"elapsed"` // 用时，单位毫秒
+	}
+	// 运行记录的存储
+	Store struct {
+		dir  string
+		keep int // 最多保留的记录数，超出时删除最早的记录
+		sync.Mutex
+	}
+	// 记录进行中的一次运行
+	Recorder struct {
+		run  *Run
+		sub  *logs.Subscription
+		done chan bool
+	}
+)
+
+// 全局运行记录
+var Runs = NewStore(config.RunsDir, config.RUNS_KEEP)
+
+func NewStore(dir string, keep int) *Store {
+	return &Store{dir: dir, keep: keep}
+}
+
+// 开始记录一次运行，并收集运行期间的错误日志
+func Begin(spiders []Spider) *Recorder {
+	self := &Recorder{
+		run: &Run{
+			Spiders: spiders,
+			Start:   time.Now(),
+			Errors:  []string{},
+		},
+		sub:  logs.Log.Subscribe(logs.LevelError, "", errorBuf),
+		done: make(chan bool),
+	}
+	go self.collect()
+	return self
+}
+
+func (self *Recorder) collect() {
+	defer close(self.done)
+	for {
+		level, msg, when, ok := self.sub.Next()
+		if !ok {
+			return
+		}
+		// App 级别为任务进度提示，不属于错误
+		if level == logs.LevelApp {
+			continue
+		}
+		self.run.ErrorNum++
+		if len(self.run.Errors) < maxErrors {
+			self.run.Errors = append(self.run.Errors, when.Format("2006-01-02 15:04:05")+" "+strings.TrimSpace(msg))
+		}
+	}
+}
+
+// 结束记录并保存，reports 为各蜘蛛的报告
+func (self *Recorder) Finish(reports []*cache.Report, stopped bool) (*Run, error) {
+	logs.Log.Unsubscribe(self.sub)
+	<-self.done
+
+	run := self.run
+	run.End = time.Now()
+	run.Success = cache.GetPageCount(1)
+	run.Failure = cache.GetPageCount(-1)
+	run.ErrorNum += self.sub.Dropped()
+	run.StopReason = Finished
+	if stopped {
+		run.StopReason = Stopped
+	}
+	for _, r := range reports {
+		run.Items += r.DataNum
+		run.Files += r.FileNum
+		sp := run.spider(r.SpiderName, r.Keyin)
+		sp.Finished = true
+		sp.Items = r.DataNum
+		sp.Files = r.FileNum
+		sp.Success = r.Success
+		sp.Failure = r.Failure
+		sp.Elapsed = int64(r.Time / time.Millisecond)
+	}
+	return run, Runs.Save(run)
+}
+
+// 查找蜘蛛记录，不存在时追加
+func (self *Run) spider(name, keyin string) *Spider {
+	for i := range self.Spiders {
+		if self.Spiders[i].Name == name && self.Spiders[i].Keyin == keyin && !self.Spiders[i].Finished {
+			return &self.Spiders[i]
+		}
+	}
+	self.Spiders = append(self.Spiders, Spider{Name: name, Keyin: keyin})
+	return &self.Spiders[len(self.Spiders)-1]
+}
+
+// 是否包含指定蜘蛛
+func (self *Run) HasSpider(name string) bool {
+	for _, sp := range self.Spiders {
+		if sp.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 保存记录，以开始时间生成记录id
+func (self *Store) Save(run *Run) error {
+	self.Lock()
+	defer self.Unlock()
+	if err := os.MkdirAll(self.dir, 0755); err != nil {
+		return err
+	}
+	id, err := self.nextID(run.Start.Format("20060102-150405"))
+	if err != nil {
+		return err
+	}
+	run.ID = id
+	b, err := json.MarshalIndent(run, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := self.path(run.ID) + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, self.path(run.ID)); err != nil {
+		return err
+	}
+	self.prune()
+	return nil
+}
+
+// 以 base 开头的新记录id：同一秒内的记录依次加上 -002、-003 等后缀，
+// 补零以使记录id按字符串排序即为时间顺序；后缀接续已有的最大值，
+// 不重用已被删除的记录id，须持有锁
+func (self *Store) nextID(base string) (string, error) {
+	ids, err := self.ids()
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	for _, id := range ids {
+		switch {
+		case id == base && n < 1:
+			n = 1
+		case strings.HasPrefix(id, base+"-"):
+			if i, err := strconv.Atoi(id[len(base)+1:]); err == nil && i > n {
+				n = i
+			}
+		}
+	}
+	if n == 0 {
+		return base, nil
+	}
+	return fmt.Sprintf("%s-%03d", base, n+1), nil
+}
+
+// 读取指定记录
+func (self *Store) Get(id string) (*Run, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return nil, os.ErrNotExist
+	}
+	b, err := ioutil.ReadFile(self.path(id))
+	if err != nil {
+		return nil, err
+	}
+	run := new(Run)
+	if err = json.Unmarshal(b, run); err != nil {
+		return nil, fmt.Errorf("%s: %v", self.path(id), err)
+	}
+	return run, nil
+}
+
+// 按时间倒序返回最近的记录；spider 不为空时只返回包含该蜘蛛的记录，limit 不大于0时不限数量
+func (self *Store) List(spider string, limit int) ([]*Run, error) {
+	ids, err := self.ids()
+	if err != nil {
+		return nil, err
+	}
+	list := []*Run{}
+	for i := len(ids) - 1; i >= 0; i-- {
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+		run, err := self.Get(ids[i])
+		if err != nil {
+			logs.Log.Warning(" *     读取运行记录失败：%v", err)
+			continue
+		}
+		if spider == "" || run.HasSpider(spider) {
+			list = append(list, run)
+		}
+	}
+	return list, nil
+}
+
+// 按时间顺序返回全部记录id
+func (self *Store) ids() ([]string, error) {
+	infos, err := ioutil.ReadDir(self.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, info := range infos {
+		if name := info.Name(); !info.IsDir() && strings.HasSuffix(name, ".json") {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// 删除超出保留数量的最早记录，须持有锁
+func (self *Store) prune() {
+	if self.keep <= 0 {
+		return
+	}
+	ids, err := self.ids()
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(ids)-self.keep; i++ {
+		os.Remove(self.path(ids[i]))
+	}
+}
+
+func (self *Store) path(id string) string {
+	return filepath.Join(self.dir, id+".json")
+}
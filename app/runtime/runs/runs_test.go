@@ -0,0 +1,86 @@
+package runs
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"skynet-service/app/logs"
+	"skynet-service/app/runtime/cache"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(t.TempDir(), 3)
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	// 同一秒内的多次运行，第 10 次之后的记录id须仍按时间排序
+	for i := 1; i <= 12; i++ {
+		run := &Run{Start: start, Spiders: []Spider{{Name: fmt.Sprintf("sp%d", i%2)}}, Items: uint64(i)}
+		if err := s.Save(run); err != nil {
+			t.Fatal(err)
+		}
+		want := "20200102-030405"
+		if i > 1 {
+			want += fmt.Sprintf("-%03d", i)
+		}
+		if run.ID != want {
+			t.Fatalf("run %d: ID = %s, want %s", i, run.ID, want)
+		}
+	}
+
+	list, err := s.List("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []uint64
+	for _, run := range list {
+		items = append(items, run.Items)
+	}
+	if fmt.Sprint(items) != "[12 11 10]" {
+		t.Fatalf("after prune List() items = %v", items)
+	}
+
+	if list, _ = s.List("sp1", 0); len(list) != 1 || list[0].Items != 11 {
+		t.Fatalf("List(sp1) = %+v", list)
+	}
+	if list, _ = s.List("", 2); len(list) != 2 || list[0].Items != 12 {
+		t.Fatalf("List(limit 2) = %+v", list)
+	}
+
+	if run, err := s.Get("20200102-030405-012"); err != nil || run.Items != 12 {
+		t.Fatalf("Get() = %+v, %v", run, err)
+	}
+	for _, id := range []string{"", "../x", `a\b`, ".hidden", "20200102-030405"} {
+		if _, err := s.Get(id); !os.IsNotExist(err) {
+			t.Errorf("Get(%q) err = %v", id, err)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	defer func(old *Store) { Runs = old }(Runs)
+	Runs = NewStore(t.TempDir(), 0)
+
+	rec := Begin([]Spider{{Name: "a"}, {Name: "b"}})
+	logs.Log.Error("boom")
+	run, err := rec.Finish([]*cache.Report{
+		{SpiderName: "a", DataNum: 3, FileNum: 1, Success: 4, Failure: 1, Time: 2 * time.Second},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.StopReason != Stopped || run.Items != 3 || run.Files != 1 {
+		t.Fatalf("run = %+v", run)
+	}
+	if !run.Spiders[0].Finished || run.Spiders[0].Elapsed != 2000 || run.Spiders[1].Finished {
+		t.Fatalf("spiders = %+v", run.Spiders)
+	}
+	if run.ErrorNum != 1 || len(run.Errors) != 1 {
+		t.Fatalf("errors = %d %v", run.ErrorNum, run.Errors)
+	}
+
+	saved, err := Runs.Get(run.ID)
+	if err != nil || saved.Items != 3 || !saved.HasSpider("b") || saved.HasSpider("c") {
+		t.Fatalf("Get() = %+v, %v", saved, err)
+	}
+}
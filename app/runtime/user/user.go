@@ -1,44 +1,280 @@
 package user
 
 import (
-	"skynet-service/app/logs"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+)
+
+// 用户角色
+const (
+	RoleViewer   = "viewer"   // 只读：查看状态、进度与日志
+	RoleOperator = "operator" // 操作员：另可运行、停止任务及修改参数
+)
+
+// 密码散列参数(PBKDF2-HMAC-SHA256)
+const (
+	hashIter    = 10000
+	hashKeyLen  = 32
+	hashSaltLen = 16
 )
 
 type UserSession struct {
 	sync.Mutex
-	allUsers 		map[string]*UserInfo
+	path     string
+	allUsers map[string]*UserInfo
 }
 
 type UserInfo struct {
-	loginTime   int64
-	// 保存的一些信息
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Salt      string `json:"salt"`
+	Hash      string `json:"hash"`
+	Created   int64  `json:"created"`
+	loginTime int64
 }
 
-var GAllUsers = newUser ()
+var GAllUsers = NewUser(config.USER_FILE)
 
-func init () {
+func init() {
 	logs.Log.Debug("初始化用户模块...")
+	if err := GAllUsers.Load(); err != nil {
+		logs.Log.Error(" *     读取用户文件失败：%v", err)
+	}
 }
 
-func newUser () *UserSession {
-	return &UserSession {
-		allUsers : map[string]*UserInfo{},
+// 创建保存于 path 的用户表，须调用 Load() 读取已有用户
+func NewUser(path string) *UserSession {
+	return &UserSession{
+		path:     path,
+		allUsers: map[string]*UserInfo{},
 	}
 }
 
-// 新登录的用户
-func (self *UserSession) NewUser (userName string) {
+// 检查角色名称是否合法
+func ValidRole(role string) bool {
+	return role == RoleViewer || role == RoleOperator
+}
+
+// 是否具有 role 的权限，操作员拥有只读用户的全部权限
+func (self *UserInfo) Can(role string) bool {
+	return self.Role == role || self.Role == RoleOperator
+}
+
+// 返回最近一次登录时间，未登录过时为零值
+func (self *UserInfo) LoginTime() time.Time {
+	if self.loginTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(self.loginTime, 0)
+}
+
+// 从用户文件重新读取全部用户，文件不存在时为空
+func (self *UserSession) Load() error {
+	b, err := ioutil.ReadFile(self.path)
+	if os.IsNotExist(err) {
+		b, err = []byte("[]"), nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []*UserInfo
+	if err = json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("%s: %v", self.path, err)
+	}
+
 	self.Lock()
 	defer self.Unlock()
+	users := make(map[string]*UserInfo, len(list))
+	for _, u := range list {
+		if old, ok := self.allUsers[u.Name]; ok {
+			u.loginTime = old.loginTime
+		}
+		users[u.Name] = u
+	}
+	self.allUsers = users
+	return nil
+}
+
+// 添加用户
+func (self *UserSession) AddUser(userName, password, role string) error {
+	if userName == "" {
+		return fmt.Errorf("user name can not be empty")
+	}
+	if !ValidRole(role) {
+		return fmt.Errorf("unknown role: %s", role)
+	}
+	u := &UserInfo{
+		Name:    userName,
+		Role:    role,
+		Created: time.Now().Unix(),
+	}
+	if err := u.setPassword(password); err != nil {
+		return err
+	}
+
+	self.Lock()
+	defer self.Unlock()
+	if _, ok := self.allUsers[userName]; ok {
+		return fmt.Errorf("user already exists: %s", userName)
+	}
+	self.allUsers[userName] = u
+	return self.save()
+}
+
+// 删除用户
+func (self *UserSession) DelUser(userName string) error {
+	self.Lock()
+	defer self.Unlock()
+	if _, ok := self.allUsers[userName]; !ok {
+		return fmt.Errorf("user not found: %s", userName)
+	}
+	delete(self.allUsers, userName)
+	return self.save()
+}
 
-	userInfo := UserInfo {time.Now().Unix()}
+// 修改密码
+func (self *UserSession) SetPassword(userName, password string) error {
+	self.Lock()
+	defer self.Unlock()
+	u, ok := self.allUsers[userName]
+	if !ok {
+		return fmt.Errorf("user not found: %s", userName)
+	}
+	if err := u.setPassword(password); err != nil {
+		return err
+	}
+	return self.save()
+}
 
-	self.allUsers[userName] = &userInfo
+// 修改角色
+func (self *UserSession) SetRole(userName, role string) error {
+	if !ValidRole(role) {
+		return fmt.Errorf("unknown role: %s", role)
+	}
+	self.Lock()
+	defer self.Unlock()
+	u, ok := self.allUsers[userName]
+	if !ok {
+		return fmt.Errorf("user not found: %s", userName)
+	}
+	u.Role = role
+	return self.save()
 }
 
-// 验证用户是否合法,先判断是否已登录，如果没有登录则查找此用户是否注册
-func (self *UserSession) ValidUser (userName string) bool {
+// 验证用户名与密码，成功时记录登录时间；
+// 散列计算耗时，在锁外进行，以免阻塞其他请求的认证
+func (self *UserSession) ValidUser(userName, password string) bool {
+	self.Lock()
+	u, ok := self.allUsers[userName]
+	var salt, want string
+	if ok {
+		salt, want = u.Salt, u.Hash
+	}
+	self.Unlock()
+	if !ok {
+		// 用户不存在时同样计算一次散列，避免通过耗时判断用户是否存在
+		hashPassword(password, make([]byte, hashSaltLen))
+		return false
+	}
+	b, err := hex.DecodeString(salt)
+	if err != nil {
+		return false
+	}
+	hash := hex.EncodeToString(hashPassword(password, b))
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(want)) != 1 {
+		return false
+	}
+
+	// 期间密码被修改或用户被删除时以新的为准
+	self.Lock()
+	defer self.Unlock()
+	if u, ok = self.allUsers[userName]; !ok || u.Hash != want {
+		return false
+	}
+	u.loginTime = time.Now().Unix()
 	return true
-}
\ No newline at end of file
+}
+
+// 获取用户信息副本
+func (self *UserSession) GetUser(userName string) (UserInfo, bool) {
+	self.Lock()
+	defer self.Unlock()
+	u, ok := self.allUsers[userName]
+	if !ok {
+		return UserInfo{}, false
+	}
+	return *u, true
+}
+
+// 按名称排序返回全部用户
+func (self *UserSession) List() []UserInfo {
+	self.Lock()
+	defer self.Unlock()
+	list := make([]UserInfo, 0, len(self.allUsers))
+	for _, u := range self.allUsers {
+		list = append(list, *u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// 用户数量
+func (self *UserSession) Len() int {
+	self.Lock()
+	defer self.Unlock()
+	return len(self.allUsers)
+}
+
+// 写入用户文件，先写临时文件再替换，须持有锁
+func (self *UserSession) save() error {
+	list := make([]*UserInfo, 0, len(self.allUsers))
+	for _, u := range self.allUsers {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	b, err := json.MarshalIndent(list, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(self.path), 0755); err != nil {
+		return err
+	}
+	tmp := self.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, self.path)
+}
+
+func (self *UserInfo) setPassword(password string) error {
+	if password == "" {
+		return fmt.Errorf("password can not be empty")
+	}
+	salt := make([]byte, hashSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	self.Salt = hex.EncodeToString(salt)
+	self.Hash = hex.EncodeToString(hashPassword(password, salt))
+	return nil
+}
+
+// PBKDF2-HMAC-SHA256
+func hashPassword(password string, salt []byte) []byte {
+	return pbkdf2.Key([]byte(password), salt, hashIter, hashKeyLen, sha256.New)
+}
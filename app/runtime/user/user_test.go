@@ -0,0 +1,103 @@
+package user
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	salt := make([]byte, hashSaltLen)
+	for i := range salt {
+		salt[i] = byte(i)
+	}
+	// 与改用 x/crypto/pbkdf2 之前的实现输出一致，已有的用户文件仍然可用
+	const want = "38898ec86290bc0f1630837c5b0b9c9546450f2929098fd1c5638b05339ef1f8"
+	if got := hex.EncodeToString(hashPassword("secret", salt)); got != want {
+		t.Fatalf("hashPassword() = %s, want %s", got, want)
+	}
+}
+
+func TestUserSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	s := NewUser(path)
+	if err := s.Load(); err != nil || s.Len() != 0 {
+		t.Fatalf("Load() missing file: %v, len %d", err, s.Len())
+	}
+
+	if err := s.AddUser("", "pw", RoleViewer); err == nil {
+		t.Error("empty name accepted")
+	}
+	if err := s.AddUser("a", "pw", "admin"); err == nil {
+		t.Error("unknown role accepted")
+	}
+	if err := s.AddUser("a", "", RoleViewer); err == nil {
+		t.Error("empty password accepted")
+	}
+	if err := s.AddUser("a", "pw", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddUser("a", "pw", RoleViewer); err == nil {
+		t.Error("duplicate user accepted")
+	}
+	if err := s.AddUser("b", "pw2", RoleOperator); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ValidUser("a", "wrong") || s.ValidUser("nobody", "pw") {
+		t.Error("invalid credentials accepted")
+	}
+	if !s.ValidUser("a", "pw") {
+		t.Fatal("ValidUser() = false")
+	}
+	if u, _ := s.GetUser("a"); u.LoginTime().IsZero() {
+		t.Error("login time not recorded")
+	}
+
+	// 重新读取用户文件
+	s2 := NewUser(path)
+	if err := s2.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if list := s2.List(); len(list) != 2 || list[0].Name != "a" || list[1].Name != "b" {
+		t.Fatalf("List() = %v", list)
+	}
+	if !s2.ValidUser("b", "pw2") {
+		t.Fatal("reloaded user rejected")
+	}
+
+	if err := s2.SetPassword("a", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if s2.ValidUser("a", "pw") || !s2.ValidUser("a", "new") {
+		t.Error("SetPassword() not applied")
+	}
+	if err := s2.SetRole("a", "admin"); err == nil {
+		t.Error("unknown role accepted")
+	}
+	if err := s2.SetRole("a", RoleOperator); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.DelUser("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.DelUser("b"); err == nil {
+		t.Error("deleting missing user succeeded")
+	}
+	if err := s.Load(); err != nil || s.Len() != 1 {
+		t.Fatalf("Load() = %v, len %d", err, s.Len())
+	}
+	if u, ok := s.GetUser("a"); !ok || u.Role != RoleOperator || u.LoginTime().IsZero() {
+		t.Fatalf("GetUser() = %+v, %v", u, ok)
+	}
+}
+
+func TestCan(t *testing.T) {
+	viewer, operator := &UserInfo{Role: RoleViewer}, &UserInfo{Role: RoleOperator}
+	if !viewer.Can(RoleViewer) || viewer.Can(RoleOperator) {
+		t.Error("viewer permissions")
+	}
+	if !operator.Can(RoleViewer) || !operator.Can(RoleOperator) {
+		t.Error("operator permissions")
+	}
+}
@@ -23,11 +23,27 @@ type Matrix struct {
 	history         history.Historier           // 历史记录
 	tempHistory     map[string]bool             // 临时记录 [reqUnique(url+method)]true
 	failures        map[string]*request.Request // 历史及本次失败请求
+	startTime       time.Time                   // 开始时间
+	endTime         time.Time                   // 结束时间，未结束时为零值
+	counts          [5]uint64                   // 进度计数[成功页数，失败页数，文本结果数，文件结果数，重试页数]
 	tempHistoryLock sync.RWMutex
 	failureLock     sync.Mutex
 	sync.Mutex
 }
 
+// 请求矩阵的实时进度
+type Progress struct {
+	Queue    int           // 队列中等待下载的请求数
+	ResCount int32         // 正在下载的请求数
+	Success  uint64        // 成功页数
+	Failure  uint64        // 失败页数，重试后仍失败才计入
+	Retried  uint64        // 首次失败后重新加入队列的页数，重试成功时同时计入成功页数
+	Items    uint64        // 文本结果数
+	Files    uint64        // 文件结果数
+	Elapsed  time.Duration // 已用时长
+	Finished bool          // 是否已结束
+}
+
 func newMatrix(spiderName, spiderSubName string, maxPage int64) *Matrix {
 	matrix := &Matrix{
 		spiderName:  spiderName,
@@ -37,6 +53,7 @@ func newMatrix(spiderName, spiderSubName string, maxPage int64) *Matrix {
 		history:     history.New(spiderName, spiderSubName),
 		tempHistory: make(map[string]bool),
 		failures:    make(map[string]*request.Request),
+		startTime:   time.Now(),
 	}
 	if cache.Task.Mode != status.SERVER {
 		matrix.history.ReadSuccess(cache.Task.OutType, cache.Task.SuccessInherit)
@@ -156,11 +173,13 @@ func (self *Matrix) DoHistory(req *request.Request, ok bool) bool {
 
 		if ok {
 			self.history.UpsertSuccess(req.Unique())
+			atomic.AddUint64(&self.counts[0], 1)
 			return false
 		}
 	}
 
 	if ok {
+		atomic.AddUint64(&self.counts[0], 1)
 		return false
 	}
 
@@ -169,14 +188,21 @@ func (self *Matrix) DoHistory(req *request.Request, ok bool) bool {
 	if _, ok := self.failures[req.Unique()]; !ok {
 		// 首次失败时，在任务队列末尾重新执行一次
 		self.failures[req.Unique()] = req
-		logs.Log.Informational("失败请求: [%v]", req.GetUrl())
+		logs.Log.Spider(self.spiderName).Informational("失败请求: [%v]", req.GetUrl())
+		atomic.AddUint64(&self.counts[4], 1)
 		return true
 	}
 	// 失败两次后，加入历史失败记录
 	self.history.UpsertFailure(req)
+	atomic.AddUint64(&self.counts[1], 1)
 	return false
 }
 
+// 统计不对应具体请求的失败，如 Root 中标记的错误
+func (self *Matrix) CountFailure() {
+	atomic.AddUint64(&self.counts[1], 1)
+}
+
 func (self *Matrix) CanStop() bool {
 	if sdl.checkStatus(status.STOP) {
 		return true
@@ -202,7 +228,7 @@ func (self *Matrix) CanStop() bool {
 			}
 			self.failures[reqUnique] = nil
 			goon = true
-			logs.Log.Informational("失败请求: [%v]", req.GetUrl())
+			logs.Log.Spider(self.spiderName).Informational("失败请求: [%v]", req.GetUrl())
 			self.Push(req)
 		}
 		if goon {
@@ -238,6 +264,43 @@ func (self *Matrix) Wait() {
 	}
 }
 
+// 统计解析出的结果数
+func (self *Matrix) CountResult(items, files int) {
+	atomic.AddUint64(&self.counts[2], uint64(items))
+	atomic.AddUint64(&self.counts[3], uint64(files))
+}
+
+// 标记结束，停止计时
+func (self *Matrix) Finish() {
+	self.Lock()
+	defer self.Unlock()
+	if self.endTime.IsZero() {
+		self.endTime = time.Now()
+	}
+}
+
+// 返回实时进度
+func (self *Matrix) Progress() Progress {
+	p := Progress{
+		Queue:    self.Len(),
+		ResCount: atomic.LoadInt32(&self.resCount),
+		Success:  atomic.LoadUint64(&self.counts[0]),
+		Failure:  atomic.LoadUint64(&self.counts[1]),
+		Items:    atomic.LoadUint64(&self.counts[2]),
+		Files:    atomic.LoadUint64(&self.counts[3]),
+		Retried:  atomic.LoadUint64(&self.counts[4]),
+	}
+	self.Lock()
+	if self.endTime.IsZero() {
+		p.Elapsed = time.Since(self.startTime)
+	} else {
+		p.Elapsed = self.endTime.Sub(self.startTime)
+		p.Finished = true
+	}
+	self.Unlock()
+	return p
+}
+
 func (self *Matrix) Len() int {
 	self.Lock()
 	defer self.Unlock()
@@ -269,7 +332,7 @@ func (self *Matrix) setFailures(reqs map[string]*request.Request) {
 	defer self.failureLock.Unlock()
 	for key, req := range reqs {
 		self.failures[key] = req
-		logs.Log.Informational("失败请求: [%v]", req.GetUrl())
+		logs.Log.Spider(self.spiderName).Informational("失败请求: [%v]", req.GetUrl())
 	}
 }
 
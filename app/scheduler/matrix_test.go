@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"testing"
+
+	"skynet-service/app/aid/history"
+	"skynet-service/app/downloader/request"
+)
+
+func testMatrix() *Matrix {
+	return &Matrix{
+		spiderName:  "test",
+		history:     history.New("test", ""),
+		tempHistory: make(map[string]bool),
+		failures:    make(map[string]*request.Request),
+	}
+}
+
+func TestDoHistoryCounts(t *testing.T) {
+	m := testMatrix()
+
+	// 首次失败后重试成功：只计重试与成功
+	a := &request.Request{Spider: "test", Url: "http://example.com/a", Method: "GET"}
+	if !m.DoHistory(a, false) {
+		t.Fatal("first failure should be retried")
+	}
+	m.DoHistory(a, true)
+
+	// 重试后仍失败：计重试与失败
+	b := &request.Request{Spider: "test", Url: "http://example.com/b", Method: "GET"}
+	m.DoHistory(b, false)
+	if m.DoHistory(b, false) {
+		t.Fatal("second failure should not be retried")
+	}
+
+	// 不对应请求的失败只计失败
+	m.CountFailure()
+
+	p := m.Progress()
+	if p.Success != 1 || p.Retried != 2 || p.Failure != 2 {
+		t.Fatalf("Progress() = success %d, retried %d, failure %d", p.Success, p.Retried, p.Failure)
+	}
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"sync/atomic"
+
+	"skynet-service/app/runtime/metrics"
+)
+
+// 调度器相关的实时指标，同一 Spider 的多个实例合并计算
+var (
+	_ = metrics.NewGaugeFunc("skynet_queue_requests", "Requests waiting in the scheduler queue.",
+		[]string{"spider"}, func() []metrics.Sample {
+			return collectMatrices(func(m *Matrix) float64 { return float64(m.Len()) })
+		})
+	_ = metrics.NewGaugeFunc("skynet_inflight_requests", "Requests currently being downloaded.",
+		[]string{"spider"}, func() []metrics.Sample {
+			return collectMatrices(func(m *Matrix) float64 { return float64(atomic.LoadInt32(&m.resCount)) })
+		})
+	_ = metrics.NewGaugeFunc("skynet_proxy_online", "Online proxies in the proxy pool.",
+		nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(sdl.proxy.Count())}}
+		})
+)
+
+// 按 Spider 名称汇总各请求矩阵的取值
+func collectMatrices(value func(*Matrix) float64) []metrics.Sample {
+	sdl.RLock()
+	matrices := make([]*Matrix, len(sdl.matrices))
+	copy(matrices, sdl.matrices)
+	sdl.RUnlock()
+
+	var samples []metrics.Sample
+	index := map[string]int{}
+	for _, m := range matrices {
+		i, ok := index[m.spiderName]
+		if !ok {
+			i = len(samples)
+			index[m.spiderName] = i
+			samples = append(samples, metrics.Sample{Labels: []string{m.spiderName}})
+		}
+		samples[i].Value += value(m)
+	}
+	return samples
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/tidwall/gjson"
 	"golang.org/x/net/html/charset"
 
 	"skynet-service/app/common/goquery"
@@ -27,6 +28,8 @@ type Context struct {
 	Response *http.Response    // 响应流，其中URL拷贝自*request.Request
 	text     []byte            // 下载内容Body的字节流格式
 	dom      *goquery.Document // 下载内容Body为html时，可转换为Dom的对象
+	json     *gjson.Result     // 下载内容Body为json时，供 GetJson() 查询的解析结果
+	xdoc     interface{}       // 供 XPath() 查询的 *html.Node 或 *xmlquery.Node
 	items    []data.DataCell   // 存放以文本形式输出的结果数据
 	files    []data.FileCell   // 存放欲直接输出的文件("Name": string; "Body": io.ReadCloser)
 	err      error             // 错误标记
@@ -64,6 +67,8 @@ func PutContext(ctx *Context) {
 	ctx.Request = nil
 	ctx.text = nil
 	ctx.dom = nil
+	ctx.json = nil
+	ctx.xdoc = nil
 	ctx.err = nil
 	contextPool.Put(ctx)
 }
@@ -73,7 +78,7 @@ func (self *Context) SetResponse(resp *http.Response) *Context {
 	return self
 }
 
-// 标记下载错误。
+// 标记下载错误；在 ParseFunc 中调用时该请求同样计为失败。
 func (self *Context) SetError(err error) {
 	self.err = err
 }
@@ -103,7 +108,7 @@ func (self *Context) AddQueue(req *request.Request) *Context {
 		Prepare()
 
 	if err != nil {
-		logs.Log.Error(err.Error())
+		logs.Log.Spider(self.spider.GetName()).Error(err.Error())
 		return self
 	}
 
@@ -172,7 +177,7 @@ func (self *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 		Prepare()
 
 	if err != nil {
-		logs.Log.Error(err.Error())
+		logs.Log.Spider(self.spider.GetName()).Error(err.Error())
 		return self
 	}
 
@@ -187,11 +192,13 @@ func (self *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 // 输出文本结果。
 // item类型为map[int]interface{}时，根据ruleName现有的ItemFields字段进行输出，
 // item类型为map[string]interface{}时，ruleName不存在的ItemFields字段将被自动添加，
-// ruleName为空时默认当前规则。
+// ruleName为空时默认当前规则；
+// 规则设置了Schema时，结果按字段声明转换类型，不符合声明或缺少UniqueKey字段时仅丢弃该条结果，
+// 计入 skynet_invalid_items_total，同一页面的其他结果照常输出。
 func (self *Context) Output(item interface{}, ruleName ...string) {
 	_ruleName, rule, found := self.getRule(ruleName...)
 	if !found {
-		logs.Log.Error("spider: %s Output() error! rule name not exists！", self.spider.GetName())
+		logs.Log.Spider(self.spider.GetName()).Error("spider: %s Output() error! rule name not exists！", self.spider.GetName())
 		return
 	}
 	var _item map[string]interface{}
@@ -209,6 +216,20 @@ func (self *Context) Output(item interface{}, ruleName ...string) {
 		}
 		_item = item2
 	}
+	if len(rule.Schema) > 0 || len(rule.UniqueKey) > 0 {
+		// 按字段声明转换类型，不符合声明或缺少唯一键的结果被丢弃；
+		// 不标记请求失败，否则已收集的结果随之丢弃，且重试必然同样失败
+		checked := make(map[string]interface{}, len(_item))
+		for k, v := range _item {
+			checked[k] = v
+		}
+		if err := rule.Validate(checked); err != nil {
+			logs.Log.Spider(self.spider.GetName()).Error("spider: %s Output() error! rule %s: %v", self.spider.GetName(), _ruleName, err)
+			invalidItemsTotal.Inc(self.spider.GetName(), _ruleName)
+			return
+		}
+		_item = checked
+	}
 	self.Lock()
 	if self.spider.NotDefaultField {
 		self.items = append(self.items, data.GetDataCell(_ruleName, _item, "", "", ""))
@@ -263,7 +284,7 @@ func (self *Context) FileOutput(nameOrExt ...string) {
 func (self *Context) CreatItem(item map[int]interface{}, ruleName ...string) map[string]interface{} {
 	_, rule, found := self.getRule(ruleName...)
 	if !found {
-		logs.Log.Error("蜘蛛 %s 调用CreatItem()时，指定的规则名不存在！", self.spider.GetName())
+		logs.Log.Spider(self.spider.GetName()).Error("蜘蛛 %s 调用CreatItem()时，指定的规则名不存在！", self.spider.GetName())
 		return nil
 	}
 
@@ -297,7 +318,7 @@ func (self *Context) SetReferer(referer string) *Context {
 func (self *Context) UpsertItemField(field string, ruleName ...string) (index int) {
 	_, rule, found := self.getRule(ruleName...)
 	if !found {
-		logs.Log.Error("蜘蛛 %s 调用UpsertItemField()时，指定的规则名不存在！", self.spider.GetName())
+		logs.Log.Spider(self.spider.GetName()).Error("蜘蛛 %s 调用UpsertItemField()时，指定的规则名不存在！", self.spider.GetName())
 		return
 	}
 	return self.spider.UpsertItemField(rule, field)
@@ -312,14 +333,14 @@ func (self *Context) Aid(aid map[string]interface{}, ruleName ...string) interfa
 	_, rule, found := self.getRule(ruleName...)
 	if !found {
 		if len(ruleName) > 0 {
-			logs.Log.Error("调用蜘蛛 %s 不存在的规则: %s", self.spider.GetName(), ruleName[0])
+			logs.Log.Spider(self.spider.GetName()).Error("调用蜘蛛 %s 不存在的规则: %s", self.spider.GetName(), ruleName[0])
 		} else {
-			logs.Log.Error("调用蜘蛛 %s 的Aid()时未指定的规则名", self.spider.GetName())
+			logs.Log.Spider(self.spider.GetName()).Error("调用蜘蛛 %s 的Aid()时未指定的规则名", self.spider.GetName())
 		}
 		return nil
 	}
 	if rule.AidFunc == nil {
-		logs.Log.Error("蜘蛛 %s 的规则 %s 未定义AidFunc", self.spider.GetName(), ruleName[0])
+		logs.Log.Spider(self.spider.GetName()).Error("蜘蛛 %s 的规则 %s 未定义AidFunc", self.spider.GetName(), ruleName[0])
 		return nil
 	}
 	return rule.AidFunc(self, aid)
@@ -340,7 +361,7 @@ func (self *Context) Parse(ruleName ...string) *Context {
 		return self
 	}
 	if rule.ParseFunc == nil {
-		logs.Log.Error("蜘蛛 %s 的规则 %s 未定义ParseFunc", self.spider.GetName(), ruleName[0])
+		logs.Log.Spider(self.spider.GetName()).Error("蜘蛛 %s 的规则 %s 未定义ParseFunc", self.spider.GetName(), ruleName[0])
 		return self
 	}
 	rule.ParseFunc(self)
@@ -431,7 +452,7 @@ func (self *Context) CopyRequest() *request.Request {
 func (self *Context) GetItemFields(ruleName ...string) []string {
 	_, rule, found := self.getRule(ruleName...)
 	if !found {
-		logs.Log.Error("蜘蛛 %s 调用GetItemFields()时，指定的规则名不存在！", self.spider.GetName())
+		logs.Log.Spider(self.spider.GetName()).Error("蜘蛛 %s 调用GetItemFields()时，指定的规则名不存在！", self.spider.GetName())
 		return nil
 	}
 	return self.spider.GetItemFields(rule)
@@ -442,7 +463,7 @@ func (self *Context) GetItemFields(ruleName ...string) []string {
 func (self *Context) GetItemField(index int, ruleName ...string) (field string) {
 	_, rule, found := self.getRule(ruleName...)
 	if !found {
-		logs.Log.Error("蜘蛛 %s 调用GetItemField()时，指定的规则名不存在！", self.spider.GetName())
+		logs.Log.Spider(self.spider.GetName()).Error("蜘蛛 %s 调用GetItemField()时，指定的规则名不存在！", self.spider.GetName())
 		return
 	}
 	return self.spider.GetItemField(rule, index)
@@ -453,7 +474,7 @@ func (self *Context) GetItemField(index int, ruleName ...string) (field string)
 func (self *Context) GetItemFieldIndex(field string, ruleName ...string) (index int) {
 	_, rule, found := self.getRule(ruleName...)
 	if !found {
-		logs.Log.Error("蜘蛛 %s 调用GetItemField()时，指定的规则名不存在！", self.spider.GetName())
+		logs.Log.Spider(self.spider.GetName()).Error("蜘蛛 %s 调用GetItemField()时，指定的规则名不存在！", self.spider.GetName())
 		return
 	}
 	return self.spider.GetItemFieldIndex(rule, field)
@@ -647,10 +668,10 @@ func (self *Context) initText() {
 					self.Response.Body.Close()
 					return
 				} else {
-					logs.Log.Warning(" *     [convert][%v]: %v (ignore transcoding)", self.GetUrl(), err)
+					logs.Log.Spider(self.spider.GetName()).Warning(" *     [convert][%v]: %v (ignore transcoding)", self.GetUrl(), err)
 				}
 			} else {
-				logs.Log.Warning(" *     [convert][%v]: %v (ignore transcoding)", self.GetUrl(), err)
+				logs.Log.Spider(self.spider.GetName()).Warning(" *     [convert][%v]: %v (ignore transcoding)", self.GetUrl(), err)
 			}
 		}
 	}
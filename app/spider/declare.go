@@ -0,0 +1,530 @@
+package spider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v2"
+
+	"skynet-service/app/common/goquery"
+	"skynet-service/app/downloader/request"
+)
+
+// 声明式规则的响应类型
+const (
+	DeclaredHTML = "html" // 以 CSS 选择器或 XPath 提取
+	DeclaredJSON = "json" // 以 JSONPath(gjson 语法)提取
+)
+
+// 声明式蜘蛛模型：入口请求、规则、字段提取、翻页与跟进链接均以数据描述，无需脚本
+type (
+	DeclaredSpider struct {
+		Name            string                   `json:"name" yaml:"name"`
+		Description     string                   `json:"description" yaml:"description"`
+		Pausetime       int64                    `json:"pausetime" yaml:"pausetime"`
+		EnableKeyin     bool                     `json:"enableKeyin" yaml:"enableKeyin"`
+		EnableCookie    bool                     `json:"enableCookie" yaml:"enableCookie"`
+		NotDefaultField bool                     `json:"notDefaultField" yaml:"notDefaultField"`
+		Root            []*DeclaredRequest       `json:"root" yaml:"root"`   // 入口请求
+		Rules           map[string]*DeclaredRule `json:"rules" yaml:"rules"` // 规则名 → 规则
+	}
+	// 请求，Url 与 PostData 中的 {keyin} 替换为自定义输入，输入以逗号分隔多个值时每个值生成一个请求
+	DeclaredRequest struct {
+		Url        string            `json:"url" yaml:"url"`
+		Rule       string            `json:"rule" yaml:"rule"`
+		Method     string            `json:"method" yaml:"method"`
+		Header     map[string]string `json:"header" yaml:"header"`
+		PostData   string            `json:"postData" yaml:"postData"`
+		Temp       map[string]string `json:"temp" yaml:"temp"`
+		Priority   int               `json:"priority" yaml:"priority"`
+		Reloadable bool              `json:"reloadable" yaml:"reloadable"`
+	}
+	DeclaredRule struct {
+		Type      string           `json:"type" yaml:"type"`           // html(默认)或 json
+		Items     *Selector        `json:"items" yaml:"items"`         // 每条结果所在的节点，为空时整页为一条结果
+		Fields    []*DeclaredField `json:"fields" yaml:"fields"`       // 结果字段，按顺序输出
+		ItemKey   string           `json:"itemKey" yaml:"itemKey"`     // 同 Rule.ItemKey
+		UniqueKey []string         `json:"uniqueKey" yaml:"uniqueKey"` // 同 Rule.UniqueKey
+		Follow    []*DeclaredLink  `json:"follow" yaml:"follow"`       // 跟进的链接
+		Paginate  *DeclaredLink    `json:"paginate" yaml:"paginate"`   // 下一页链接
+	}
+	// 在当前节点中选取节点或文本，CSS、XPath、JSONPath 至多设置一个，均未设置时为当前节点本身
+	Selector struct {
+		CSS      string `json:"css" yaml:"css"`
+		XPath    string `json:"xpath" yaml:"xpath"`
+		JSONPath string `json:"jsonpath" yaml:"jsonpath"`
+		Attr     string `json:"attr" yaml:"attr"`   // html 节点的属性，为空时取文本，为 html 时取内部 HTML
+		Regex    string `json:"regex" yaml:"regex"` // 对取得的文本做正则匹配，有分组时取第一个分组；未设置选择器与属性时作用于节点的 HTML
+
+		regex *regexp.Regexp
+	}
+	DeclaredField struct {
+		Name     string `json:"name" yaml:"name"`
+		Selector `yaml:",inline"`
+		Value    string `json:"value" yaml:"value"`       // 固定值，{url}、{keyin} 替换为当前地址与自定义输入，设置后不使用选择器
+		Multiple bool   `json:"multiple" yaml:"multiple"` // 取全部匹配项组成列表，否则取第一项
+		Type     string `json:"type" yaml:"type"`         // 同 Field.Type
+		Required bool   `json:"required" yaml:"required"` // 同 Field.Required
+	}
+	// 链接：html 规则未设置选择器时为全部 <a href>，未设置属性时取 href
+	DeclaredLink struct {
+		Selector `yaml:",inline"`
+		Pattern  string `json:"pattern" yaml:"pattern"`   // 只跟进匹配该正则的链接
+		Rule     string `json:"rule" yaml:"rule"`         // 解析链接的规则，为空时为当前规则
+		MaxPages int    `json:"maxPages" yaml:"maxPages"` // 翻页时的最大页数，为 0 时不限
+		Priority int    `json:"priority" yaml:"priority"`
+
+		pattern *regexp.Regexp
+	}
+)
+
+// 读取并检查声明式规则文件，返回尚未注册的蜘蛛
+func LoadDeclared(filename string) (*Spider, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseDeclared(b, strings.HasSuffix(filename, ".yaml") || strings.HasSuffix(filename, ".yml"))
+	if err != nil {
+		return nil, err
+	}
+	return m.Spider()
+}
+
+// 解析声明式规则，isYaml 为 false 时按 JSON 解析；
+// 两种格式均拒绝未知字段，以免拼错的字段名被静默忽略
+func ParseDeclared(b []byte, isYaml bool) (*DeclaredSpider, error) {
+	m := &DeclaredSpider{}
+	var err error
+	if isYaml {
+		err = yaml.UnmarshalStrict(b, m)
+	} else {
+		err = unmarshalStrict(b, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func unmarshalStrict(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after json object")
+	}
+	return nil
+}
+
+// 检查规则并生成蜘蛛
+func (self *DeclaredSpider) Spider() (*Spider, error) {
+	if err := self.compile(); err != nil {
+		return nil, err
+	}
+	sp := &Spider{
+		Name:            self.Name,
+		Description:     self.Description,
+		Pausetime:       self.Pausetime,
+		EnableCookie:    self.EnableCookie,
+		NotDefaultField: self.NotDefaultField,
+		RuleTree:        &RuleTree{Trunk: map[string]*Rule{}},
+	}
+	if self.EnableKeyin {
+		sp.Keyin = KEYIN
+	}
+	sp.RuleTree.Root = func(ctx *Context) {
+		for _, req := range self.Root {
+			req.addQueue(ctx)
+		}
+	}
+	for name, r := range self.Rules {
+		rule := &Rule{ItemKey: r.ItemKey, UniqueKey: r.UniqueKey}
+		for _, f := range r.Fields {
+			rule.ItemFields = append(rule.ItemFields, f.Name)
+			if f.Type != "" || f.Required {
+				rule.Schema = append(rule.Schema, Field{Name: f.Name, Type: f.Type, Required: f.Required})
+			}
+		}
+		rule.ParseFunc = func(name string, r *DeclaredRule) func(*Context) {
+			return func(ctx *Context) { r.parse(ctx, name) }
+		}(name, r)
+		sp.RuleTree.Trunk[name] = rule
+	}
+	return sp, nil
+}
+
+// 检查各项设置并编译其中的正则
+func (self *DeclaredSpider) compile() error {
+	if self.Name == "" {
+		return fmt.Errorf("name can not be empty")
+	}
+	if len(self.Root) == 0 {
+		return fmt.Errorf("root requests can not be empty")
+	}
+	for i, req := range self.Root {
+		if req.Url == "" {
+			return fmt.Errorf("root[%d]: url can not be empty", i)
+		}
+		if _, ok := self.Rules[req.Rule]; !ok {
+			return fmt.Errorf("root[%d]: rule %q not exists", i, req.Rule)
+		}
+	}
+	for name, r := range self.Rules {
+		if r == nil {
+			return fmt.Errorf("rule %s can not be empty", name)
+		}
+		if err := r.compile(self.Rules); err != nil {
+			return fmt.Errorf("rule %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (self *DeclaredRule) compile(rules map[string]*DeclaredRule) error {
+	switch self.Type {
+	case "":
+		self.Type = DeclaredHTML
+	case DeclaredHTML, DeclaredJSON:
+	default:
+		return fmt.Errorf("unknown type %q", self.Type)
+	}
+	if self.Items != nil {
+		if err := self.Items.compile(self.Type); err != nil {
+			return fmt.Errorf("items: %v", err)
+		}
+	}
+	names := map[string]bool{}
+	for i, f := range self.Fields {
+		if f.Name == "" {
+			return fmt.Errorf("fields[%d]: name can not be empty", i)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("field %s: duplicate name", f.Name)
+		}
+		names[f.Name] = true
+		if !ValidType(f.Type) {
+			return fmt.Errorf("field %s: unknown type %q", f.Name, f.Type)
+		}
+		if f.Value != "" {
+			if f.CSS != "" || f.XPath != "" || f.JSONPath != "" || f.Regex != "" {
+				return fmt.Errorf("field %s: value can not be used with a selector", f.Name)
+			}
+			continue
+		}
+		if err := f.Selector.compile(self.Type); err != nil {
+			return fmt.Errorf("field %s: %v", f.Name, err)
+		}
+	}
+	if self.ItemKey != "" && !names[self.ItemKey] {
+		return fmt.Errorf("itemKey %s is not a field", self.ItemKey)
+	}
+	for _, k := range self.UniqueKey {
+		if !names[k] {
+			return fmt.Errorf("uniqueKey %s is not a field", k)
+		}
+	}
+	for i, link := range self.Follow {
+		if err := link.compile(self.Type, rules); err != nil {
+			return fmt.Errorf("follow[%d]: %v", i, err)
+		}
+	}
+	if self.Paginate != nil {
+		if err := self.Paginate.compile(self.Type, rules); err != nil {
+			return fmt.Errorf("paginate: %v", err)
+		}
+	}
+	return nil
+}
+
+func (self *Selector) compile(typ string) (err error) {
+	n := 0
+	for _, s := range []string{self.CSS, self.XPath, self.JSONPath} {
+		if s != "" {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("only one of css, xpath and jsonpath can be set")
+	}
+	if typ == DeclaredJSON && (self.CSS != "" || self.XPath != "" || self.Attr != "") {
+		return fmt.Errorf("json rules only support jsonpath and regex")
+	}
+	if typ == DeclaredHTML && self.JSONPath != "" {
+		return fmt.Errorf("html rules do not support jsonpath")
+	}
+	if self.CSS != "" {
+		if _, err = cascadia.Compile(self.CSS); err != nil {
+			return fmt.Errorf("css %q: %v", self.CSS, err)
+		}
+	}
+	if self.XPath != "" {
+		if _, err = xpath.Compile(self.XPath); err != nil {
+			return fmt.Errorf("xpath %q: %v", self.XPath, err)
+		}
+	}
+	if self.Regex != "" {
+		if self.regex, err = regexp.Compile(self.Regex); err != nil {
+			return fmt.Errorf("regex: %v", err)
+		}
+	}
+	return nil
+}
+
+func (self *DeclaredLink) compile(typ string, rules map[string]*DeclaredRule) (err error) {
+	if self.Rule != "" {
+		if _, ok := rules[self.Rule]; !ok {
+			return fmt.Errorf("rule %q not exists", self.Rule)
+		}
+	}
+	if typ == DeclaredHTML && self.CSS == "" && self.XPath == "" {
+		self.CSS = "a[href]"
+	}
+	if typ == DeclaredHTML && self.Attr == "" {
+		self.Attr = "href"
+	}
+	if self.Pattern != "" {
+		if self.pattern, err = regexp.Compile(self.Pattern); err != nil {
+			return fmt.Errorf("pattern: %v", err)
+		}
+	}
+	return self.Selector.compile(typ)
+}
+
+//**************************************** 执行 *******************************************\\
+
+func (self *DeclaredRequest) addQueue(ctx *Context) {
+	keyins := []string{""}
+	if keyin := ctx.GetKeyin(); keyin != KEYIN && (strings.Contains(self.Url, "{keyin}") || strings.Contains(self.PostData, "{keyin}")) {
+		keyins = keyins[:0]
+		for _, v := range strings.Split(keyin, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				keyins = append(keyins, v)
+			}
+		}
+	}
+	for _, keyin := range keyins {
+		req := &request.Request{
+			Url:        strings.Replace(self.Url, "{keyin}", url.QueryEscape(keyin), -1),
+			Rule:       self.Rule,
+			Method:     self.Method,
+			PostData:   strings.Replace(self.PostData, "{keyin}", url.QueryEscape(keyin), -1),
+			Priority:   self.Priority,
+			Reloadable: self.Reloadable,
+			Header:     http.Header{},
+			Temp:       request.Temp{},
+		}
+		for k, v := range self.Header {
+			req.Header.Set(k, v)
+		}
+		for k, v := range self.Temp {
+			req.SetTemp(k, v)
+		}
+		ctx.AddQueue(req)
+	}
+}
+
+func (self *DeclaredRule) parse(ctx *Context, name string) {
+	var root interface{}
+	switch self.Type {
+	case DeclaredJSON:
+		text := ctx.GetText()
+		if !gjson.Valid(text) {
+			ctx.SetError(fmt.Errorf("invalid json"))
+			return
+		}
+		root = gjson.Parse(text)
+	default:
+		doc, err := htmlquery.Parse(strings.NewReader(ctx.GetText()))
+		if err != nil {
+			ctx.SetError(err)
+			return
+		}
+		root = doc
+	}
+
+	if len(self.Fields) > 0 {
+		items := []interface{}{root}
+		if self.Items != nil {
+			items = self.Items.nodes(root)
+		}
+		for _, node := range items {
+			item := make(map[string]interface{}, len(self.Fields))
+			for _, f := range self.Fields {
+				item[f.Name] = f.extract(ctx, node)
+			}
+			ctx.Output(item)
+			if ctx.GetError() != nil {
+				return
+			}
+		}
+	}
+
+	for _, link := range self.Follow {
+		rule := link.Rule
+		if rule == "" {
+			rule = name
+		}
+		for _, u := range link.links(ctx, root) {
+			ctx.AddQueue(&request.Request{Url: u, Rule: rule, Priority: link.Priority})
+		}
+	}
+
+	if p := self.Paginate; p != nil {
+		page := ctx.GetTemp("page", 1).(int)
+		if p.MaxPages > 0 && page >= p.MaxPages {
+			return
+		}
+		rule := p.Rule
+		if rule == "" {
+			rule = name
+		}
+		if links := p.links(ctx, root); len(links) > 0 {
+			ctx.AddQueue(&request.Request{
+				Url:      links[0],
+				Rule:     rule,
+				Priority: p.Priority,
+				Temp:     request.Temp{"page": page + 1},
+			})
+		}
+	}
+}
+
+func (self *DeclaredField) extract(ctx *Context, node interface{}) interface{} {
+	if self.Value != "" {
+		keyin := ctx.GetKeyin()
+		if keyin == KEYIN {
+			keyin = ""
+		}
+		return strings.NewReplacer("{url}", ctx.GetUrl(), "{keyin}", keyin).Replace(self.Value)
+	}
+	if self.Multiple {
+		values := []string{}
+		for _, n := range self.nodes(node) {
+			if v, ok := self.text(n); ok {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+	// json 规则中取单个值时，数组与对象整体作为值
+	if r, ok := node.(gjson.Result); ok && self.JSONPath != "" {
+		node = r.Get(self.JSONPath)
+		if !node.(gjson.Result).Exists() {
+			return nil
+		}
+		v, _ := (&Selector{Regex: self.Regex, regex: self.regex}).text(node)
+		return v
+	}
+	for _, n := range self.nodes(node) {
+		if v, ok := self.text(n); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// 链接按当前地址补全为绝对地址，并按 Pattern 过滤
+func (self *DeclaredLink) links(ctx *Context, root interface{}) []string {
+	base, _ := url.Parse(ctx.GetUrl())
+	var links []string
+	for _, n := range self.nodes(root) {
+		v, ok := self.text(n)
+		if !ok || v == "" || strings.HasPrefix(v, "#") || strings.HasPrefix(v, "javascript:") {
+			continue
+		}
+		if base != nil {
+			if u, err := base.Parse(v); err == nil {
+				v = u.String()
+			}
+		}
+		if self.pattern != nil && !self.pattern.MatchString(v) {
+			continue
+		}
+		links = append(links, v)
+	}
+	return links
+}
+
+// 选取的全部节点，json 数组展开为各元素
+func (self *Selector) nodes(node interface{}) []interface{} {
+	var list []interface{}
+	switch n := node.(type) {
+	case *html.Node:
+		switch {
+		case self.CSS != "":
+			goquery.NewDocumentFromNode(n).Find(self.CSS).Each(func(_ int, s *goquery.Selection) {
+				for _, x := range s.Nodes {
+					list = append(list, x)
+				}
+			})
+		case self.XPath != "":
+			for _, x := range htmlquery.Find(n, self.XPath) {
+				list = append(list, x)
+			}
+		default:
+			list = append(list, n)
+		}
+	case gjson.Result:
+		r := n
+		if self.JSONPath != "" {
+			r = n.Get(self.JSONPath)
+		}
+		if r.IsArray() {
+			for _, x := range r.Array() {
+				list = append(list, x)
+			}
+		} else if r.Exists() {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+// 节点的文本，设置了正则但未匹配时返回 false
+func (self *Selector) text(node interface{}) (string, bool) {
+	var s string
+	switch n := node.(type) {
+	case *html.Node:
+		switch {
+		case self.Attr == "html":
+			s = htmlquery.OutputHTML(n, false)
+		case self.Attr != "":
+			if n.Type == html.TextNode {
+				s = n.Data
+			} else {
+				s = htmlquery.SelectAttr(n, self.Attr)
+			}
+		case self.regex != nil && self.CSS == "" && self.XPath == "":
+			s = htmlquery.OutputHTML(n, true)
+		default:
+			s = strings.TrimSpace(htmlquery.InnerText(n))
+		}
+	case gjson.Result:
+		s = n.String()
+	}
+	if self.regex == nil {
+		return s, true
+	}
+	m := self.regex.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	if len(m) > 1 {
+		return m[1], true
+	}
+	return m[0], true
+}
@@ -0,0 +1,125 @@
+package spider_test
+
+import (
+	"strings"
+	"testing"
+
+	"skynet-service/app/spider"
+	"skynet-service/app/spider/spidertest"
+)
+
+const declaredYaml = `
+name: declaretest
+enableKeyin: true
+root:
+  - url: http://example.com/list?q={keyin}
+    rule: list
+  - url: http://example.com/api
+    rule: api
+rules:
+  list:
+    follow:
+      - css: .item a
+        rule: item
+    paginate:
+      css: a.next
+      maxPages: 2
+  item:
+    uniqueKey: [Id]
+    fields:
+      - name: Id
+        xpath: //div[@id='id']
+        type: int
+      - name: Title
+        css: h1
+        required: true
+      - name: Price
+        css: .price
+        regex: '([\d.]+)'
+        type: float
+      - name: Tags
+        css: .tag
+        multiple: true
+      - name: Source
+        value: '{url}'
+  api:
+    type: json
+    items:
+      jsonpath: data.list
+    fields:
+      - name: Name
+        jsonpath: name
+      - name: Attrs
+        jsonpath: attrs
+        type: json
+`
+
+func TestDeclared(t *testing.T) {
+	m, err := spider.ParseDeclared([]byte(declaredYaml), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp, err := m.Spider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp = sp.Register()
+
+	replayer := spidertest.NewReplayer(
+		&spidertest.Fixture{Url: "http://example.com/list?q=a", Response: `<div class="item"><a href="/1">1</a></div><a class="next" href="/list?q=a&p=2">next</a>`},
+		&spidertest.Fixture{Url: "http://example.com/list?q=a&p=2", Response: `<div class="item"><a href="2">2</a></div><a class="next" href="/list?q=a&p=3">next</a>`},
+		&spidertest.Fixture{Url: "http://example.com/1", Response: `<div id="id">1</div><h1> One </h1><span class="price">$9.5</span><i class="tag">a</i><i class="tag">b</i>`},
+		&spidertest.Fixture{Url: "http://example.com/2", Response: `<div id="id">2</div><h1>Two</h1>`},
+		&spidertest.Fixture{Url: "http://example.com/api", Response: `{"data":{"list":[{"name":"x","attrs":{"k":1}},{"name":"y"}]}}`},
+	)
+	result := (&spidertest.Runner{Spider: sp, Downloader: replayer, Keyin: "a"}).Run()
+	if err := result.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// 第 3 页超出 maxPages
+	if n := len(result.RequestsOf("list")); n != 2 {
+		t.Fatalf("list requests = %d", n)
+	}
+
+	items := result.ItemsOf("item")
+	if len(items) != 2 {
+		t.Fatalf("items = %v", items)
+	}
+	one := items[0]
+	if one["Id"] != int64(1) || one["Title"] != "One" || one["Price"] != 9.5 || one["Source"] != "http://example.com/1" {
+		t.Errorf("item = %v", one)
+	}
+	if tags, _ := one["Tags"].([]string); len(tags) != 2 || tags[1] != "b" {
+		t.Errorf("Tags = %v", one["Tags"])
+	}
+	if items[1]["Price"] != nil {
+		t.Errorf("Price = %v", items[1]["Price"])
+	}
+
+	apis := result.ItemsOf("api")
+	if len(apis) != 2 || apis[0]["Name"] != "x" || apis[1]["Name"] != "y" {
+		t.Fatalf("api items = %v", apis)
+	}
+}
+
+func TestDeclaredInvalid(t *testing.T) {
+	for _, c := range []struct{ def, err string }{
+		{`{"root":[{"url":"http://a","rule":"a"}],"rules":{"a":{}}}`, "name"},
+		{`{"name":"x","root":[{"url":"http://a","rule":"b"}],"rules":{"a":{}}}`, "not exists"},
+		{`{"name":"x","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{"type":"xml"}}}`, "unknown type"},
+		{`{"name":"x","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{"fields":[{"name":"f","css":"a[","type":"int"}]}}}`, "css"},
+		{`{"name":"x","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{"type":"json","fields":[{"name":"f","xpath":"//a"}]}}}`, "jsonpath"},
+		{`{"name":"x","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{"follow":[{"rule":"b"}]}}}`, "not exists"},
+		{`{"name":"x","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{"uniqueKey":["f"]}}}`, "uniqueKey"},
+		{`{"name":"x","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{"feilds":[]}}}`, "unknown field"},
+		{`{"name":"x","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{}}} {}`, "unexpected data"},
+	} {
+		m, err := spider.ParseDeclared([]byte(c.def), false)
+		if err == nil {
+			_, err = m.Spider()
+		}
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: err = %v, want %q", c.def, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package spider
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+)
+
+// 动态规则目录的加载记录，检查到文件变化时替换或移除其注册的蜘蛛；
+// 只影响此后开始的任务，已在运行的任务使用的是蜘蛛副本
+type Watcher struct {
+	files map[string]*dynamicFile // 文件名 → 加载记录
+	stop  chan struct{}
+	done  chan struct{}
+	sync.Mutex
+}
+
+type dynamicFile struct {
+	modTime time.Time
+	size    int64
+	name    string // 已注册的蜘蛛名，从未加载成功时为空
+}
+
+// 全局动态规则加载记录
+var Dynamic = &Watcher{files: map[string]*dynamicFile{}}
+
+func init() {
+	Dynamic.Scan()
+}
+
+// 动态规则目录中的全部规则文件
+func DynamicFiles(dir string) []string {
+	var files []string
+	for _, ext := range []string{config.SpiderExt, config.SpiderJsonExt, config.SpiderYamlExt, ".spider.yml"} {
+		list, _ := filepath.Glob(path.Join(dir, "*"+ext))
+		files = append(files, list...)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// 按扩展名读取并检查动态规则文件，返回尚未注册的蜘蛛
+func LoadDynamic(filename string) (*Spider, error) {
+	if strings.HasSuffix(filename, config.SpiderExt) {
+		return LoadSpiderModle(filename)
+	}
+	return LoadDeclared(filename)
+}
+
+// 按间隔检查动态规则目录，interval 为 0 时不检查
+func (self *Watcher) Start(interval time.Duration) {
+	self.Lock()
+	defer self.Unlock()
+	if self.stop != nil || interval <= 0 {
+		return
+	}
+	self.stop = make(chan struct{})
+	self.done = make(chan struct{})
+	go self.loop(interval, self.stop, self.done)
+}
+
+// 停止检查，等待正在进行的检查完成
+func (self *Watcher) Stop() {
+	self.Lock()
+	if self.stop == nil {
+		self.Unlock()
+		return
+	}
+	close(self.stop)
+	done := self.done
+	self.stop = nil
+	self.Unlock()
+	<-done
+}
+
+// 是否正在按间隔检查
+func (self *Watcher) Running() bool {
+	self.Lock()
+	defer self.Unlock()
+	return self.stop != nil
+}
+
+func (self *Watcher) loop(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			self.Scan()
+		}
+	}
+}
+
+// 检查一次动态规则目录：新增或修改的文件检查通过后替换同名蜘蛛，
+// 检查失败时保留原有蜘蛛；删除的文件移除其蜘蛛
+func (self *Watcher) Scan() {
+	self.Lock()
+	defer self.Unlock()
+
+	exists := map[string]bool{}
+	for _, filename := range DynamicFiles(config.SPIDER_DIR) {
+		exists[filename] = true
+		info, err := os.Stat(filename)
+		if err != nil {
+			continue
+		}
+		f := self.files[filename]
+		if f != nil && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+			continue
+		}
+		if f == nil {
+			f = &dynamicFile{}
+			self.files[filename] = f
+		}
+		f.modTime, f.size = info.ModTime(), info.Size()
+		if err = self.load(filename, f); err != nil {
+			logs.Log.Error(" *     动态规则[%s]: %v", filename, err)
+		}
+	}
+
+	for filename, f := range self.files {
+		if exists[filename] {
+			continue
+		}
+		delete(self.files, filename)
+		if f.name != "" && Species.Remove(f.name) != nil {
+			logs.Log.Informational(" *     动态规则[%s]已删除，移除蜘蛛 %s", filename, f.name)
+		}
+	}
+}
+
+func (self *Watcher) load(filename string, f *dynamicFile) error {
+	sp, err := LoadDynamic(filename)
+	if err != nil {
+		return err
+	}
+	for other, o := range self.files {
+		if other != filename && o.name == sp.Name {
+			return fmt.Errorf("spider %s is already defined in %s", sp.Name, other)
+		}
+	}
+	if f.name != sp.Name && Species.GetByName(sp.Name) != nil {
+		return fmt.Errorf("spider %s is already registered", sp.Name)
+	}
+	if err = sp.prepare(); err != nil {
+		return err
+	}
+
+	if f.name != "" && f.name != sp.Name {
+		Species.Remove(f.name)
+	}
+	if f.name == "" {
+		logs.Log.Informational(" *     动态规则[%s]已加载蜘蛛 %s", filename, sp.Name)
+	} else {
+		logs.Log.Informational(" *     动态规则[%s]已更新蜘蛛 %s", filename, sp.Name)
+	}
+	Species.Replace(sp)
+	f.name = sp.Name
+	return nil
+}
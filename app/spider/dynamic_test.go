@@ -0,0 +1,80 @@
+package spider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"skynet-service/app/config"
+	"skynet-service/app/runtime/status"
+)
+
+func TestWatcherScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spiders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(old string) { config.SPIDER_DIR = old }(config.SPIDER_DIR)
+	config.SPIDER_DIR = dir
+
+	w := &Watcher{files: map[string]*dynamicFile{}}
+	filename := filepath.Join(dir, "a"+config.SpiderJsonExt)
+	mtime := time.Now()
+	write := func(s string) {
+		if err := ioutil.WriteFile(filename, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// 避免同一时钟周期内的两次写入修改时间相同
+		mtime = mtime.Add(time.Second)
+		os.Chtimes(filename, mtime, mtime)
+	}
+
+	write(`{"name":"reloadtest","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{}}}`)
+	w.Scan()
+	old := Species.GetByName("reloadtest")
+	if old == nil || old.status != status.STOPPED {
+		t.Fatalf("spider not registered: %v", old)
+	}
+	running := old.Copy()
+
+	// 有误的修改保留原有蜘蛛
+	write(`{"name":"reloadtest","root":[{"url":"http://a","rule":"b"}],"rules":{"a":{}}}`)
+	w.Scan()
+	if Species.GetByName("reloadtest") != old {
+		t.Fatal("invalid file should keep the old spider")
+	}
+
+	write(`{"name":"reloadtest","description":"v2","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{}}}`)
+	w.Scan()
+	if sp := Species.GetByName("reloadtest"); sp == old || sp.Description != "v2" {
+		t.Fatalf("spider not replaced: %v", sp)
+	}
+	if running.Description != "" {
+		t.Error("running copy should not change")
+	}
+	n := 0
+	for _, sp := range Species.Get() {
+		if sp.Name == "reloadtest" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatalf("%d spiders named reloadtest", n)
+	}
+
+	// 改名时移除旧名
+	write(`{"name":"reloadtest2","root":[{"url":"http://a","rule":"a"}],"rules":{"a":{}}}`)
+	w.Scan()
+	if Species.GetByName("reloadtest") != nil || Species.GetByName("reloadtest2") == nil {
+		t.Fatal("renamed spider not replaced")
+	}
+
+	os.Remove(filename)
+	w.Scan()
+	if Species.GetByName("reloadtest2") != nil {
+		t.Fatal("spider of deleted file not removed")
+	}
+}
@@ -2,14 +2,9 @@ package spider
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io/ioutil"
-	"log"
-	"path"
-	"path/filepath"
 
-	"github.com/robertkrimen/otto"
-
-	"skynet-service/app/config"
 	"skynet-service/app/logs"
 )
 
@@ -35,111 +30,112 @@ type (
 	}
 )
 
-func init() {
-	for _, _m := range getSpiderModles() {
-		m := _m //保证闭包变量
-		var sp = &Spider{
-			Name:            m.Name,
-			Description:     m.Description,
-			Pausetime:       m.Pausetime,
-			EnableCookie:    m.EnableCookie,
-			NotDefaultField: m.NotDefaultField,
-			RuleTree:        &RuleTree{Trunk: map[string]*Rule{}},
-		}
-		if m.EnableLimit {
-			sp.Limit = LIMIT
-		}
-		if m.EnableKeyin {
-			sp.Keyin = KEYIN
-		}
+// 读取并检查 HTML 动态规则文件，返回尚未注册的蜘蛛
+func LoadSpiderModle(filename string) (*Spider, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m SpiderModle
+	if err = xml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m.Spider()
+}
 
-		if m.Namespace != "" {
-			sp.Namespace = func(self *Spider) string {
-				vm := otto.New()
-				vm.Set("self", self)
-				val, err := vm.Eval(m.Namespace)
-				if err != nil {
-					logs.Log.Error(" *     动态规则  [Namespace]: %v\n", err)
-				}
-				s, _ := val.ToString()
-				return s
-			}
-		}
+// 编译规则中的脚本并生成蜘蛛，各脚本只编译一次，执行时复用虚拟机
+func (m *SpiderModle) Spider() (*Spider, error) {
+	if m.Name == "" {
+		return nil, fmt.Errorf("name can not be empty")
+	}
+	if m.Root == "" {
+		return nil, fmt.Errorf("root script can not be empty")
+	}
+	var sp = &Spider{
+		Name:            m.Name,
+		Description:     m.Description,
+		Pausetime:       m.Pausetime,
+		EnableCookie:    m.EnableCookie,
+		NotDefaultField: m.NotDefaultField,
+		RuleTree:        &RuleTree{Trunk: map[string]*Rule{}},
+	}
+	if m.EnableLimit {
+		sp.Limit = LIMIT
+	}
+	if m.EnableKeyin {
+		sp.Keyin = KEYIN
+	}
 
-		if m.SubNamespace != "" {
-			sp.SubNamespace = func(self *Spider, dataCell map[string]interface{}) string {
-				vm := otto.New()
-				vm.Set("self", self)
-				vm.Set("dataCell", dataCell)
-				val, err := vm.Eval(m.SubNamespace)
-				if err != nil {
-					logs.Log.Error(" *     动态规则  [SubNamespace]: %v\n", err)
-				}
-				s, _ := val.ToString()
-				return s
+	if m.Namespace != "" {
+		script, err := CompileScript("Namespace", m.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		sp.Namespace = func(self *Spider) string {
+			val, err := script.Run(map[string]interface{}{"self": self})
+			if err != nil {
+				logs.Log.Spider(self.GetName()).Error(" *     动态规则  [%s]: %v\n", self.GetName(), err)
 			}
+			s, _ := val.ToString()
+			return s
 		}
+	}
 
-		sp.RuleTree.Root = func(ctx *Context) {
-			vm := otto.New()
-			vm.Set("ctx", ctx)
-			_, err := vm.Eval(m.Root)
+	if m.SubNamespace != "" {
+		script, err := CompileScript("SubNamespace", m.SubNamespace)
+		if err != nil {
+			return nil, err
+		}
+		sp.SubNamespace = func(self *Spider, dataCell map[string]interface{}) string {
+			val, err := script.Run(map[string]interface{}{"self": self, "dataCell": dataCell})
 			if err != nil {
-				logs.Log.Error(" *     动态规则  [Root]: %v\n", err)
+				logs.Log.Spider(self.GetName()).Error(" *     动态规则  [%s]: %v\n", self.GetName(), err)
 			}
+			s, _ := val.ToString()
+			return s
 		}
+	}
 
-		for _, rule := range m.Trunk {
-			r := new(Rule)
-			r.ParseFunc = func(parse string) func(*Context) {
-				return func(ctx *Context) {
-					vm := otto.New()
-					vm.Set("ctx", ctx)
-					_, err := vm.Eval(parse)
-					if err != nil {
-						logs.Log.Error(" *     动态规则  [ParseFunc]: %v\n", err)
-					}
-				}
-			}(rule.ParseFunc)
-
-			r.AidFunc = func(parse string) func(*Context, map[string]interface{}) interface{} {
-				return func(ctx *Context, aid map[string]interface{}) interface{} {
-					vm := otto.New()
-					vm.Set("ctx", ctx)
-					vm.Set("aid", aid)
-					val, err := vm.Eval(parse)
-					if err != nil {
-						logs.Log.Error(" *     动态规则  [AidFunc]: %v\n", err)
-					}
-					return val
-				}
-			}(rule.AidFunc)
-			sp.RuleTree.Trunk[rule.Name] = r
+	root, err := CompileScript("Root", m.Root)
+	if err != nil {
+		return nil, err
+	}
+	sp.RuleTree.Root = func(ctx *Context) {
+		if _, err := root.Run(map[string]interface{}{"ctx": ctx}); err != nil {
+			logs.Log.Spider(ctx.GetName()).Error(" *     动态规则  [%s]: %v\n", ctx.GetName(), err)
 		}
-		sp.Register()
 	}
-}
 
-func getSpiderModles() (ms []*SpiderModle) {
-	defer func() {
-		if p := recover(); p != nil {
-			log.Printf("[E] HTML动态规则解析: %v\n", p)
+	for _, rule := range m.Trunk {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule name can not be empty")
+		}
+		if _, ok := sp.RuleTree.Trunk[rule.Name]; ok {
+			return nil, fmt.Errorf("rule %s: duplicate name", rule.Name)
 		}
-	}()
-	files, _ := filepath.Glob(path.Join(config.SPIDER_DIR, "*"+config.SpiderExt))
-	for _, filename := range files {
-		b, err := ioutil.ReadFile(filename)
+		parse, err := CompileScript("Rule "+rule.Name+" ParseFunc", rule.ParseFunc)
 		if err != nil {
-			log.Printf("[E] HTML动态规则[%s]: %v\n", filename, err)
-			continue
+			return nil, err
 		}
-		var m SpiderModle
-		err = xml.Unmarshal(b, &m)
+		aid, err := CompileScript("Rule "+rule.Name+" AidFunc", rule.AidFunc)
 		if err != nil {
-			log.Printf("[E] HTML动态规则[%s]: %v\n", filename, err)
-			continue
+			return nil, err
+		}
+		// 脚本出错时标记错误，该请求计为失败
+		r := new(Rule)
+		r.ParseFunc = func(ctx *Context) {
+			if _, err := parse.Run(map[string]interface{}{"ctx": ctx}); err != nil {
+				ctx.SetError(err)
+			}
+		}
+		r.AidFunc = func(ctx *Context, aidMap map[string]interface{}) interface{} {
+			val, err := aid.Run(map[string]interface{}{"ctx": ctx, "aid": aidMap})
+			if err != nil {
+				ctx.SetError(err)
+			}
+			return val
 		}
-		ms = append(ms, &m)
+		sp.RuleTree.Trunk[rule.Name] = r
 	}
-	return
+	return sp, nil
 }
@@ -0,0 +1,182 @@
+package spider
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/golang/groupcache/lru"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/html"
+)
+
+// 以下提取方法的参数与返回值均为基本类型，可在动态规则脚本中直接调用；
+// 表达式有误或下载内容无法解析时通过 SetError() 标记错误，该请求计为失败
+
+// 已编译的正则，按表达式缓存最近用到的 regexCacheSize 个，
+// 以免脚本拼接出的表达式(如含关键词)使缓存无限增长
+const regexCacheSize = 256
+
+var (
+	regexCache     = lru.New(regexCacheSize)
+	regexCacheLock sync.Mutex
+)
+
+// 按 JSONPath(gjson 语法，如 "items.0.price"、"items.#.curr")查询 JSON 内容，
+// 返回 string、float64、bool、nil、[]interface{} 或 map[string]interface{}
+func (self *Context) JsonQuery(path string) interface{} {
+	r := self.GetJson(path)
+	if !r.Exists() {
+		return nil
+	}
+	return r.Value()
+}
+
+// 同 JsonQuery()，返回 gjson.Result 以便在 Go 规则中按类型取值
+func (self *Context) GetJson(path string) gjson.Result {
+	if self.json == nil {
+		text := self.GetText()
+		if !gjson.Valid(text) {
+			self.SetError(fmt.Errorf("response is not valid json"))
+			return gjson.Result{}
+		}
+		r := gjson.Parse(text)
+		self.json = &r
+	}
+	if path == "" {
+		return *self.json
+	}
+	return self.json.Get(path)
+}
+
+// 按 XPath 查询 HTML 或 XML 内容，返回各节点的文本，属性节点(如 //a/@href)为属性值；
+// 响应头的 Content-Type 含 xml 或内容以 <?xml 开头时按 XML 解析
+func (self *Context) XPath(expr string) []string {
+	var (
+		texts []string
+		err   error
+	)
+	switch doc := self.xpathDoc().(type) {
+	case *xmlquery.Node:
+		var nodes []*xmlquery.Node
+		if nodes, err = xmlquery.QueryAll(doc, expr); err == nil {
+			for _, n := range nodes {
+				texts = append(texts, strings.TrimSpace(n.InnerText()))
+			}
+		}
+	case *html.Node:
+		var nodes []*html.Node
+		if nodes, err = htmlquery.QueryAll(doc, expr); err == nil {
+			for _, n := range nodes {
+				texts = append(texts, strings.TrimSpace(htmlquery.InnerText(n)))
+			}
+		}
+	}
+	if err != nil {
+		self.SetError(fmt.Errorf("xpath %q: %v", expr, err))
+	}
+	return texts
+}
+
+// 按 XPath 查询第一个节点的文本，不存在时返回空字符串
+func (self *Context) XPathOne(expr string) string {
+	if texts := self.XPath(expr); len(texts) > 0 {
+		return texts[0]
+	}
+	return ""
+}
+
+// 在下载内容中查找正则的第一个匹配，返回各分组的值：
+// 命名分组以组名为键，未命名分组以序号为键，未匹配时返回 nil
+func (self *Context) RegexFind(pattern string) map[string]string {
+	re := self.regex(pattern)
+	if re == nil {
+		return nil
+	}
+	m := re.FindStringSubmatch(self.GetText())
+	if m == nil {
+		return nil
+	}
+	return regexGroups(re, m)
+}
+
+// 同 RegexFind()，返回全部匹配
+func (self *Context) RegexFindAll(pattern string) []map[string]string {
+	re := self.regex(pattern)
+	if re == nil {
+		return nil
+	}
+	var list []map[string]string
+	for _, m := range re.FindAllStringSubmatch(self.GetText(), -1) {
+		list = append(list, regexGroups(re, m))
+	}
+	return list
+}
+
+func (self *Context) regex(pattern string) *regexp.Regexp {
+	regexCacheLock.Lock()
+	re, ok := regexCache.Get(pattern)
+	regexCacheLock.Unlock()
+	if ok {
+		return re.(*regexp.Regexp)
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		self.SetError(fmt.Errorf("regex %q: %v", pattern, err))
+		return nil
+	}
+	regexCacheLock.Lock()
+	regexCache.Add(pattern, compiled)
+	regexCacheLock.Unlock()
+	return compiled
+}
+
+func regexGroups(re *regexp.Regexp, m []string) map[string]string {
+	groups := make(map[string]string, len(m)-1)
+	for i, name := range re.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		groups[name] = m[i]
+	}
+	return groups
+}
+
+// 解析下载内容供 XPath 查询，结果缓存至 Context 回收
+func (self *Context) xpathDoc() interface{} {
+	if self.xdoc != nil {
+		return self.xdoc
+	}
+	text := self.GetText()
+	var err error
+	if self.isXml(text) {
+		self.xdoc, err = xmlquery.Parse(strings.NewReader(text))
+	} else {
+		self.xdoc, err = htmlquery.Parse(strings.NewReader(text))
+	}
+	if err != nil {
+		self.xdoc = nil
+		self.SetError(fmt.Errorf("parse document: %v", err))
+	}
+	return self.xdoc
+}
+
+func (self *Context) isXml(text string) bool {
+	if self.Response != nil {
+		ct := strings.ToLower(self.Response.Header.Get("Content-Type"))
+		if strings.Contains(ct, "html") {
+			return false
+		}
+		if strings.Contains(ct, "xml") {
+			return true
+		}
+	}
+	return strings.HasPrefix(strings.TrimSpace(text), "<?xml")
+}
@@ -0,0 +1,70 @@
+package spider
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"skynet-service/app/downloader/request"
+)
+
+func queryContext(body, contentType string) *Context {
+	ctx := GetContext(&Spider{}, &request.Request{Url: "http://example.com/"})
+	ctx.text = []byte(body)
+	ctx.Response = &http.Response{Header: http.Header{"Content-Type": {contentType}}}
+	return ctx
+}
+
+func TestContextQuery(t *testing.T) {
+	ctx := queryContext(`{"items":[{"curr":"USD","price":1.5},{"curr":"EUR","price":2}]}`, "application/json")
+	if v := ctx.JsonQuery("items.1.curr"); v != "EUR" {
+		t.Errorf("JsonQuery() = %v", v)
+	}
+	if v, ok := ctx.JsonQuery("items.#.price").([]interface{}); !ok || len(v) != 2 || v[0] != 1.5 {
+		t.Errorf("JsonQuery() = %v", v)
+	}
+	if ctx.JsonQuery("missing") != nil || ctx.err != nil {
+		t.Errorf("missing path: %v", ctx.err)
+	}
+
+	ctx = queryContext(`<ul><li><a href="/1"> one </a></li><li><a href="/2">two</a></li></ul>`, "text/html")
+	if v := ctx.XPath("//a"); len(v) != 2 || v[0] != "one" {
+		t.Errorf("XPath() = %v", v)
+	}
+	if v := ctx.XPathOne("//li[2]/a/@href"); v != "/2" {
+		t.Errorf("XPathOne() = %q", v)
+	}
+	if m := ctx.RegexFind(`href="/(?P<id>\d+)">\s*(\w+)`); m["id"] != "1" || m["2"] != "one" {
+		t.Errorf("RegexFind() = %v", m)
+	}
+	if l := ctx.RegexFindAll(`href="/(?P<id>\d+)"`); len(l) != 2 || l[1]["id"] != "2" {
+		t.Errorf("RegexFindAll() = %v", l)
+	}
+	if ctx.err != nil {
+		t.Fatal(ctx.err)
+	}
+	// 正则缓存只保留最近用到的表达式
+	for i := 0; i < regexCacheSize+10; i++ {
+		ctx.RegexFind(fmt.Sprintf(`id%d`, i))
+	}
+	if _, ok := regexCache.Get("id0"); ok || regexCache.Len() != regexCacheSize {
+		t.Errorf("regex cache: %d entries, id0 cached: %v", regexCache.Len(), ok)
+	}
+	if _, ok := regexCache.Get(fmt.Sprintf("id%d", regexCacheSize+9)); !ok {
+		t.Error("latest regex not cached")
+	}
+	if ctx.XPath("//a["); ctx.err == nil {
+		t.Error("invalid xpath should set error")
+	}
+
+	// 脚本中调用
+	ctx = queryContext(`<?xml version="1.0"?><rss><item><title>t1</title></item></rss>`, "")
+	s, _ := CompileScript("query", `ctx.XPathOne("//item/title") + ctx.RegexFind("<(?P<tag>rss)>").tag`)
+	val, err := s.Run(map[string]interface{}{"ctx": ctx})
+	if v, _ := val.ToString(); err != nil || v != "t1rss" {
+		t.Errorf("script = %v, %v", v, err)
+	}
+	if ctx.JsonQuery("a"); ctx.err == nil {
+		t.Error("invalid json should set error")
+	}
+}
@@ -0,0 +1,242 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"skynet-service/app/common/util"
+	"skynet-service/app/runtime/metrics"
+)
+
+var invalidItemsTotal = metrics.NewCounter("skynet_invalid_items_total",
+	"Items discarded for not matching the rule schema, by spider and rule.", "spider", "rule")
+
+// 结果字段的类型
+const (
+	TypeString = "string"
+	TypeInt    = "int"   // 转换为 int64
+	TypeFloat  = "float" // 转换为 float64
+	TypeBool   = "bool"
+	TypeTime   = "time" // 转换为 time.Time，字符串按 TimeLayouts 解析
+	TypeJSON   = "json" // 任意可编码为 JSON 的值，字符串按 JSON 解码
+)
+
+// 输出时间字段的格式，与下载时间一致
+const TimeLayout = "2006-01-02 15:04:05"
+
+// 解析时间字段时依次尝试的格式，不含时区的按本地时间解析
+var TimeLayouts = []string{
+	TimeLayout,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+// 结果字段的类型声明
+type Field struct {
+	Name     string
+	Type     string // 为空时为 string
+	Required bool   // 是否不可缺少，值为 nil 或空字符串时视为缺少
+}
+
+// 是否为合法的字段类型
+func ValidType(typ string) bool {
+	switch typ {
+	case "", TypeString, TypeInt, TypeFloat, TypeBool, TypeTime, TypeJSON:
+		return true
+	}
+	return false
+}
+
+// 返回字段声明的类型，未声明时返回空字符串
+func (self *Rule) FieldType(name string) string {
+	for _, f := range self.Schema {
+		if f.Name == name {
+			if f.Type == "" {
+				return TypeString
+			}
+			return f.Type
+		}
+	}
+	return ""
+}
+
+// 检查 Schema 与 UniqueKey，并将其中的字段按顺序补入 ItemFields
+func (self *Rule) initFields() error {
+	for _, f := range self.Schema {
+		if f.Name == "" {
+			return fmt.Errorf("field name can not be empty")
+		}
+		if !ValidType(f.Type) {
+			return fmt.Errorf("field %s: unknown type %q", f.Name, f.Type)
+		}
+		self.addItemField(f.Name)
+	}
+	for _, name := range self.UniqueKey {
+		if name == "" {
+			return fmt.Errorf("unique key field can not be empty")
+		}
+		if typ := self.FieldType(name); typ == TypeJSON {
+			return fmt.Errorf("unique key field %s can not be %s", name, typ)
+		}
+		self.addItemField(name)
+	}
+	return nil
+}
+
+func (self *Rule) addItemField(name string) {
+	for _, v := range self.ItemFields {
+		if v == name {
+			return
+		}
+	}
+	self.ItemFields = append(self.ItemFields, name)
+}
+
+// 是否为 UniqueKey 中的字段
+func (self *Rule) IsUniqueKey(name string) bool {
+	for _, v := range self.UniqueKey {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 按 Schema 检查结果并将各字段转换为声明的类型，未声明的字段保持不变；
+// 设置了 UniqueKey 时其中的字段均不可缺少
+func (self *Rule) Validate(item map[string]interface{}) error {
+	for _, f := range self.Schema {
+		v, ok := item[f.Name]
+		if !ok || v == nil || v == "" {
+			if f.Required {
+				return fmt.Errorf("field %s is required", f.Name)
+			}
+			item[f.Name] = nil
+			continue
+		}
+		v, err := ConvertValue(f.Type, v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", f.Name, err)
+		}
+		item[f.Name] = v
+	}
+	for _, name := range self.UniqueKey {
+		if v := item[name]; v == nil || v == "" {
+			return fmt.Errorf("unique key field %s is required", name)
+		}
+	}
+	return nil
+}
+
+// 将值转换为指定类型
+func ConvertValue(typ string, v interface{}) (interface{}, error) {
+	s, isString := v.(string)
+	if isString {
+		s = strings.TrimSpace(s)
+	}
+	switch typ {
+	case "", TypeString:
+		return FormatValue(v), nil
+
+	case TypeInt:
+		if isString {
+			return strconv.ParseInt(strings.Replace(s, ",", "", -1), 10, 64)
+		}
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if u := rv.Uint(); u <= math.MaxInt64 {
+				return int64(u), nil
+			}
+		case reflect.Float32, reflect.Float64:
+			if f := rv.Float(); f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+				return int64(f), nil
+			}
+		}
+
+	case TypeFloat:
+		if isString {
+			return strconv.ParseFloat(strings.Replace(s, ",", "", -1), 64)
+		}
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(rv.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), nil
+		}
+
+	case TypeBool:
+		if isString {
+			return strconv.ParseBool(s)
+		}
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+
+	case TypeTime:
+		switch t := v.(type) {
+		case time.Time:
+			return t, nil
+		case int64:
+			return time.Unix(t, 0), nil
+		case int:
+			return time.Unix(int64(t), 0), nil
+		case string:
+			for _, layout := range TimeLayouts {
+				if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+					return t, nil
+				}
+			}
+		}
+
+	case TypeJSON:
+		if isString {
+			var x interface{}
+			if err := json.Unmarshal([]byte(s), &x); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+		if _, err := json.Marshal(v); err != nil {
+			return nil, err
+		}
+		return v, nil
+
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	return nil, fmt.Errorf("can not convert %T %v to %s", v, v, typ)
+}
+
+// 以字符串形式返回结果字段的值，供 csv、excel 等文本输出使用
+func FormatValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		return x.Format(TimeLayout)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	return util.JsonString(v)
+}
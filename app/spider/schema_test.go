@@ -0,0 +1,103 @@
+package spider
+
+import (
+	"testing"
+	"time"
+
+	"skynet-service/app/downloader/request"
+)
+
+func TestValidate(t *testing.T) {
+	rule := &Rule{
+		ItemFields: []string{"name"},
+		Schema: []Field{
+			{Name: "price", Type: TypeFloat, Required: true},
+			{Name: "count", Type: TypeInt},
+			{Name: "ok", Type: TypeBool},
+			{Name: "time", Type: TypeTime},
+			{Name: "extra", Type: TypeJSON},
+		},
+	}
+	if err := rule.initFields(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rule.ItemFields) != 6 || rule.ItemFields[0] != "name" || rule.ItemFields[1] != "price" {
+		t.Fatalf("ItemFields = %v", rule.ItemFields)
+	}
+	if rule.FieldType("name") != "" || rule.FieldType("count") != TypeInt {
+		t.Fatal("FieldType() mismatch")
+	}
+
+	item := map[string]interface{}{
+		"name":  "gold",
+		"price": "1,990.5",
+		"count": 3.0,
+		"ok":    "true",
+		"time":  "2024-01-02 03:04:05",
+		"extra": `{"a":[1,2]}`,
+	}
+	if err := rule.Validate(item); err != nil {
+		t.Fatal(err)
+	}
+	if item["price"] != 1990.5 || item["count"] != int64(3) || item["ok"] != true || item["name"] != "gold" {
+		t.Fatalf("item = %v", item)
+	}
+	if tm, ok := item["time"].(time.Time); !ok || FormatValue(tm) != "2024-01-02 03:04:05" {
+		t.Fatalf("time = %v", item["time"])
+	}
+	if FormatValue(item["extra"]) != `{"a":[1,2]}` {
+		t.Fatalf("extra = %v", FormatValue(item["extra"]))
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"count": "1"},
+		{"price": "abc"},
+		{"price": 1, "count": 1.5},
+		{"price": 1, "time": "yesterday"},
+		{"price": 1, "extra": "{"},
+	} {
+		if err := rule.Validate(bad); err == nil {
+			t.Errorf("Validate(%v) should fail", bad)
+		}
+	}
+
+	if err := (&Rule{Schema: []Field{{Name: "x", Type: "date"}}}).initFields(); err == nil {
+		t.Error("unknown type should fail")
+	}
+
+	rule = &Rule{UniqueKey: []string{"id"}}
+	if err := rule.initFields(); err != nil || len(rule.ItemFields) != 1 || !rule.IsUniqueKey("id") {
+		t.Fatalf("UniqueKey: %v %v", err, rule.ItemFields)
+	}
+	if err := rule.Validate(map[string]interface{}{"id": ""}); err == nil {
+		t.Error("empty unique key should fail")
+	}
+	if err := (&Rule{UniqueKey: []string{"x"}, Schema: []Field{{Name: "x", Type: TypeJSON}}}).initFields(); err == nil {
+		t.Error("json unique key should fail")
+	}
+}
+
+// 不符合声明的结果只丢弃该条，同一页面的其他结果照常输出，请求不记为失败
+func TestOutputInvalidItem(t *testing.T) {
+	rule := &Rule{Schema: []Field{{Name: "price", Type: TypeFloat, Required: true}}}
+	if err := rule.initFields(); err != nil {
+		t.Fatal(err)
+	}
+	sp := &Spider{Name: "schematest", NotDefaultField: true, RuleTree: &RuleTree{Trunk: map[string]*Rule{"a": rule}}}
+	ctx := GetContext(sp, &request.Request{Url: "http://example.com/"})
+	defer PutContext(ctx)
+
+	before := invalidItemsTotal.Value("schematest", "a")
+	ctx.Output(map[string]interface{}{"price": "1.5"}, "a")
+	ctx.Output(map[string]interface{}{"price": "n/a"}, "a")
+	if ctx.err != nil {
+		t.Fatalf("err = %v", ctx.err)
+	}
+	items := ctx.PullItems()
+	if len(items) != 1 || items[0]["Data"].(map[string]interface{})["price"] != 1.5 {
+		t.Fatalf("items = %v", items)
+	}
+	if n := invalidItemsTotal.Value("schematest", "a") - before; n != 1 {
+		t.Fatalf("invalid items = %v", n)
+	}
+}
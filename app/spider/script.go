@@ -0,0 +1,105 @@
+package spider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+)
+
+// 动态规则脚本的调用栈深度上限，防止无限递归耗尽内存
+const ScriptStackDepth = 256
+
+// 脚本中不可用的全局对象：动态生成代码可绕过预编译检查；
+// 另删除 Function.prototype.constructor，否则 (function(){}).constructor 仍可取得 Function
+var scriptBlacklist = []string{"eval", "Function"}
+
+var errScriptTimeout = errors.New("script timeout")
+
+// 预编译的动态规则脚本，每次执行在模板虚拟机的副本上进行，
+// 全局变量不会带到下一次执行。复制约需 1ms(见 BenchmarkScriptRun)，远小于下载一个页面的耗时；
+// 副本不复用，因为脚本可能改动内置对象(如 Math.pow = null)，执行后无法可靠地还原。
+// otto 没有内存分配的钩子，无法限制单次执行的内存，
+// 只能以超时与调用栈深度限制失控的脚本
+type Script struct {
+	name    string
+	program *otto.Script
+	vm      *otto.Otto // 模板，本身不执行脚本
+}
+
+// 编译脚本，name 用于错误信息
+func CompileScript(name, src string) (*Script, error) {
+	vm := newScriptVM()
+	program, err := vm.Compile("", src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &Script{name: name, program: program, vm: vm}, nil
+}
+
+// 以 vars 为全局变量执行脚本，单次执行超过 config.Current().ScriptTimeout 秒时中断并返回错误；
+// 脚本中调用的 Go 方法引发的 panic(如主动终止任务)照常抛出
+func (self *Script) Run(vars map[string]interface{}) (val otto.Value, err error) {
+	vm := self.vm.Copy()
+	vm.Interrupt = make(chan func(), 1)
+	for _, name := range scriptBlacklist {
+		vm.Set(name, otto.UndefinedValue())
+	}
+	var (
+		timer   *time.Timer
+		timeout = config.Current().ScriptTimeout
+	)
+	if timeout > 0 {
+		timer = time.AfterFunc(time.Duration(timeout)*time.Second, func() {
+			vm.Interrupt <- func() { panic(errScriptTimeout) }
+		})
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		if p := recover(); p != nil {
+			if p != errScriptTimeout {
+				panic(p)
+			}
+			err = fmt.Errorf("%s: exceeded %ds", self.name, timeout)
+		}
+	}()
+
+	for k, v := range vars {
+		vm.Set(k, v)
+	}
+	val, err = vm.Run(self.program)
+	if err != nil {
+		if e, ok := err.(*otto.Error); ok {
+			err = fmt.Errorf("%s: %s", self.name, strings.TrimSpace(e.String()))
+		} else {
+			err = fmt.Errorf("%s: %v", self.name, err)
+		}
+	}
+	return
+}
+
+// 新建模板虚拟机，console 输出至日志；
+// Copy() 要求全局 eval 仍在，黑名单在每个副本上移除
+func newScriptVM() *otto.Otto {
+	vm := otto.New()
+	vm.SetStackDepthLimit(ScriptStackDepth)
+	vm.Run("delete Function.prototype.constructor")
+	console, _ := vm.Object("({})")
+	console.Set("log", func(call otto.FunctionCall) otto.Value {
+		args := make([]string, len(call.ArgumentList))
+		for i, v := range call.ArgumentList {
+			args[i] = v.String()
+		}
+		logs.Log.Informational(" *     动态规则  [console]: %s", strings.Join(args, " "))
+		return otto.UndefinedValue()
+	})
+	vm.Set("console", console)
+	return vm
+}
@@ -0,0 +1,123 @@
+package spider
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"skynet-service/app/config"
+)
+
+func TestScript(t *testing.T) {
+	if _, err := CompileScript("bad", "var a = ;"); err == nil || !strings.HasPrefix(err.Error(), "bad: ") {
+		t.Fatalf("compile err = %v", err)
+	}
+
+	s, err := CompileScript("add", "a + b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		val, err := s.Run(map[string]interface{}{"a": i, "b": 1})
+		if n, _ := val.ToInteger(); err != nil || n != int64(i+1) {
+			t.Fatalf("Run() = %v, %v", val, err)
+		}
+	}
+
+	s, _ = CompileScript("throw", "eval('1')")
+	if _, err = s.Run(nil); err == nil || !strings.Contains(err.Error(), "throw: ") {
+		t.Fatalf("eval should be unavailable: %v", err)
+	}
+
+	for _, src := range []string{"(function(){}).constructor('return 1')()", "Object.getPrototypeOf(console.log).constructor('return 1')()"} {
+		s, _ = CompileScript("ctor", src)
+		if _, err = s.Run(nil); err == nil {
+			t.Fatalf("%s: Function should be unavailable", src)
+		}
+	}
+
+	// 每次执行的全局变量互不影响
+	s, _ = CompileScript("global", "if (typeof n === 'undefined') { n = 0 } n += 1; Math.pow = null; n")
+	for i := 0; i < 3; i++ {
+		val, err := s.Run(nil)
+		if n, _ := val.ToInteger(); err != nil || n != 1 {
+			t.Fatalf("run %d: Run() = %v, %v", i, val, err)
+		}
+	}
+	if s, _ = CompileScript("math", "Math.pow(2, 3)"); true {
+		if val, err := s.Run(nil); err != nil || val.String() != "8" {
+			t.Fatalf("Math.pow() = %v, %v", val, err)
+		}
+	}
+
+	// Go 方法的 panic 不被脚本吞掉
+	s, _ = CompileScript("panic", "try { f() } catch (e) {}")
+	func() {
+		defer func() {
+			if p := recover(); p != FORCED_STOP {
+				t.Errorf("recover() = %v", p)
+			}
+		}()
+		s.Run(map[string]interface{}{"f": func() { panic(FORCED_STOP) }})
+	}()
+
+	defer config.SetCurrent(config.Current())
+	conf := *config.Current()
+	conf.ScriptTimeout = 1
+	config.SetCurrent(&conf)
+	s, _ = CompileScript("loop", "for (;;) { try { while (true) {} } catch (e) {} }")
+	start := time.Now()
+	if _, err = s.Run(nil); err == nil || !strings.Contains(err.Error(), "exceeded 1s") {
+		t.Fatalf("timeout err = %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("timeout took %v", time.Since(start))
+	}
+
+	s, _ = CompileScript("recurse", "function f() { return f() } f()")
+	if _, err = s.Run(nil); err == nil {
+		t.Fatal("recursion should exceed the stack depth limit")
+	}
+}
+
+// 典型的解析脚本：逐条输出数十条结果
+const benchScript = `
+var items = [];
+for (var i = 0; i < 40; i++) {
+	items.push({title: "item " + i, price: (i * 1.5).toFixed(2)});
+}
+for (var j = 0; j < items.length; j++) {
+	output(items[j].title, items[j].price);
+}
+items.length`
+
+// 每次执行复制一份模板虚拟机，与 BenchmarkScriptShared 之差即为 Copy() 的开销
+func BenchmarkScriptRun(b *testing.B) {
+	s, err := CompileScript("bench", benchScript)
+	if err != nil {
+		b.Fatal(err)
+	}
+	vars := map[string]interface{}{"output": func(title, price string) {}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Run(vars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 在同一虚拟机上反复执行，不隔离全局变量，仅作对照
+func BenchmarkScriptShared(b *testing.B) {
+	vm := newScriptVM()
+	program, err := vm.Compile("", benchScript)
+	if err != nil {
+		b.Fatal(err)
+	}
+	vm.Set("output", func(title, price string) {})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := vm.Run(program); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package spider
 
 import (
 	"fmt"
+	"sync"
 
 	"skynet-service/app/common/pinyin"
 )
@@ -11,6 +12,7 @@ type SpiderSpecies struct {
 	list   []*Spider
 	hash   map[string]*Spider
 	sorted bool
+	lock   sync.RWMutex
 }
 
 // 全局蜘蛛种类实例
@@ -21,6 +23,8 @@ var Species = &SpiderSpecies{
 
 // 向蜘蛛种类清单添加新种类
 func (self *SpiderSpecies) Add(sp *Spider) *Spider {
+	self.lock.Lock()
+	defer self.lock.Unlock()
 	name := sp.Name
 	for i := 2; true; i++ {
 		if _, ok := self.hash[name]; !ok {
@@ -32,11 +36,53 @@ func (self *SpiderSpecies) Add(sp *Spider) *Spider {
 	}
 	sp.Name = name
 	self.list = append(self.list, sp)
+	self.sorted = false
+	return sp
+}
+
+// 以 sp 替换同名种类，不存在时添加；
+// 已取得原种类副本的任务不受影响
+func (self *SpiderSpecies) Replace(sp *Spider) *Spider {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	old, ok := self.hash[sp.Name]
+	self.hash[sp.Name] = sp
+	if !ok {
+		self.list = append(self.list, sp)
+		self.sorted = false
+		return sp
+	}
+	for i, v := range self.list {
+		if v == old {
+			self.list[i] = sp
+			break
+		}
+	}
+	return sp
+}
+
+// 移除指定名称的种类，返回被移除的种类，不存在时返回 nil
+func (self *SpiderSpecies) Remove(name string) *Spider {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	sp, ok := self.hash[name]
+	if !ok {
+		return nil
+	}
+	delete(self.hash, name)
+	for i, v := range self.list {
+		if v == sp {
+			self.list = append(self.list[:i:i], self.list[i+1:]...)
+			break
+		}
+	}
 	return sp
 }
 
 // 获取全部蜘蛛种类
 func (self *SpiderSpecies) Get() []*Spider {
+	self.lock.Lock()
+	defer self.lock.Unlock()
 	if !self.sorted {
 		l := len(self.list)
 		initials := make([]string, l)
@@ -51,9 +97,11 @@ func (self *SpiderSpecies) Get() []*Spider {
 		}
 		self.sorted = true
 	}
-	return self.list
+	return append([]*Spider(nil), self.list...)
 }
 
 func (self *SpiderSpecies) GetByName(name string) *Spider {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.hash[name]
 }
@@ -1,6 +1,7 @@
 package spider
 
 import (
+	"fmt"
 	"math"
 	"sync"
 	"time"
@@ -34,11 +35,12 @@ type (
 		RuleTree        *RuleTree                                                  	// 定义具体的采集规则树
 
 		// 以下字段系统自动赋值
-		id        int               // 自动分配的SpiderQueue中的索引
-		subName   string            // 由Keyin转换为的二级标识名
-		reqMatrix *scheduler.Matrix // 请求矩阵
-		timer     *Timer            // 定时器
-		status    int               // 执行状态
+		id        int                    // 自动分配的SpiderQueue中的索引
+		subName   string                 // 由Keyin转换为的二级标识名
+		reqMatrix *scheduler.Matrix      // 请求矩阵
+		reqHook   func(*request.Request) // 设置后代替请求矩阵接收新请求
+		timer     *Timer                 // 定时器
+		status    int                    // 执行状态
 		lock      sync.RWMutex
 		once      sync.Once
 	}
@@ -50,6 +52,9 @@ type (
 	// 采集规则节点
 	Rule struct {
 		ItemFields []string                                           // 结果字段列表(选填，写上可保证字段顺序)
+		ItemKey    string                                             // 标识同一条结果的字段，设置后检测结果的变化(选填)
+		Schema     []Field                                            // 结果字段的类型声明，Output() 时据此检查与转换，其中的字段自动补入 ItemFields(选填)
+		UniqueKey  []string                                           // 唯一标识一条结果的字段组合，设置后 mysql、mgo 输出按其更新已有结果而非重复插入(选填)
 		ParseFunc  func(*Context)                                     // 内容解析函数
 		AidFunc    func(*Context, map[string]interface{}) interface{} // 通用辅助函数
 	}
@@ -57,10 +62,24 @@ type (
 
 // 添加自身到蜘蛛菜单
 func (self Spider) Register() *Spider {
-	self.status = status.STOPPED
+	self.prepare()
 	return Species.Add(&self)
 }
 
+// 初始化运行状态与各规则的字段声明，返回第一个字段声明错误
+func (self *Spider) prepare() (err error) {
+	self.status = status.STOPPED
+	for name, rule := range self.RuleTree.Trunk {
+		if e := rule.initFields(); e != nil {
+			logs.Log.Error("蜘蛛 %s 的规则 %s 字段声明有误：%v", self.Name, name, e)
+			if err == nil {
+				err = fmt.Errorf("rule %s: %v", name, e)
+			}
+		}
+	}
+	return
+}
+
 // 指定规则的获取结果的字段名列表
 func (self *Spider) GetItemFields(rule *Rule) []string {
 	return rule.ItemFields
@@ -214,6 +233,9 @@ func (self *Spider) Copy() *Spider {
 
 		ghost.RuleTree.Trunk[k].ItemFields = make([]string, len(v.ItemFields))
 		copy(ghost.RuleTree.Trunk[k].ItemFields, v.ItemFields)
+		ghost.RuleTree.Trunk[k].ItemKey = v.ItemKey
+		ghost.RuleTree.Trunk[k].Schema = v.Schema
+		ghost.RuleTree.Trunk[k].UniqueKey = v.UniqueKey
 
 		ghost.RuleTree.Trunk[k].ParseFunc = v.ParseFunc
 		ghost.RuleTree.Trunk[k].AidFunc = v.AidFunc
@@ -236,24 +258,54 @@ func (self *Spider) Copy() *Spider {
 }
 
 func (self *Spider) ReqmatrixInit() *Spider {
+	var matrix *scheduler.Matrix
 	if self.Limit < 0 {
-		self.reqMatrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), self.Limit)
+		matrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), self.Limit)
 		self.SetLimit(0)
 	} else {
-		self.reqMatrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), math.MinInt64)
+		matrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), math.MinInt64)
 	}
+	self.lock.Lock()
+	self.reqMatrix = matrix
+	self.lock.Unlock()
 	return self
 }
 
+// 返回实时进度，尚未开始执行时返回 false
+func (self *Spider) Progress() (scheduler.Progress, bool) {
+	self.lock.RLock()
+	matrix := self.reqMatrix
+	self.lock.RUnlock()
+	if matrix == nil {
+		return scheduler.Progress{}, false
+	}
+	return matrix.Progress(), true
+}
+
+// 统计解析出的结果数
+func (self *Spider) CountResult(items, files int) {
+	self.reqMatrix.CountResult(items, files)
+}
+
 // 返回是否作为新的失败请求被添加至队列尾部
 func (self *Spider) DoHistory(req *request.Request, ok bool) bool {
 	return self.reqMatrix.DoHistory(req, ok)
 }
 
 func (self *Spider) RequestPush(req *request.Request) {
+	if self.reqHook != nil {
+		self.reqHook(req)
+		return
+	}
 	self.reqMatrix.Push(req)
 }
 
+// 由 f 接收规则添加的请求而不进入调度，用于脱离调度器测试规则树
+func (self *Spider) SetRequestHook(f func(*request.Request)) *Spider {
+	self.reqHook = f
+	return self
+}
+
 func (self *Spider) RequestPull() *request.Request {
 	return self.reqMatrix.Pull()
 }
@@ -282,13 +334,19 @@ func (self *Spider) TryFlushFailure() {
 func (self *Spider) Start() {
 	defer func() {
 		if p := recover(); p != nil {
-			logs.Log.Error("Panic  [root]: %v", p)
+			logs.Log.Spider(self.Name).Error("Panic  [root][%s]: %v", self.Name, p)
 		}
 		self.lock.Lock()
 		self.status = status.RUN
 		self.lock.Unlock()
 	}()
-	self.RuleTree.Root(GetContext(self, nil))
+	ctx := GetContext(self, nil)
+	self.RuleTree.Root(ctx)
+	// Root 中通过 ctx.SetError() 标记的错误(如自定义输入有误)计为一次失败
+	if ctx.err != nil {
+		logs.Log.Spider(self.Name).Error(" *     Fail  [root][%s]: %v", self.Name, ctx.err)
+		self.reqMatrix.CountFailure()
+	}
 }
 
 // 主动崩溃爬虫运行协程
@@ -336,6 +394,8 @@ func (self *Spider) Defer() {
 	self.reqMatrix.Wait()
 	// 更新失败记录
 	self.reqMatrix.TryFlushFailure()
+	// 停止计时
+	self.reqMatrix.Finish()
 }
 
 // 是否输出默认添加的字段 Url/ParentUrl/DownloadTime
@@ -0,0 +1,233 @@
+// 以录制的 HTTP 响应回放蜘蛛规则树，用于在不访问真实站点的情况下测试蜘蛛
+package spidertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"skynet-service/app/downloader"
+	"skynet-service/app/downloader/request"
+	"skynet-service/app/spider"
+)
+
+// 一条录制的请求与响应
+type Fixture struct {
+	Method   string            `json:"method,omitempty"`   // 请求方法，为空时为 GET
+	Url      string            `json:"url"`                // 请求地址，须与 Request.Url 完全一致
+	Body     string            `json:"body,omitempty"`     // 请求体，为空时匹配任意请求体
+	Status   int               `json:"status,omitempty"`   // 响应状态码，为空时为 200
+	Header   map[string]string `json:"header,omitempty"`   // 响应头
+	Response string            `json:"response,omitempty"` // 响应内容
+	File     string            `json:"file,omitempty"`     // 响应内容所在文件，相对于 fixture 文件所在目录，设置后忽略 Response
+}
+
+// 回放 fixture 的下载器，实现 downloader.Downloader
+type Replayer struct {
+	fixtures map[string]*Fixture
+	dir      string // 读取 Fixture.File 的目录
+	sync.RWMutex
+}
+
+var _ downloader.Downloader = (*Replayer)(nil)
+
+func NewReplayer(fixtures ...*Fixture) *Replayer {
+	self := &Replayer{fixtures: map[string]*Fixture{}}
+	for _, f := range fixtures {
+		self.Add(f)
+	}
+	return self
+}
+
+// 读取 fixture 文件(JSON 数组)，path 为目录时读取其中全部 .json 文件
+func Load(path string) (*Replayer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	self := NewReplayer()
+	self.dir = filepath.Dir(path)
+	if info.IsDir() {
+		self.dir = path
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var list []*Fixture
+		if err = json.Unmarshal(b, &list); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		for _, f := range list {
+			self.Add(f)
+		}
+	}
+	return self, nil
+}
+
+// 添加 fixture，同一请求已存在时覆盖
+func (self *Replayer) Add(f *Fixture) *Replayer {
+	self.Lock()
+	self.fixtures[fixtureKey(f.Method, f.Url, f.Body)] = f
+	self.Unlock()
+	return self
+}
+
+// 按请求查找 fixture，先匹配请求体，再匹配未指定请求体的 fixture
+func (self *Replayer) Find(method, url, body string) (*Fixture, bool) {
+	self.RLock()
+	defer self.RUnlock()
+	if f, ok := self.fixtures[fixtureKey(method, url, body)]; ok {
+		return f, true
+	}
+	f, ok := self.fixtures[fixtureKey(method, url, "")]
+	return f, ok
+}
+
+func (self *Replayer) Download(sp *spider.Spider, req *request.Request) *spider.Context {
+	ctx := spider.GetContext(sp, req)
+	f, ok := self.Find(req.GetMethod(), req.GetUrl(), req.GetPostData())
+	if !ok {
+		ctx.SetError(fmt.Errorf("no fixture for %s %s", req.GetMethod(), req.GetUrl()))
+		return ctx
+	}
+	body := []byte(f.Response)
+	if f.File != "" {
+		var err error
+		if body, err = ioutil.ReadFile(filepath.Join(self.dir, f.File)); err != nil {
+			ctx.SetError(err)
+			return ctx
+		}
+	}
+	resp, err := f.response(req, body)
+	if err != nil {
+		ctx.SetError(err)
+		return ctx
+	}
+	ctx.SetResponse(resp)
+	if resp.StatusCode >= 400 {
+		ctx.SetError(fmt.Errorf("响应状态 %s", resp.Status))
+	}
+	return ctx
+}
+
+func (self *Fixture) response(req *request.Request, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequest(httpMethod(req.GetMethod()), req.GetUrl(), strings.NewReader(req.GetPostData()))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.GetHeader() {
+		httpReq.Header[k] = v
+	}
+	status := self.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       httpReq,
+	}
+	for k, v := range self.Header {
+		resp.Header.Set(k, v)
+	}
+	return resp, nil
+}
+
+// 录制经由 Downloader 下载的响应，用于生成 fixture 文件
+type Recorder struct {
+	Downloader downloader.Downloader
+	fixtures   []*Fixture
+	sync.Mutex
+}
+
+var _ downloader.Downloader = (*Recorder)(nil)
+
+func NewRecorder(d downloader.Downloader) *Recorder {
+	return &Recorder{Downloader: d}
+}
+
+func (self *Recorder) Download(sp *spider.Spider, req *request.Request) *spider.Context {
+	ctx := self.Downloader.Download(sp, req)
+	resp := ctx.GetResponse()
+	if resp == nil {
+		return ctx
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ctx
+	}
+	f := &Fixture{
+		Url:      req.GetUrl(),
+		Body:     req.GetPostData(),
+		Status:   resp.StatusCode,
+		Header:   map[string]string{},
+		Response: string(body),
+	}
+	if m := req.GetMethod(); m != "GET" {
+		f.Method = m
+	}
+	for k := range resp.Header {
+		f.Header[k] = resp.Header.Get(k)
+	}
+	self.Lock()
+	self.fixtures = append(self.fixtures, f)
+	self.Unlock()
+	return ctx
+}
+
+// 已录制的 fixture
+func (self *Recorder) Fixtures() []*Fixture {
+	self.Lock()
+	defer self.Unlock()
+	return append([]*Fixture(nil), self.fixtures...)
+}
+
+// 将已录制的 fixture 写入文件，可由 Load 读取
+func (self *Recorder) Save(path string) error {
+	b, err := json.MarshalIndent(self.Fixtures(), "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+func fixtureKey(method, url, body string) string {
+	return httpMethod(method) + " " + url + "\n" + body
+}
+
+// 规则中的 POST-M 等方法按实际发送的 HTTP 方法匹配
+func httpMethod(method string) string {
+	method = strings.ToUpper(method)
+	switch method {
+	case "":
+		return "GET"
+	case "POST-M":
+		return "POST"
+	}
+	return method
+}
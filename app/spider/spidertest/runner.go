@@ -0,0 +1,193 @@
+package spidertest
+
+import (
+	"fmt"
+
+	"skynet-service/app/downloader"
+	"skynet-service/app/downloader/request"
+	"skynet-service/app/pipeline/collector/data"
+	"skynet-service/app/spider"
+)
+
+// 未设置 Runner.MaxRequests 时最多处理的请求数
+const DefaultMaxRequests = 1000
+
+// 在测试中运行蜘蛛规则树：请求不经调度器与历史记录，直接由 Downloader 下载后交给规则解析
+type Runner struct {
+	Spider      *spider.Spider        // 蜘蛛，运行时使用同名已注册蜘蛛的副本
+	Downloader  downloader.Downloader // 一般为 *Replayer
+	Keyin       string                // 自定义输入，为空时保持蜘蛛的默认值
+	Limit       int64                 // 蜘蛛采用自定义限制方案(Limit 为 spider.LIMIT)时传给规则的采集上限
+	MaxRequests int                   // 最多处理的请求数，为 0 时为 DefaultMaxRequests
+}
+
+// 一次运行捕获的全部输出
+type Result struct {
+	Items    []data.DataCell    // Context.Output 输出的结果
+	Files    []data.FileCell    // Context.FileOutput 输出的文件
+	Requests []*request.Request // Context.AddQueue 添加的全部请求，含重复的请求
+	Failures []*Failure         // 下载或解析失败的请求
+}
+
+// 失败的请求，Request 为 nil 时为 Root 执行失败或在 Root 中标记了错误
+type Failure struct {
+	Request *request.Request
+	Err     error
+}
+
+func (self *Failure) Error() string {
+	if self.Request == nil {
+		return fmt.Sprintf("root: %v", self.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", self.Request.GetMethod(), self.Request.GetUrl(), self.Err)
+}
+
+// 从 Root 开始执行，按优先级依次处理添加的请求直至队列为空；
+// 与调度器一致，不可重复下载的请求只处理一次
+func (self *Runner) Run() *Result {
+	result := &Result{}
+	var queue []*request.Request
+	sp := self.spider(result, &queue)
+
+	ctx := spider.GetContext(sp, nil)
+	if self.call(result, nil, func() { sp.RuleTree.Root(ctx) }) {
+		if err := ctx.GetError(); err != nil {
+			result.Failures = append(result.Failures, &Failure{nil, err})
+		}
+	}
+	self.collect(result, ctx)
+
+	seen := map[string]bool{}
+	max := self.MaxRequests
+	if max <= 0 {
+		max = DefaultMaxRequests
+	}
+	for n := 0; len(queue) > 0; {
+		req := pop(&queue)
+		if !req.IsReloadable() {
+			if seen[req.Unique()] {
+				continue
+			}
+			seen[req.Unique()] = true
+		}
+		if n++; n > max {
+			result.Failures = append(result.Failures, &Failure{req, fmt.Errorf("exceeded %d requests", max)})
+			break
+		}
+		self.process(result, sp, req)
+	}
+	return result
+}
+
+// 仅下载并解析 req，其中添加的请求记入 Result.Requests 而不再处理；
+// req 须已设置 Url 与 Rule
+func (self *Runner) Process(req *request.Request) *Result {
+	result := &Result{}
+	var queue []*request.Request
+	sp := self.spider(result, &queue)
+	if err := req.SetSpiderName(sp.GetName()).Prepare(); err != nil {
+		result.Failures = append(result.Failures, &Failure{req, err})
+		return result
+	}
+	self.process(result, sp, req)
+	return result
+}
+
+// 指定规则输出的结果数据
+func (self *Result) ItemsOf(ruleName string) []map[string]interface{} {
+	var items []map[string]interface{}
+	for _, cell := range self.Items {
+		if cell["RuleName"] == ruleName {
+			items = append(items, cell["Data"].(map[string]interface{}))
+		}
+	}
+	return items
+}
+
+// 交由指定规则解析的请求
+func (self *Result) RequestsOf(ruleName string) []*request.Request {
+	var reqs []*request.Request
+	for _, req := range self.Requests {
+		if req.GetRuleName() == ruleName {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// 第一个失败，全部成功时为 nil
+func (self *Result) Err() error {
+	if len(self.Failures) == 0 {
+		return nil
+	}
+	return self.Failures[0]
+}
+
+// 生成蜘蛛副本，规则添加的请求记入 result 并放入 queue
+func (self *Runner) spider(result *Result, queue *[]*request.Request) *spider.Spider {
+	base := self.Spider
+	if registered := spider.Species.GetByName(base.GetName()); registered != nil {
+		// 注册前的蜘蛛变量尚未初始化运行状态与字段声明
+		base = registered
+	}
+	sp := base.Copy()
+	if self.Keyin != "" {
+		sp.SetKeyin(self.Keyin)
+	}
+	if self.Limit > 0 && sp.GetLimit() > 0 {
+		sp.SetLimit(self.Limit)
+	}
+	sp.SetRequestHook(func(req *request.Request) {
+		result.Requests = append(result.Requests, req)
+		*queue = append(*queue, req)
+	})
+	return sp
+}
+
+// 与 crawler 的处理流程一致：下载失败或解析时标记错误均记为失败
+func (self *Runner) process(result *Result, sp *spider.Spider, req *request.Request) {
+	ctx := self.Downloader.Download(sp, req)
+	defer spider.PutContext(ctx)
+	if err := ctx.GetError(); err != nil {
+		result.Failures = append(result.Failures, &Failure{req, err})
+		return
+	}
+	if !self.call(result, req, func() { ctx.Parse(req.GetRuleName()) }) {
+		return
+	}
+	if err := ctx.GetError(); err != nil {
+		result.Failures = append(result.Failures, &Failure{req, err})
+		return
+	}
+	self.collect(result, ctx)
+}
+
+// 执行 f，崩溃时记为失败
+func (self *Runner) call(result *Result, req *request.Request, f func()) (ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			result.Failures = append(result.Failures, &Failure{req, fmt.Errorf("panic: %v", p)})
+		}
+	}()
+	f()
+	return true
+}
+
+func (self *Runner) collect(result *Result, ctx *spider.Context) {
+	result.Items = append(result.Items, ctx.PullItems()...)
+	result.Files = append(result.Files, ctx.PullFiles()...)
+}
+
+// 取出优先级最高的请求，同一优先级先进先出
+func pop(queue *[]*request.Request) *request.Request {
+	q := *queue
+	idx := 0
+	for i, req := range q {
+		if req.GetPriority() > q[idx].GetPriority() {
+			idx = i
+		}
+	}
+	req := q[idx]
+	*queue = append(q[:idx], q[idx+1:]...)
+	return req
+}
@@ -0,0 +1,68 @@
+package spidertest
+
+import (
+	"testing"
+
+	"skynet-service/app/common/goquery"
+	"skynet-service/app/downloader/request"
+	"skynet-service/app/spider"
+)
+
+var testSpider = spider.Spider{
+	Name: "spidertest",
+	RuleTree: &spider.RuleTree{
+		Root: func(ctx *spider.Context) {
+			ctx.AddQueue(&request.Request{Url: "http://example.com/list", Rule: "list"})
+			ctx.AddQueue(&request.Request{Url: "http://example.com/list", Rule: "list"})
+			ctx.AddQueue(&request.Request{Url: "http://example.com/missing", Rule: "list"})
+		},
+		Trunk: map[string]*spider.Rule{
+			"list": {
+				ParseFunc: func(ctx *spider.Context) {
+					ctx.GetDom().Find("a").Each(func(i int, s *goquery.Selection) {
+						href, _ := s.Attr("href")
+						ctx.AddQueue(&request.Request{Url: href, Rule: "item", Priority: i})
+					})
+				},
+			},
+			"item": {
+				ItemFields: []string{"url"},
+				ParseFunc: func(ctx *spider.Context) {
+					ctx.Output(map[int]interface{}{0: ctx.GetUrl()})
+					if ctx.GetUrl() == "http://example.com/2" {
+						ctx.FileOutput("page.html")
+					}
+				},
+			},
+		},
+	},
+}.Register()
+
+func TestRun(t *testing.T) {
+	replayer := NewReplayer(
+		&Fixture{Url: "http://example.com/list", Response: `<a href="http://example.com/1">1</a><a href="http://example.com/2">2</a>`},
+		&Fixture{Url: "http://example.com/1", Response: `<h1>one</h1>`},
+		&Fixture{Url: "http://example.com/2", Response: `<h1>two</h1>`},
+	)
+	result := (&Runner{Spider: testSpider, Downloader: replayer}).Run()
+
+	if len(result.RequestsOf("list")) != 3 || len(result.RequestsOf("item")) != 2 {
+		t.Fatalf("requests = %v", result.Requests)
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Request.GetUrl() != "http://example.com/missing" {
+		t.Fatalf("failures = %v", result.Failures)
+	}
+	// 优先级高的请求先处理
+	items := result.ItemsOf("item")
+	if len(items) != 2 || items[0]["url"] != "http://example.com/2" || items[1]["url"] != "http://example.com/1" {
+		t.Fatalf("items = %v", items)
+	}
+	if len(result.Files) != 1 || string(result.Files[0]["Bytes"].([]byte)) != "<h1>two</h1>" {
+		t.Fatalf("files = %v", result.Files)
+	}
+
+	result = (&Runner{Spider: testSpider, Downloader: replayer}).Process(&request.Request{Url: "http://example.com/list", Rule: "list"})
+	if result.Err() != nil || len(result.Requests) != 2 || len(result.Items) != 0 {
+		t.Fatalf("Process() = %v, %v", result.Requests, result.Err())
+	}
+}
@@ -0,0 +1,376 @@
+package spider
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/file"
+	"github.com/robertkrimen/otto/parser"
+
+	"skynet-service/app/config"
+)
+
+// 规则文件中的一处问题，Line 为 0 时不定位到行
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (self *Diagnostic) String() string {
+	switch {
+	case self.Line <= 0:
+		return fmt.Sprintf("%s: %s", self.File, self.Message)
+	case self.Column <= 0:
+		return fmt.Sprintf("%s:%d: %s", self.File, self.Line, self.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", self.File, self.Line, self.Column, self.Message)
+}
+
+// 检查一组规则文件，返回按文件与位置排序的问题；
+// 除各文件自身的检查外，还检查不同文件间的蜘蛛重名
+func ValidateFiles(files []string) []*Diagnostic {
+	var diags []*Diagnostic
+	defined := map[string]string{}
+	for _, filename := range files {
+		name, ds := ValidateFile(filename)
+		diags = append(diags, ds...)
+		if name == "" {
+			continue
+		}
+		if other, ok := defined[name]; ok {
+			diags = append(diags, &Diagnostic{File: filename, Message: fmt.Sprintf("spider %s is already defined in %s", name, other)})
+			continue
+		}
+		defined[name] = filename
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diags
+}
+
+// 检查一个规则文件，返回其中定义的蜘蛛名与发现的问题
+func ValidateFile(filename string) (string, []*Diagnostic) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", []*Diagnostic{{File: filename, Message: err.Error()}}
+	}
+	if strings.HasSuffix(filename, config.SpiderExt) {
+		return validateModle(filename, b)
+	}
+	return validateDeclared(filename, b)
+}
+
+// 声明式规则：语法错误定位到行，其余按 DeclaredSpider.Spider() 的检查
+func validateDeclared(filename string, b []byte) (string, []*Diagnostic) {
+	isYaml := !strings.HasSuffix(filename, config.SpiderJsonExt)
+	m, err := ParseDeclared(b, isYaml)
+	if err != nil {
+		d := &Diagnostic{File: filename, Message: err.Error()}
+		switch e := err.(type) {
+		case *json.SyntaxError:
+			d.Line, d.Column = position(b, int(e.Offset))
+		case *json.UnmarshalTypeError:
+			d.Line, d.Column = position(b, int(e.Offset))
+		default:
+			if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+				d.Line, _ = strconv.Atoi(m[1])
+			}
+		}
+		return "", []*Diagnostic{d}
+	}
+	if _, err = m.Spider(); err != nil {
+		return m.Name, []*Diagnostic{{File: filename, Message: err.Error()}}
+	}
+	return m.Name, nil
+}
+
+var yamlLine = regexp.MustCompile(`line (\d+)`)
+
+// HTML 动态规则中的一段脚本
+type scriptBlock struct {
+	name    string // 同 CompileScript 的 name
+	src     string
+	line    int    // 脚本首字符在文件中的行
+	file    []byte // 所在文件的内容
+	offsets []int  // src 各字节在文件中的字节偏移，末尾多一项为脚本结束处
+}
+
+// HTML 动态规则：定位 XML 与脚本的语法错误，并检查脚本中以字面量引用的规则是否存在
+func validateModle(filename string, b []byte) (string, []*Diagnostic) {
+	var (
+		m     SpiderModle
+		diags []*Diagnostic
+		add   = func(line, column int, format string, a ...interface{}) {
+			diags = append(diags, &Diagnostic{filename, line, column, fmt.Sprintf(format, a...)})
+		}
+	)
+	if err := xml.Unmarshal(b, &m); err != nil {
+		line := 0
+		if e, ok := err.(*xml.SyntaxError); ok {
+			line = e.Line
+		}
+		add(line, 0, "%v", err)
+		return "", diags
+	}
+	blocks, rules, err := scriptBlocks(b)
+	if err != nil {
+		add(0, 0, "%v", err)
+		return m.Name, diags
+	}
+
+	if m.Name == "" {
+		add(0, 0, "name can not be empty")
+	}
+	if strings.TrimSpace(m.Root) == "" {
+		add(0, 0, "root script can not be empty")
+	}
+	trunk := map[string]bool{}
+	for _, r := range rules {
+		switch {
+		case r.name == "":
+			add(r.line, 0, "rule name can not be empty")
+		case trunk[r.name]:
+			add(r.line, 0, "rule %s: duplicate name", r.name)
+		}
+		trunk[r.name] = true
+	}
+
+	for _, s := range blocks {
+		program, err := parser.ParseFile(nil, "", s.src, 0)
+		if err != nil {
+			if list, ok := err.(parser.ErrorList); ok {
+				for _, e := range list {
+					line, column := s.position(e.Position.Line, e.Position.Column)
+					add(line, column, "%s: %s", s.name, e.Message)
+				}
+			} else {
+				add(s.line, 0, "%s: %v", s.name, err)
+			}
+			continue
+		}
+		ast.Walk(&ruleRefs{func(rule string, idx file.Idx) {
+			if trunk[rule] {
+				return
+			}
+			line, column := s.line, 0
+			if p := program.File.Position(idx); p != nil {
+				line, column = s.position(p.Line, p.Column)
+			}
+			add(line, column, "%s: rule %q not exists", s.name, rule)
+		}}, program)
+	}
+	return m.Name, diags
+}
+
+// 脚本内的行列(列按字节计)换算为文件中的行列；
+// 经由字节偏移换算，CDATA 标记、实体及换行的转换均不影响列
+func (self *scriptBlock) position(line, column int) (int, int) {
+	i := 0
+	for ; line > 1; line-- {
+		n := strings.IndexByte(self.src[i:], '\n')
+		if n < 0 {
+			break
+		}
+		i += n + 1
+	}
+	if i += column - 1; i < 0 {
+		i = 0
+	} else if i >= len(self.offsets) {
+		i = len(self.offsets) - 1
+	}
+	return position(self.file, self.offsets[i])
+}
+
+// 记录一段文本在文件中的字节偏移：raw 为该段文本在文件中的原文，text 为解码后的内容；
+// 与 encoding/xml 一致，"\r\n" 与单独的 "\r" 解码为 "\n"，CDATA 以外的实体解码为对应的字符
+func (self *scriptBlock) addText(raw []byte, offset int, text string) {
+	if len(self.offsets) > 0 {
+		self.offsets = self.offsets[:len(self.offsets)-1]
+	}
+	self.src += text
+	end := offset + len(raw)
+	cdata := bytes.HasPrefix(raw, []byte("<![CDATA[")) && bytes.HasSuffix(raw, []byte("]]>"))
+	if cdata {
+		raw, offset = raw[9:len(raw)-3], offset+9
+	}
+	for i := 0; i < len(raw); i++ {
+		n := 1
+		switch {
+		case raw[i] == '\r' && i+1 < len(raw) && raw[i+1] == '\n':
+			n = 0
+		case raw[i] == '&' && !cdata:
+			if j := bytes.IndexByte(raw[i:], ';'); j > 0 {
+				n = entityLen(string(raw[i+1 : i+j]))
+				for k := 0; k < n; k++ {
+					self.offsets = append(self.offsets, offset+i)
+				}
+				i += j
+				continue
+			}
+		}
+		for k := 0; k < n; k++ {
+			self.offsets = append(self.offsets, offset+i)
+		}
+	}
+	self.offsets = append(self.offsets, end)
+}
+
+// 实体解码后的字节数，如 "lt" 为 1，"#x4e2d" 为 3
+func entityLen(name string) int {
+	if !strings.HasPrefix(name, "#") {
+		return 1
+	}
+	var (
+		n   uint64
+		err error
+	)
+	if strings.HasPrefix(name, "#x") {
+		n, err = strconv.ParseUint(name[2:], 16, 32)
+	} else {
+		n, err = strconv.ParseUint(name[1:], 10, 32)
+	}
+	if err != nil || utf8.RuneLen(rune(n)) < 0 {
+		return 1
+	}
+	return utf8.RuneLen(rune(n))
+}
+
+type ruleDecl struct {
+	name string
+	line int
+}
+
+// 逐个读取 XML 标记，取得各段脚本与规则的位置
+func scriptBlocks(b []byte) ([]*scriptBlock, []*ruleDecl, error) {
+	var (
+		blocks []*scriptBlock
+		rules  []*ruleDecl
+		path   []string
+		rule   string
+		cur    *scriptBlock
+		d      = xml.NewDecoder(bytes.NewReader(b))
+	)
+	for {
+		offset := int(d.InputOffset())
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			switch {
+			case len(path) == 2 && t.Name.Local == "Rule":
+				rule = ""
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "name" {
+						rule = attr.Value
+					}
+				}
+				line, _ := position(b, offset)
+				rules = append(rules, &ruleDecl{rule, line})
+			case t.Name.Local == "Script" && len(path) >= 3:
+				name := path[len(path)-2]
+				if path[1] == "Rule" {
+					name = "Rule " + rule + " " + name
+				}
+				cur = &scriptBlock{name: name, file: b}
+			}
+		case xml.CharData:
+			if cur != nil {
+				cur.addText(b[offset:d.InputOffset()], offset, string(t))
+				if cur.line == 0 {
+					cur.line, _ = position(b, cur.offsets[0])
+				}
+			}
+		case xml.EndElement:
+			if cur != nil && t.Name.Local == "Script" {
+				blocks = append(blocks, cur)
+				cur = nil
+			}
+			path = path[:len(path)-1]
+		}
+	}
+	return blocks, rules, nil
+}
+
+// 字节偏移所在的行列，均从 1 开始
+func position(b []byte, offset int) (line, column int) {
+	if offset > len(b) {
+		offset = len(b)
+	}
+	head := b[:offset]
+	line = bytes.Count(head, []byte("\n")) + 1
+	return line, len([]rune(string(head[bytes.LastIndexByte(head, '\n')+1:]))) + 1
+}
+
+// 查找脚本中以字符串字面量引用规则的调用：
+// ctx.AddQueue({Rule: "x"})、ctx.JsAddQueue({Rule: "x"})、ctx.Parse("x")、ctx.Aid(aid, "x")
+type ruleRefs struct {
+	found func(rule string, idx file.Idx)
+}
+
+func (self *ruleRefs) Enter(n ast.Node) ast.Visitor {
+	call, ok := n.(*ast.CallExpression)
+	if !ok {
+		return self
+	}
+	dot, ok := call.Callee.(*ast.DotExpression)
+	if !ok {
+		return self
+	}
+	args := call.ArgumentList
+	switch dot.Identifier.Name {
+	case "AddQueue", "JsAddQueue":
+		if len(args) == 0 {
+			break
+		}
+		if obj, ok := args[0].(*ast.ObjectLiteral); ok {
+			for _, p := range obj.Value {
+				if p.Key == "Rule" {
+					self.literal(p.Value)
+				}
+			}
+		}
+	case "Parse":
+		if len(args) > 0 {
+			self.literal(args[0])
+		}
+	case "Aid":
+		if len(args) > 1 {
+			self.literal(args[1])
+		}
+	}
+	return self
+}
+
+func (self *ruleRefs) Exit(ast.Node) {}
+
+func (self *ruleRefs) literal(e ast.Expression) {
+	if s, ok := e.(*ast.StringLiteral); ok {
+		self.found(s.Value, s.Idx)
+	}
+}
@@ -0,0 +1,55 @@
+package spider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFile(t *testing.T) {
+	name, diags := ValidateFile("testdata/invalid.spider.html")
+	if name != "A" {
+		t.Errorf("name = %q", name)
+	}
+	want := map[string]bool{
+		"testdata/invalid.spider.html:6:43: Root: rule \"nope\" not exists":                 true,
+		"testdata/invalid.spider.html:12:13: Rule list ParseFunc: Unexpected token ;":       true,
+		"testdata/invalid.spider.html:17:32: Rule item ParseFunc: rule \"gone\" not exists": true,
+	}
+	for _, d := range diags {
+		delete(want, d.String())
+	}
+	for s := range want {
+		t.Errorf("missing diagnostic %s in %v", s, diags)
+	}
+
+	// 位置不定的问题排在同一文件的最前
+	diags = ValidateFiles([]string{"testdata/invalid.spider.html", "testdata/invalid.spider.html"})
+	if first := diags[0]; first.Line != 0 || first.Message != "spider A is already defined in testdata/invalid.spider.html" {
+		t.Errorf("duplicate = %v", first)
+	}
+}
+
+func TestValidateScriptPosition(t *testing.T) {
+	// 与 CDATA 标记、实体同行的脚本，以及 CRLF 换行
+	b := []byte("<Spider>\r\n" +
+		"\t<Name>B</Name>\r\n" +
+		"\t<Root><Script><![CDATA[ctx.Parse(\"nope\")]]></Script></Root>\r\n" +
+		"\t<Rule name=\"a\">\r\n" +
+		"\t\t<ParseFunc><Script>if (1 &lt; 2) ctx.Parse(\"gone\");\r\n" +
+		"\t\t\tvar s = \"&#x4e2d;\"; ctx.Parse(\"lost\")</Script></ParseFunc>\r\n" +
+		"\t</Rule>\r\n" +
+		"</Spider>\r\n")
+	_, diags := validateModle("b.spider.html", b)
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"b.spider.html:3:35: Root: rule \"nope\" not exists",
+		"b.spider.html:5:46: Rule a ParseFunc: rule \"gone\" not exists",
+		"b.spider.html:6:34: Rule a ParseFunc: rule \"lost\" not exists",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s", strings.Join(got, "\n"))
+	}
+}
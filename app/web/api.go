@@ -0,0 +1,299 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"skynet-service/app/pipeline/collector"
+	"skynet-service/app/runtime/cache"
+	"skynet-service/app/runtime/status"
+	"skynet-service/app/spider"
+)
+
+type (
+	// 蜘蛛信息
+	spiderInfo struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Keyin       string `json:"keyin"`
+		Limit       int64  `json:"limit"`
+	}
+	// 运行状态
+	statusInfo struct {
+		Status  string          `json:"status"`
+		Mode    string          `json:"mode"`
+		Spiders []string        `json:"spiders"` // 当前任务的蜘蛛队列
+		Pages   pageInfo        `json:"pages"`
+		Reports []*cache.Report `json:"reports"` // 最近一次任务的报告
+	}
+	pageInfo struct {
+		Total   uint64 `json:"total"`
+		Success uint64 `json:"success"`
+		Failure uint64 `json:"failure"`
+	}
+	// 运行请求
+	runRequest struct {
+		Spiders []string `json:"spiders"` // 为空时运行全部蜘蛛
+	}
+)
+
+// 须重启进程才能变更的参数，不允许经由接口修改
+var readonlyConf = map[string]bool{
+	"RunType": true,
+	"Mode":    true,
+	"Port":    true,
+	"Master":  true,
+}
+
+// GET /api/status
+func (self *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	info := statusInfo{
+		Status:  statusText(self.app.Status()),
+		Mode:    modeText(self.app.GetAppConf("Mode").(int)),
+		Spiders: []string{},
+		Pages: pageInfo{
+			Total:   cache.GetPageCount(0),
+			Success: cache.GetPageCount(1),
+			Failure: cache.GetPageCount(-1),
+		},
+		Reports: self.app.GetReports(),
+	}
+	if !self.app.IsStopped() {
+		for _, sp := range self.app.GetSpiderQueue().GetAll() {
+			info.Spiders = append(info.Spiders, sp.GetName())
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// GET /api/spiders
+func (self *Server) handleSpiders(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	list := []spiderInfo{}
+	for _, sp := range self.app.GetSpiderLib() {
+		list = append(list, spiderInfo{
+			Name:        sp.GetName(),
+			Description: sp.GetDescription(),
+			Keyin:       sp.GetKeyin(),
+			Limit:       sp.GetLimit(),
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GET /api/outputs
+func (self *Server) handleOutputs(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	writeJSON(w, http.StatusOK, self.app.GetOutputLib())
+}
+
+// GET /api/conf 查看全局参数
+// POST /api/conf 修改全局参数，如 {"ThreadNum": 10, "OutType": "csv"}，下一次任务生效
+func (self *Server) handleConf(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET", "POST") {
+		return
+	}
+	if r.Method == "GET" {
+		writeJSON(w, http.StatusOK, self.app.GetAppConf())
+		return
+	}
+
+	var raw map[string]json.RawMessage
+	if err := decodeJSON(r, &raw); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: %v", err)
+		return
+	}
+	// 全部校验通过后再写入
+	values := make(map[string]interface{}, len(raw))
+	conf := reflect.ValueOf(self.app.GetAppConf()).Elem()
+	for k, v := range raw {
+		key := strings.Title(k)
+		field := conf.FieldByName(key)
+		if !field.IsValid() {
+			writeError(w, http.StatusBadRequest, "unknown conf: %s", k)
+			return
+		}
+		if readonlyConf[key] {
+			writeError(w, http.StatusBadRequest, "conf %s can not be changed at runtime", key)
+			return
+		}
+		ptr := reflect.New(field.Type())
+		if err := json.Unmarshal(v, ptr.Interface()); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid %s: %v", key, err)
+			return
+		}
+		if err := checkConf(key, ptr.Elem().Interface()); err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		values[key] = ptr.Elem().Interface()
+	}
+	for k, v := range values {
+		self.app.SetAppConf(k, v)
+	}
+	writeJSON(w, http.StatusOK, self.app.GetAppConf())
+}
+
+// POST /api/run 运行任务，如 {"spiders": ["金价抓取"]}，运行全部蜘蛛时须显式传入 {}
+func (self *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "POST") {
+		return
+	}
+	var req runRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: %v", err)
+		return
+	}
+	if self.app.GetAppConf("Mode").(int) == status.CLIENT {
+		writeError(w, http.StatusBadRequest, "client node receives tasks from server")
+		return
+	}
+
+	var spiders []*spider.Spider
+	if len(req.Spiders) == 0 {
+		spiders = self.app.GetSpiderLib()
+	}
+	for _, name := range req.Spiders {
+		sp := self.app.GetSpiderByName(name)
+		if sp == nil {
+			writeError(w, http.StatusNotFound, "spider not found: %s", name)
+			return
+		}
+		spiders = append(spiders, sp)
+	}
+
+	// 与定时任务共用同一 App，由 App 判断是否已有任务
+	if err := self.app.TryPrepare(spiders); err != nil {
+		writeError(w, http.StatusConflict, "%v", err)
+		return
+	}
+	go self.app.Run()
+
+	names := make([]string, 0, len(spiders))
+	for _, sp := range spiders {
+		names = append(names, sp.GetName())
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"spiders": names})
+}
+
+// POST /api/stop 终止任务，不等待任务结束
+func (self *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "POST") {
+		return
+	}
+	if self.app.IsStopped() {
+		writeError(w, http.StatusConflict, "task is not running")
+		return
+	}
+	go self.app.Stop()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": statusText(status.STOP)})
+}
+
+// POST /api/pause 暂停或恢复任务
+func (self *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "POST") {
+		return
+	}
+	switch self.app.Status() {
+	case status.RUN, status.PAUSE:
+	default:
+		writeError(w, http.StatusConflict, "task is not running")
+		return
+	}
+	self.app.PauseRecover()
+	writeJSON(w, http.StatusOK, map[string]string{"status": statusText(self.app.Status())})
+}
+
+// 校验全局参数取值
+func checkConf(key string, v interface{}) error {
+	switch key {
+	case "ThreadNum", "DockerCap":
+		if v.(int) <= 0 {
+			return fmt.Errorf("invalid %s: %v", key, v)
+		}
+	case "Pausetime", "Limit", "ProxyMinute":
+		if v.(int64) < 0 {
+			return fmt.Errorf("invalid %s: %v", key, v)
+		}
+	case "OutType":
+		if _, ok := collector.DataOutput[v.(string)]; !ok {
+			return fmt.Errorf("unknown outtype: %v", v)
+		}
+	}
+	return nil
+}
+
+// 解析请求体中的单个 JSON 对象，请求体为空、含未知字段或多余内容时返回错误
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("empty body")
+		}
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after json object")
+	}
+	return nil
+}
+
+// 检查请求方法
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, format string, a ...interface{}) {
+	writeJSON(w, code, map[string]string{"error": fmt.Sprintf(format, a...)})
+}
+
+func statusText(s int) string {
+	switch s {
+	case status.STOPPED:
+		return "stopped"
+	case status.STOP:
+		return "stopping"
+	case status.RUN:
+		return "running"
+	case status.PAUSE:
+		return "paused"
+	}
+	return "unknown"
+}
+
+func modeText(m int) string {
+	switch m {
+	case status.OFFLINE:
+		return "offline"
+	case status.SERVER:
+		return "server"
+	case status.CLIENT:
+		return "client"
+	}
+	return "unset"
+}
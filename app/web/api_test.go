@@ -0,0 +1,161 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"skynet-service/app"
+	"skynet-service/app/runtime/cache"
+	"skynet-service/app/runtime/status"
+	"skynet-service/app/spider"
+)
+
+// 只实现接口测试用到的方法，其余方法调用时 panic
+type fakeApp struct {
+	app.App
+	conf     cache.AppConf
+	spiders  map[string]*spider.Spider
+	prepared []*spider.Spider
+	busy     error
+	ran      chan struct{}
+}
+
+func newFakeApp(names ...string) *fakeApp {
+	self := &fakeApp{
+		conf:    cache.AppConf{Mode: status.OFFLINE, ThreadNum: 1, OutType: "csv"},
+		spiders: map[string]*spider.Spider{},
+		ran:     make(chan struct{}, 1),
+	}
+	for _, name := range names {
+		self.spiders[name] = &spider.Spider{Name: name}
+	}
+	return self
+}
+
+func (self *fakeApp) GetAppConf(k ...string) interface{} {
+	if len(k) == 0 {
+		conf := self.conf
+		return &conf
+	}
+	if k[0] == "Mode" {
+		return self.conf.Mode
+	}
+	panic("unexpected conf " + k[0])
+}
+
+func (self *fakeApp) SetAppConf(k string, v interface{}) app.App {
+	switch k {
+	case "ThreadNum":
+		self.conf.ThreadNum = v.(int)
+	case "OutType":
+		self.conf.OutType = v.(string)
+	default:
+		panic("unexpected conf " + k)
+	}
+	return self
+}
+
+func (self *fakeApp) GetSpiderLib() []*spider.Spider {
+	var list []*spider.Spider
+	for _, sp := range self.spiders {
+		list = append(list, sp)
+	}
+	return list
+}
+
+func (self *fakeApp) GetSpiderByName(name string) *spider.Spider {
+	return self.spiders[name]
+}
+
+func (self *fakeApp) TryPrepare(original []*spider.Spider) error {
+	if self.busy != nil {
+		return self.busy
+	}
+	self.prepared = original
+	return nil
+}
+
+func (self *fakeApp) Run() {
+	self.ran <- struct{}{}
+}
+
+// 以本机请求访问，未配置用户时无须认证
+func serve(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Host = "localhost:9090"
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandleRun(t *testing.T) {
+	a := newFakeApp("a", "b")
+	s := New(a)
+
+	for _, body := range []string{"", "{", `{"spider": ["a"]}`, `{"spiders": "a"}`, `{} {}`} {
+		if w := serve(s, "POST", "/api/run", body); w.Code != http.StatusBadRequest {
+			t.Errorf("body %q: code = %d %s", body, w.Code, w.Body)
+		}
+	}
+	if a.prepared != nil {
+		t.Fatalf("rejected request prepared %v", a.prepared)
+	}
+
+	if w := serve(s, "POST", "/api/run", `{"spiders": ["nope"]}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown spider: code = %d", w.Code)
+	}
+	if w := serve(s, "GET", "/api/run", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: code = %d", w.Code)
+	}
+
+	w := serve(s, "POST", "/api/run", `{"spiders": ["b"]}`)
+	if w.Code != http.StatusAccepted || len(a.prepared) != 1 || a.prepared[0].Name != "b" {
+		t.Fatalf("run: code = %d %s, prepared %v", w.Code, w.Body, a.prepared)
+	}
+	select {
+	case <-a.ran:
+	case <-time.After(time.Second):
+		t.Fatal("Run() not called")
+	}
+
+	if w = serve(s, "POST", "/api/run", `{}`); w.Code != http.StatusAccepted || len(a.prepared) != 2 {
+		t.Fatalf("run all: code = %d, prepared %v", w.Code, a.prepared)
+	}
+	<-a.ran
+
+	// 定时任务等其他调用方已占用
+	a.busy = app.ErrRunning
+	if w = serve(s, "POST", "/api/run", `{}`); w.Code != http.StatusConflict {
+		t.Fatalf("busy: code = %d", w.Code)
+	}
+
+	a.conf.Mode = status.CLIENT
+	if w = serve(s, "POST", "/api/run", `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("client mode: code = %d", w.Code)
+	}
+}
+
+func TestHandleConf(t *testing.T) {
+	a := newFakeApp()
+	s := New(a)
+
+	for _, body := range []string{"", `{"Mode": 1}`, `{"Nope": 1}`, `{"ThreadNum": 0}`, `{"ThreadNum": "2"}`, `{"OutType": "nope"}`, `{"ThreadNum": 2, "OutType": "nope"}`} {
+		if w := serve(s, "POST", "/api/conf", body); w.Code != http.StatusBadRequest {
+			t.Errorf("body %q: code = %d %s", body, w.Code, w.Body)
+		}
+	}
+	if a.conf.ThreadNum != 1 {
+		t.Fatalf("rejected request changed ThreadNum to %d", a.conf.ThreadNum)
+	}
+
+	w := serve(s, "POST", "/api/conf", `{"threadNum": 4}`)
+	var conf cache.AppConf
+	if err := json.Unmarshal(w.Body.Bytes(), &conf); err != nil || w.Code != http.StatusOK || conf.ThreadNum != 4 {
+		t.Fatalf("code = %d %s, %v", w.Code, w.Body, err)
+	}
+}
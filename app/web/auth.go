@@ -0,0 +1,294 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"skynet-service/app/common/session"
+	"skynet-service/app/common/websocket"
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+	"skynet-service/app/runtime/user"
+)
+
+const (
+	sessionCookie = "skynetsid" // 会话 cookie 名称
+	sessionUser   = "user"      // 会话中保存用户名的键
+
+	loginMaxFailures = 5           // 同一来源地址连续登录失败的次数上限
+	loginLockout     = time.Minute // 达到上限后拒绝该地址登录的时长
+)
+
+type (
+	// 登录请求
+	loginRequest struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	// 对外展示的用户信息
+	userView struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	// 登录失败限速，按来源地址计数，登录接口与 HTTP Basic 认证共用
+	loginThrottle struct {
+		sync.Mutex
+		failures map[string]*loginFailure
+	}
+	loginFailure struct {
+		count int
+		last  time.Time // 最近一次失败的时刻
+	}
+)
+
+// 创建登录会话管理器
+func newSessionManager() (*session.Manager, error) {
+	cf, _ := json.Marshal(map[string]interface{}{
+		"cookieName":     sessionCookie,
+		"cookieSameSite": http.SameSiteStrictMode,
+		"gclifetime":     config.SESSION_MAXLIFETIME,
+		"maxLifetime":    config.SESSION_MAXLIFETIME,
+		"providerConfig": config.SessionDir,
+	})
+	m, err := session.NewManager(config.SESSION_PROVIDER, string(cf))
+	if err != nil {
+		return nil, fmt.Errorf("session: %v", err)
+	}
+	go m.GC()
+	return m, nil
+}
+
+// 认证与授权：GET 请求须具有只读权限，其余请求须具有操作员权限；
+// 未配置任何用户时不做认证，但只接受本机的请求
+func (self *Server) auth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkOrigin(w, r) {
+			return
+		}
+		if user.GAllUsers.Len() == 0 {
+			if !isLocal(r) {
+				writeError(w, http.StatusForbidden, "no users configured, only local access is allowed")
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+		u, ok := self.currentUser(w, r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "login required")
+			return
+		}
+		role := user.RoleOperator
+		if r.Method == "GET" || r.Method == "HEAD" {
+			role = user.RoleViewer
+		}
+		if !u.Can(role) {
+			writeError(w, http.StatusForbidden, "permission denied: %s role required", role)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// 拒绝跨站发起的修改请求(CSRF)：GET、HEAD 以外的请求带有 Origin 或 Referer 时，
+// 其主机须与请求的主机一致；两者均无时视为非浏览器客户端
+func checkOrigin(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	logs.Log.Warning(" *     拒绝跨站请求 %s %s，来源 %s", r.Method, r.URL.Path, origin)
+	writeError(w, http.StatusForbidden, "cross-origin request rejected")
+	return false
+}
+
+// 日志 WebSocket 的握手检查：浏览器总会带上 Origin，其主机须与请求的主机一致
+func checkWebsocketOrigin(config *websocket.Config, r *http.Request) (err error) {
+	config.Origin, err = websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if config.Origin == nil || config.Origin.Host != r.Host {
+		return fmt.Errorf("cross-origin websocket rejected: %s", r.Header.Get("Origin"))
+	}
+	return nil
+}
+
+// 请求是否来自本机，且以本机地址访问：
+// 否则 DNS 重绑定的网页可借本机浏览器访问，其 Host 与 Origin 均为攻击者的域名
+func isLocal(r *http.Request) bool {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || !isLoopback(remote) {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = strings.Trim(r.Host, "[]")
+	}
+	return strings.EqualFold(host, "localhost") || isLoopback(host)
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{failures: map[string]*loginFailure{}}
+}
+
+// 来源地址是否仍可尝试登录
+func (self *loginThrottle) allow(r *http.Request, now time.Time) bool {
+	self.Lock()
+	defer self.Unlock()
+	f, ok := self.failures[remoteHost(r)]
+	return !ok || f.count < loginMaxFailures || now.Sub(f.last) >= loginLockout
+}
+
+// 记录一次登录结果，成功时清除该地址的失败计数
+func (self *loginThrottle) done(r *http.Request, ok bool, now time.Time) {
+	host := remoteHost(r)
+	self.Lock()
+	defer self.Unlock()
+	if ok {
+		delete(self.failures, host)
+		return
+	}
+	f := self.failures[host]
+	if f == nil || now.Sub(f.last) >= loginLockout {
+		// 顺带清理已过期的记录，以免计数表无限增长
+		for k, v := range self.failures {
+			if now.Sub(v.last) >= loginLockout {
+				delete(self.failures, k)
+			}
+		}
+		f = &loginFailure{}
+		self.failures[host] = f
+	}
+	f.count++
+	f.last = now
+}
+
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// 限速后验证用户名与密码，被限速时返回 false 与 http.StatusTooManyRequests
+func (self *Server) validUser(r *http.Request, name, password string) (bool, int) {
+	now := time.Now()
+	if !self.throttle.allow(r, now) {
+		return false, http.StatusTooManyRequests
+	}
+	ok := user.GAllUsers.ValidUser(name, password)
+	self.throttle.done(r, ok, now)
+	if !ok {
+		return false, http.StatusUnauthorized
+	}
+	return true, http.StatusOK
+}
+
+// 当前请求的登录用户，用户被删除后视为未登录；
+// 带有 HTTP Basic 认证信息的请求(如 Prometheus 抓取)不使用会话
+func (self *Server) currentUser(w http.ResponseWriter, r *http.Request) (user.UserInfo, bool) {
+	if name, password, ok := r.BasicAuth(); ok {
+		if ok, _ := self.validUser(r, name, password); !ok {
+			return user.UserInfo{}, false
+		}
+		return user.GAllUsers.GetUser(name)
+	}
+	sess, err := self.sessions.SessionStart(w, r)
+	if err != nil {
+		return user.UserInfo{}, false
+	}
+	defer sess.SessionRelease(w)
+	name, _ := sess.Get(sessionUser).(string)
+	if name == "" {
+		return user.UserInfo{}, false
+	}
+	return user.GAllUsers.GetUser(name)
+}
+
+// POST /api/login 登录，如 {"name": "admin", "password": "..."}
+func (self *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "POST") || !checkOrigin(w, r) {
+		return
+	}
+	var req loginRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: %v", err)
+		return
+	}
+	if ok, code := self.validUser(r, req.Name, req.Password); !ok {
+		logs.Log.Warning(" *     用户 [%s] 登录失败，来自 %s", req.Name, r.RemoteAddr)
+		if code == http.StatusTooManyRequests {
+			writeError(w, code, "too many failed logins, try again later")
+			return
+		}
+		writeError(w, code, "invalid user name or password")
+		return
+	}
+
+	// 登录后更换会话id，防止会话固定攻击
+	sess := self.sessions.SessionRegenerateID(w, r)
+	if sess == nil {
+		writeError(w, http.StatusInternalServerError, "create session failed")
+		return
+	}
+	sess.Set(sessionUser, req.Name)
+	sess.SessionRelease(w)
+
+	u, _ := user.GAllUsers.GetUser(req.Name)
+	logs.Log.Informational(" *     用户 [%s] 已登录，来自 %s", u.Name, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, userView{Name: u.Name, Role: u.Role})
+}
+
+// POST /api/logout 退出登录
+func (self *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "POST") || !checkOrigin(w, r) {
+		return
+	}
+	self.sessions.SessionDestroy(w, r)
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+// GET /api/me 当前登录用户；未配置任何用户时 auth 为 false，且只接受本机的请求
+func (self *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	if user.GAllUsers.Len() == 0 {
+		if !isLocal(r) {
+			writeError(w, http.StatusForbidden, "no users configured, only local access is allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"auth": false})
+		return
+	}
+	u, ok := self.currentUser(w, r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "login required")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"auth": true,
+		"user": userView{Name: u.Name, Role: u.Role},
+	})
+}
@@ -0,0 +1,220 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"skynet-service/app/common/websocket"
+	"skynet-service/app/config"
+	"skynet-service/app/runtime/user"
+)
+
+// 以临时用户表替换全局用户表，测试结束后恢复
+func setUsers(t *testing.T, users ...[3]string) {
+	old := user.GAllUsers
+	t.Cleanup(func() { user.GAllUsers = old })
+	user.GAllUsers = user.NewUser(filepath.Join(t.TempDir(), "users.json"))
+	for _, u := range users {
+		if err := user.GAllUsers.AddUser(u[0], u[1], u[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newAuthServer(t *testing.T) *Server {
+	defer func(old string) { config.SESSION_PROVIDER = old }(config.SESSION_PROVIDER)
+	config.SESSION_PROVIDER = "memory"
+	s := New(newFakeApp())
+	var err error
+	if s.sessions, err = newSessionManager(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+type authRequest struct {
+	method, target, body string
+	remote, host, origin string
+	cookie               *http.Cookie
+	basic                []string
+}
+
+func (self authRequest) do(s *Server) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(self.method, self.target, strings.NewReader(self.body))
+	if self.remote != "" {
+		r.RemoteAddr = self.remote
+	}
+	if self.host != "" {
+		r.Host = self.host
+	}
+	if self.origin != "" {
+		r.Header.Set("Origin", self.origin)
+	}
+	if self.cookie != nil {
+		r.AddCookie(self.cookie)
+	}
+	if self.basic != nil {
+		r.SetBasicAuth(self.basic[0], self.basic[1])
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthNoUsers(t *testing.T) {
+	setUsers(t)
+	s := newAuthServer(t)
+	const (
+		local = "127.0.0.1:1234"
+		host  = "localhost:9090"
+	)
+
+	for _, c := range []struct {
+		req  authRequest
+		code int
+	}{
+		{authRequest{method: "GET", target: "/api/conf", remote: local, host: host}, http.StatusOK},
+		{authRequest{method: "GET", target: "/api/conf", remote: "[::1]:1234", host: "[::1]:9090"}, http.StatusOK},
+		{authRequest{method: "GET", target: "/api/conf", remote: local, host: "127.0.0.1"}, http.StatusOK},
+		{authRequest{method: "GET", target: "/api/conf", remote: "192.0.2.1:1234", host: host}, http.StatusForbidden},
+		{authRequest{method: "GET", target: "/api/me", remote: local, host: host}, http.StatusOK},
+		{authRequest{method: "GET", target: "/api/me", remote: "192.0.2.1:1234", host: host}, http.StatusForbidden},
+		{authRequest{method: "POST", target: "/api/conf", body: `{"threadNum": 2}`, remote: local, host: host, origin: "http://" + host}, http.StatusOK},
+		{authRequest{method: "POST", target: "/api/conf", body: `{"threadNum": 2}`, remote: local, host: host, origin: "http://evil.example"}, http.StatusForbidden},
+		{authRequest{method: "POST", target: "/api/conf", body: `{"threadNum": 2}`, remote: local, host: host, origin: "null"}, http.StatusForbidden},
+		// DNS 重绑定：Host 与 Origin 一致，但均为外部域名
+		{authRequest{method: "POST", target: "/api/stop", remote: local, host: "attacker.example", origin: "http://attacker.example"}, http.StatusForbidden},
+		{authRequest{method: "GET", target: "/api/me", remote: local, host: "attacker.example:9090"}, http.StatusForbidden},
+	} {
+		if w := c.req.do(s); w.Code != c.code {
+			t.Errorf("%+v: code = %d %s, want %d", c.req, w.Code, w.Body, c.code)
+		}
+	}
+}
+
+func TestAuthLogin(t *testing.T) {
+	setUsers(t, [3]string{"v", "pw", user.RoleViewer}, [3]string{"o", "pw", user.RoleOperator})
+	s := newAuthServer(t)
+	const conf = `{"threadNum": 2}`
+
+	if w := (authRequest{method: "GET", target: "/api/conf"}).do(s); w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous: code = %d", w.Code)
+	}
+	if w := (authRequest{method: "POST", target: "/api/login", body: `{"name": "v", "password": "nope"}`}).do(s); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: code = %d", w.Code)
+	}
+	if w := (authRequest{method: "POST", target: "/api/login", body: `{"name": "v", "password": "pw"}`, origin: "http://evil.example"}).do(s); w.Code != http.StatusForbidden {
+		t.Fatalf("cross-origin login: code = %d", w.Code)
+	}
+
+	login := func(name string) *http.Cookie {
+		w := (authRequest{method: "POST", target: "/api/login", body: `{"name": "` + name + `", "password": "pw"}`}).do(s)
+		if w.Code != http.StatusOK {
+			t.Fatalf("login %s: code = %d %s", name, w.Code, w.Body)
+		}
+		for _, c := range w.Result().Cookies() {
+			if c.Name == sessionCookie {
+				if c.SameSite != http.SameSiteStrictMode || !c.HttpOnly {
+					t.Errorf("session cookie = %+v", c)
+				}
+				return c
+			}
+		}
+		t.Fatalf("login %s: no session cookie", name)
+		return nil
+	}
+
+	viewer := login("v")
+	if w := (authRequest{method: "GET", target: "/api/conf", cookie: viewer}).do(s); w.Code != http.StatusOK {
+		t.Errorf("viewer GET: code = %d", w.Code)
+	}
+	if w := (authRequest{method: "POST", target: "/api/conf", body: conf, cookie: viewer}).do(s); w.Code != http.StatusForbidden {
+		t.Errorf("viewer POST: code = %d", w.Code)
+	}
+
+	operator := login("o")
+	if w := (authRequest{method: "POST", target: "/api/conf", body: conf, cookie: operator}).do(s); w.Code != http.StatusOK {
+		t.Errorf("operator POST: code = %d %s", w.Code, w.Body)
+	}
+	if w := (authRequest{method: "POST", target: "/api/conf", body: conf, cookie: operator, origin: "http://evil.example"}).do(s); w.Code != http.StatusForbidden {
+		t.Errorf("cross-origin operator POST: code = %d", w.Code)
+	}
+	if w := (authRequest{method: "POST", target: "/api/conf", body: conf, basic: []string{"o", "pw"}}).do(s); w.Code != http.StatusOK {
+		t.Errorf("basic auth POST: code = %d", w.Code)
+	}
+	if w := (authRequest{method: "GET", target: "/api/conf", basic: []string{"o", "nope"}}).do(s); w.Code != http.StatusUnauthorized {
+		t.Errorf("basic auth wrong password: code = %d", w.Code)
+	}
+
+	if w := (authRequest{method: "POST", target: "/api/logout", cookie: operator}).do(s); w.Code != http.StatusOK {
+		t.Fatalf("logout: code = %d", w.Code)
+	}
+	if w := (authRequest{method: "GET", target: "/api/conf", cookie: operator}).do(s); w.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: code = %d", w.Code)
+	}
+
+	// 用户被删除后会话失效
+	if err := user.GAllUsers.DelUser("v"); err != nil {
+		t.Fatal(err)
+	}
+	if w := (authRequest{method: "GET", target: "/api/conf", cookie: viewer}).do(s); w.Code != http.StatusUnauthorized {
+		t.Errorf("deleted user: code = %d", w.Code)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	setUsers(t, [3]string{"o", "pw", user.RoleOperator})
+	s := newAuthServer(t)
+	const attacker = "192.0.2.1:1234"
+
+	for i := 0; i < loginMaxFailures; i++ {
+		if w := (authRequest{method: "POST", target: "/api/login", body: `{"name": "o", "password": "nope"}`, remote: attacker}).do(s); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: code = %d", i, w.Code)
+		}
+	}
+	// 达到上限后即使密码正确也拒绝，Basic 认证同样受限
+	if w := (authRequest{method: "POST", target: "/api/login", body: `{"name": "o", "password": "pw"}`, remote: attacker}).do(s); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked out login: code = %d", w.Code)
+	}
+	if w := (authRequest{method: "GET", target: "/api/conf", basic: []string{"o", "pw"}, remote: attacker}).do(s); w.Code != http.StatusUnauthorized {
+		t.Fatalf("locked out basic auth: code = %d", w.Code)
+	}
+	// 其他地址不受影响
+	if w := (authRequest{method: "POST", target: "/api/login", body: `{"name": "o", "password": "pw"}`, remote: "192.0.2.2:1234"}).do(s); w.Code != http.StatusOK {
+		t.Fatalf("other address: code = %d", w.Code)
+	}
+
+	// 锁定时长过后恢复
+	r := httptest.NewRequest("POST", "/api/login", nil)
+	r.RemoteAddr = attacker
+	if s.throttle.allow(r, time.Now()) || !s.throttle.allow(r, time.Now().Add(loginLockout)) {
+		t.Fatal("lockout window mismatch")
+	}
+	s.throttle.done(r, true, time.Now())
+	if !s.throttle.allow(r, time.Now()) {
+		t.Fatal("successful login did not reset failures")
+	}
+}
+
+func TestCheckWebsocketOrigin(t *testing.T) {
+	for origin, ok := range map[string]bool{
+		"http://example.com":  true,
+		"https://example.com": true,
+		"http://evil.example": false,
+		"null":                false,
+		"":                    false,
+	} {
+		r := httptest.NewRequest("GET", "/api/logs", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		err := checkWebsocketOrigin(&websocket.Config{Version: websocket.ProtocolVersionHybi13}, r)
+		if (err == nil) != ok {
+			t.Errorf("origin %q: err = %v", origin, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"skynet-service/app/aid/change"
+)
+
+// 变化事件的默认查询时长与条数
+const (
+	defaultChangesWindow = 24 * time.Hour
+	defaultChangesLimit  = 100
+)
+
+// GET /api/changes?spider=&window=&limit= 按时间倒序列出结果变化事件
+func (self *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	q := r.URL.Query()
+	window := defaultChangesWindow
+	if v := q.Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid window: %s", v)
+			return
+		}
+		window = d
+	}
+	limit := defaultChangesLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit: %s", v)
+			return
+		}
+		limit = n
+	}
+	events, err := change.List(q.Get("spider"), time.Now().Add(-window), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	_ "embed"
+	"net/http"
+	"time"
+)
+
+// 内嵌的仪表盘页面
+//
+//go:embed static/index.html
+var dashboardHTML []byte
+
+// 蜘蛛的实时进度
+type spiderProgress struct {
+	Name     string `json:"name"`
+	Keyin    string `json:"keyin"`
+	State    string `json:"state"`    // waiting | running | finished
+	Queue    int    `json:"queue"`    // 队列中等待下载的请求数
+	InFlight int32  `json:"inflight"` // 正在下载的请求数
+	Success  uint64 `json:"success"`
+	Failure  uint64 `json:"failure"`
+	Retried  uint64 `json:"retried"` // 首次失败后重试的页数
+	Items    uint64 `json:"items"`
+	Files    uint64 `json:"files"`
+	Elapsed  int64  `json:"elapsed"` // 已用时长，单位毫秒
+}
+
+// GET / 仪表盘页面
+func (self *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if !allow(w, r, "GET") {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// GET /api/progress 当前(或最近一次)任务中各蜘蛛的实时进度
+func (self *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	list := []spiderProgress{}
+	for _, sp := range self.app.GetSpiderQueue().GetAll() {
+		item := spiderProgress{
+			Name:  sp.GetName(),
+			Keyin: sp.GetKeyin(),
+			State: "waiting",
+		}
+		if p, ok := sp.Progress(); ok {
+			item.State = "running"
+			if p.Finished {
+				item.State = "finished"
+			}
+			item.Queue = p.Queue
+			item.InFlight = p.ResCount
+			item.Success = p.Success
+			item.Failure = p.Failure
+			item.Retried = p.Retried
+			item.Items = p.Items
+			item.Files = p.Files
+			item.Elapsed = int64(p.Elapsed / time.Millisecond)
+		}
+		list = append(list, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  statusText(self.app.Status()),
+		"spiders": list,
+	})
+}
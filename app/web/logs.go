@@ -0,0 +1,90 @@
+package web
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"skynet-service/app/common/websocket"
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+)
+
+// 每个连接缓存的日志条数，客户端跟不上时丢弃
+const logBuffer = 1024
+
+// 推送给浏览器的一条日志
+type logLine struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Msg     string `json:"msg"`
+	Dropped uint64 `json:"dropped,omitempty"` // 此前累计丢弃的条数
+}
+
+// GET /api/logs 以 websocket 实时推送日志
+// 参数 level 为最低级别(默认 info)，spider 为蜘蛛名称，只推送该蜘蛛的日志
+func (self *Server) handleLogs(ws *websocket.Conn) {
+	defer ws.Close()
+
+	query := ws.Request().URL.Query()
+	level := logs.LevelInformational
+	if l := query.Get("level"); l != "" {
+		var ok bool
+		if level, ok = config.ParseLogLevel(l); !ok {
+			websocket.JSON.Send(ws, map[string]string{"error": "unknown level: " + l})
+			return
+		}
+	}
+	sub := logs.Log.Subscribe(level, query.Get("spider"), logBuffer)
+	defer logs.Log.Unsubscribe(sub)
+
+	// 客户端断开后取消订阅，使下方循环退出
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		logs.Log.Unsubscribe(sub)
+	}()
+
+	var reported uint64
+	for {
+		level, msg, when, ok := sub.Next()
+		if !ok {
+			return
+		}
+		line := logLine{
+			Time:  when.Format(time.RFC3339),
+			Level: levelName(level),
+			Msg:   strings.TrimRight(msg, "\r\n"),
+		}
+		if dropped := sub.Dropped(); dropped != reported {
+			line.Dropped, reported = dropped, dropped
+		}
+		if _, err := websocket.JSON.Send(ws, line); err != nil {
+			return
+		}
+	}
+}
+
+func levelName(level int) string {
+	switch level {
+	case logs.LevelApp:
+		return "app"
+	case logs.LevelEmergency:
+		return "emergency"
+	case logs.LevelAlert:
+		return "alert"
+	case logs.LevelCritical:
+		return "critical"
+	case logs.LevelError:
+		return "error"
+	case logs.LevelWarning:
+		return "warning"
+	case logs.LevelNotice:
+		return "notice"
+	case logs.LevelInformational:
+		return "info"
+	case logs.LevelDebug:
+		return "debug"
+	}
+	return "unknown"
+}
@@ -0,0 +1,16 @@
+package web
+
+import (
+	"net/http"
+
+	"skynet-service/app/runtime/metrics"
+)
+
+// GET /metrics 以 Prometheus 文本格式导出运行指标
+func (self *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WriteTo(w)
+}
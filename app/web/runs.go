@@ -0,0 +1,53 @@
+package web
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"skynet-service/app/runtime/runs"
+)
+
+// 运行记录列表的默认条数
+const defaultRunsLimit = 20
+
+// GET /api/runs?spider=&limit= 按时间倒序列出运行记录
+func (self *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	limit := defaultRunsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit: %s", v)
+			return
+		}
+		limit = n
+	}
+	list, err := runs.Runs.List(r.URL.Query().Get("spider"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GET /api/runs/{id} 查看一条运行记录
+func (self *Server) handleRunDetail(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "GET") {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/runs/")
+	run, err := runs.Runs.Get(id)
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, "run not found: %s", id)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
@@ -0,0 +1,105 @@
+// HTTP 控制接口
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"skynet-service/app"
+	"skynet-service/app/common/session"
+	"skynet-service/app/common/websocket"
+	"skynet-service/app/config"
+	"skynet-service/app/logs"
+	"skynet-service/app/runtime/user"
+)
+
+// 关闭服务时等待未完成请求的时长
+const shutdownTimeout = 5 * time.Second
+
+// 以 JSON 形式对外提供 app.App 的控制接口
+type Server struct {
+	app      app.App
+	mux      *http.ServeMux
+	srv      *http.Server
+	sessions *session.Manager // 登录会话
+	throttle *loginThrottle   // 登录失败限速
+}
+
+func New(a app.App) *Server {
+	self := &Server{
+		app:      a,
+		mux:      http.NewServeMux(),
+		throttle: newLoginThrottle(),
+	}
+	self.routes()
+	return self
+}
+
+// 注册路由，除页面与登录接口外均须认证
+func (self *Server) routes() {
+	self.mux.HandleFunc("/", self.handleDashboard)
+	self.mux.HandleFunc("/api/login", self.handleLogin)
+	self.mux.HandleFunc("/api/logout", self.handleLogout)
+	self.mux.HandleFunc("/api/me", self.handleMe)
+
+	self.handle("/api/status", http.HandlerFunc(self.handleStatus))
+	self.handle("/api/progress", http.HandlerFunc(self.handleProgress))
+	self.handle("/api/spiders", http.HandlerFunc(self.handleSpiders))
+	self.handle("/api/outputs", http.HandlerFunc(self.handleOutputs))
+	self.handle("/api/conf", http.HandlerFunc(self.handleConf))
+	self.handle("/api/run", http.HandlerFunc(self.handleRun))
+	self.handle("/api/stop", http.HandlerFunc(self.handleStop))
+	self.handle("/api/pause", http.HandlerFunc(self.handlePause))
+	self.handle("/api/runs", http.HandlerFunc(self.handleRuns))
+	self.handle("/api/runs/", http.HandlerFunc(self.handleRunDetail))
+	self.handle("/api/changes", http.HandlerFunc(self.handleChanges))
+	self.handle("/api/logs", websocket.Server{Handler: self.handleLogs, Handshake: checkWebsocketOrigin})
+	self.handle("/metrics", http.HandlerFunc(self.handleMetrics))
+}
+
+// 注册须认证的路由
+func (self *Server) handle(pattern string, h http.Handler) {
+	self.mux.Handle(pattern, self.auth(h))
+}
+
+// 监听 addr 并在后台提供服务，监听失败时返回错误
+func (self *Server) Start(addr string) error {
+	sessions, err := newSessionManager()
+	if err != nil {
+		return err
+	}
+	self.sessions = sessions
+	if user.GAllUsers.Len() == 0 {
+		logs.Log.Warning(" *     未配置用户(%s)，HTTP 控制接口不做认证，仅接受本机以 localhost 或回环地址发起的访问", config.USER_FILE)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	self.srv = &http.Server{Handler: self.mux}
+	go func() {
+		if err := self.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logs.Log.Error(" *     HTTP 服务异常退出：%v", err)
+		}
+	}()
+	logs.Log.Informational(" *     HTTP 控制接口已开启：http://%s", ln.Addr())
+	return nil
+}
+
+// 关闭服务
+func (self *Server) Stop() {
+	if self.srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	self.srv.Shutdown(ctx)
+	self.srv = nil
+}
+
+func (self *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.mux.ServeHTTP(w, r)
+}
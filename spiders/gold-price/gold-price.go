@@ -2,31 +2,40 @@ package gold_price
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"skynet-service/app/common/timeUtils"
-	"skynet-service/app/logs"
-	"skynet-service/app/spider"
+	"regexp"
+	"strings"
+	"time"
 
-	//"skynet-service/app/common/request"
 	"skynet-service/app/downloader/request"
+	"skynet-service/app/spider"
 )
 
 func init() {
 	GoldPrice.Register()
 }
 
+const (
+	priceUrl        = "https://data-asg.goldprice.org/dbXRates/"
+	defaultCurrency = "CNY"
+)
+
+// 币种代码，如 CNY、USD
+var currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
 type GoldPriceData struct {
-	Ts int64 `json:"ts"`
-	Tsj int64 `json:"tsj"`
-	Date string `json:"date"`
-	Items [] struct {
-		Curr string `json:"curr"`
+	Ts    int64  `json:"ts"`
+	Tsj   int64  `json:"tsj"`
+	Date  string `json:"date"`
+	Items []struct {
+		Curr     string  `json:"curr"`
 		XauPrice float64 `json:"xauPrice"`
 		XagPrice float64 `json:"xagPrice"`
-		ChgXau float64 `json:"chgXau"`
-		ChgXag float64 `json:"chgXag"`
-		PcXau float64 `json:"pcXau"`
-		PcXag float64 `json:"pcXag"`
+		ChgXau   float64 `json:"chgXau"`
+		ChgXag   float64 `json:"chgXag"`
+		PcXau    float64 `json:"pcXau"`
+		PcXag    float64 `json:"pcXag"`
 		XauClose float64 `json:"xauClose"`
 		XagClose float64 `json:"xagClose"`
 	} `json:"items"`
@@ -34,56 +43,82 @@ type GoldPriceData struct {
 
 var GoldPrice = &spider.Spider{
 	Name:        "金价抓取",
-	Description: "获取伦敦金价、美国金价、中国金价",
-	RuleTree:    &spider.RuleTree{
+	Description: "获取金、银价格及涨跌，自定义输入为币种代码，如 <CNY><USD> 或 <CNY,USD>，默认为 CNY",
+	Keyin:       spider.KEYIN,
+	RuleTree: &spider.RuleTree{
 		Root: func(ctx *spider.Context) {
+			currencies, err := parseCurrencies(ctx.GetKeyin())
+			if err != nil {
+				ctx.SetError(fmt.Errorf("自定义输入有误：%v", err))
+				return
+			}
 			ctx.AddQueue(&request.Request{
-				Url:  "https://data-asg.goldprice.org/dbXRates/CNY",
+				Url:  priceUrl + strings.Join(currencies, ","),
 				Rule: "获取标准黄金价格",
-				Temp: map[string]interface{}{
-					"target": "first",
-				},
 			})
 		},
 
 		Trunk: map[string]*spider.Rule{
 			"获取标准黄金价格": {
-				ItemFields: []string {
-					"gid",
-					"gold-1",
-					"gold-2",
-					"gold-h",
-					"gold-l",
-					"gold-v",
-					"gold-a",
-					"gold-t",
+				UniqueKey: []string{"Curr", "Time"}, // 同一币种同一报价时间只保留一条
+				Schema: []spider.Field{
+					{Name: "Curr", Required: true},                             // 币种
+					{Name: "XauPrice", Type: spider.TypeFloat, Required: true}, // 金价，每盎司
+					{Name: "XagPrice", Type: spider.TypeFloat},                 // 银价，每盎司
+					{Name: "ChgXau", Type: spider.TypeFloat},                   // 金价涨跌
+					{Name: "ChgXag", Type: spider.TypeFloat},                   // 银价涨跌
+					{Name: "PcXau", Type: spider.TypeFloat},                    // 金价涨跌幅，百分比
+					{Name: "PcXag", Type: spider.TypeFloat},                    // 银价涨跌幅，百分比
+					{Name: "XauClose", Type: spider.TypeFloat},                 // 金价昨收
+					{Name: "XagClose", Type: spider.TypeFloat},                 // 银价昨收
+					{Name: "Time", Type: spider.TypeTime},                      // 报价时间
 				},
 				ParseFunc: func(ctx *spider.Context) {
-					jsonStr := ctx.GetText()
 					gs := &GoldPriceData{}
-					json.Unmarshal([]byte(jsonStr), &gs)
-					gold1 := gs.Items[0].XauPrice
-					gold2 := gs.Items[0].XauPrice
-					goldh := gs.Items[0].XauPrice
-					goldl := gs.Items[0].XauPrice
-					goldv := gs.Items[0].ChgXau
-					golda := 0
-					goldt := timeUtils.GetTimeStamp()
-					id := fmt.Sprintf("%d-%.2f-%.2f-%.2f-%.2f-%.2f-%d", goldt, gold1, gold2, goldh, goldl, goldv, golda)
-
-					ctx.Output (map[int]interface{} {
-						0: id,
-						1: gold1,
-						2: gold2,
-						3: goldh,
-						4: goldl,
-						5: goldv,
-						6: golda,
-						7: goldt,
-					})
-					logs.Log.Informational("%v", id)
+					if err := json.Unmarshal([]byte(ctx.GetText()), gs); err != nil {
+						ctx.SetError(fmt.Errorf("解析报价失败：%v", err))
+						return
+					}
+					if len(gs.Items) == 0 {
+						ctx.SetError(errors.New("报价为空"))
+						return
+					}
+					quoted := time.UnixMilli(gs.Ts)
+					for _, item := range gs.Items {
+						ctx.Output(map[int]interface{}{
+							0: item.Curr,
+							1: item.XauPrice,
+							2: item.XagPrice,
+							3: item.ChgXau,
+							4: item.ChgXag,
+							5: item.PcXau,
+							6: item.PcXag,
+							7: item.XauClose,
+							8: item.XagClose,
+							9: quoted,
+						})
+					}
 				},
 			},
 		},
 	},
-}
\ No newline at end of file
+}
+
+// 解析自定义输入中以逗号或空白分隔的币种代码，未输入时为默认币种
+func parseCurrencies(keyin string) ([]string, error) {
+	if keyin == spider.KEYIN {
+		keyin = ""
+	}
+	fields := strings.FieldsFunc(strings.ToUpper(keyin), func(r rune) bool {
+		return r == ',' || r == '，' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+	if len(fields) == 0 {
+		return []string{defaultCurrency}, nil
+	}
+	for _, c := range fields {
+		if !currencyRegexp.MatchString(c) {
+			return nil, fmt.Errorf("invalid currency code: %q", c)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,55 @@
+package gold_price
+
+import (
+	"testing"
+	"time"
+
+	"skynet-service/app/spider/spidertest"
+)
+
+func TestGoldPrice(t *testing.T) {
+	replayer, err := spidertest.Load("testdata/fixtures.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &spidertest.Runner{Spider: GoldPrice, Downloader: replayer, Keyin: "usd, eur"}
+	result := runner.Run()
+	if err := result.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Requests) != 1 || result.Requests[0].GetUrl() != priceUrl+"USD,EUR" {
+		t.Fatalf("requests = %v", result.Requests)
+	}
+
+	items := result.ItemsOf("获取标准黄金价格")
+	if len(items) != 2 {
+		t.Fatalf("items = %v", items)
+	}
+	usd := items[0]
+	if usd["Curr"] != "USD" || usd["XauPrice"] != 1963.415 || usd["PcXag"] != 2.4845 {
+		t.Errorf("USD = %v", usd)
+	}
+	if tm, ok := usd["Time"].(time.Time); !ok || tm.Unix() != 1700000000 {
+		t.Errorf("Time = %v", usd["Time"])
+	}
+	if items[1]["Curr"] != "EUR" {
+		t.Errorf("EUR = %v", items[1])
+	}
+
+	// 报价为空时记为失败
+	runner.Keyin = "XXX"
+	result = runner.Run()
+	if len(result.Items) != 0 || len(result.Failures) != 1 {
+		t.Fatalf("items = %v, failures = %v", result.Items, result.Failures)
+	}
+
+	// 自定义输入有误时不发出请求，记为 Root 失败
+	runner.Keyin = "usd,rmb1"
+	result = runner.Run()
+	if len(result.Requests) != 0 || len(result.Failures) != 1 || result.Failures[0].Request != nil {
+		t.Fatalf("requests = %v, failures = %v", result.Requests, result.Failures)
+	}
+	if _, err := parseCurrencies("usd,rmb1"); err == nil {
+		t.Error("invalid currency should fail")
+	}
+}